@@ -20,12 +20,14 @@ const (
 // Enumeration of some values with single fixed-byte representations.
 // All of these are in the "simple" space.
 // See https://tools.ietf.org/html/rfc7049#section-2.3 for tables.
-// The prefix indicating a float is also packed into the simple space.
+// The prefix indicating a float is also packed into the simple space,
+// as is the prefix for simple values too large to pack into the first byte.
 const (
 	cborSigilFalse     byte = 0xf4
 	cborSigilTrue           = 0xf5
 	cborSigilNil            = 0xf6
 	cborSigilUndefined      = 0xf7
+	cborSigilSimple8        = 0xf8
 	cborSigilFloat16        = 0xf9
 	cborSigilFloat32        = 0xfA
 	cborSigilFloat64        = 0xfB
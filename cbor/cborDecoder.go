@@ -1,6 +1,7 @@
 package cbor
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 
@@ -9,16 +10,29 @@ import (
 )
 
 type Decoder struct {
-	r shared.SlickReader
+	r   shared.SlickReader
+	cfg DecodeOptions
 
 	stack []decoderStep // When empty, and step returns done, all done.
 	step  decoderStep   // Shortcut to end of stack.
-	left  []int         // Statekeeping space for definite-len map and array.
+	left  []int         // Statekeeping space for map and array: entries remaining if definite-len; entries seen so far if indefinite.
+	keys  [][]byte      // Statekeeping space for map: encoded form of the previous key.  Only used in strict mode.
+	base  int           // Reader position at last reset; used to count against MaxTotalBytes and for error offsets.
 }
 
 func NewDecoder(r io.Reader) (d *Decoder) {
+	return NewDecoderWithOptions(DecodeOptions{}, r)
+}
+
+func NewDecoderWithOptions(cfg DecodeOptions, r io.Reader) (d *Decoder) {
+	switch cfg.FloatMode {
+	case "", FloatMode_Shortest, FloatMode_Float64:
+	default:
+		panic(fmt.Errorf("cannot require float mode %q", cfg.FloatMode))
+	}
 	d = &Decoder{
 		r:     shared.NewReader(r),
+		cfg:   cfg,
 		stack: make([]decoderStep, 0, 10),
 		left:  make([]int, 0, 10),
 	}
@@ -30,6 +44,8 @@ func (d *Decoder) Reset() {
 	d.stack = d.stack[0:0]
 	d.step = d.step_acceptValue
 	d.left = d.left[0:0]
+	d.keys = d.keys[0:0]
+	d.base = d.r.NumRead()
 }
 
 type decoderStep func(tokenSlot *Token) (done bool, err error)
@@ -37,7 +53,14 @@ type decoderStep func(tokenSlot *Token) (done bool, err error)
 func (d *Decoder) Step(tokenSlot *Token) (done bool, err error) {
 	done, err = d.step(tokenSlot)
 	// If the step errored: out, entirely.
+	// Running out of input is only a clean EOF if we hadn't started on a value yet.
 	if err != nil {
+		if err == io.EOF && d.r.NumRead() != d.base {
+			err = io.ErrUnexpectedEOF
+		}
+		return true, err
+	}
+	if err := d.checkTotalBytes(0); err != nil {
 		return true, err
 	}
 	// If the step wasn't done, return same status.
@@ -54,9 +77,63 @@ func (d *Decoder) Step(tokenSlot *Token) (done bool, err error) {
 	return false, nil
 }
 
-func (d *Decoder) pushPhase(newPhase decoderStep) {
+func (d *Decoder) pushPhase(newPhase decoderStep) error {
+	if err := shared.CheckLimit("MaxDepth", int64(d.cfg.MaxDepth), int64(len(d.stack)+1)); err != nil {
+		return err
+	}
 	d.stack = append(d.stack, d.step)
 	d.step = newPhase
+	return nil
+}
+
+// Count one more entry in the indefinite-length map or array we're in the midst of.
+func (d *Decoder) countIndefEntry() error {
+	ll := len(d.left) - 1
+	d.left[ll]++
+	return shared.CheckLimit("MaxContainerLength", int64(d.cfg.MaxContainerLength), int64(d.left[ll]))
+}
+
+// Return an ErrNonCanonical about an item which began `size` bytes ago.
+func (d *Decoder) errNonCanonical(size int, reason string) error {
+	return &ErrNonCanonical{d.r.NumRead() - d.base - size, reason}
+}
+
+// Start a fresh slot for remembering the previous key of a map.
+func (d *Decoder) pushKeys() {
+	n := len(d.keys)
+	if n < cap(d.keys) {
+		d.keys = d.keys[:n+1]
+		d.keys[n] = d.keys[n][:0]
+	} else {
+		d.keys = append(d.keys, nil)
+	}
+}
+
+// Check the key just read (bytes tracked since the key began) sorts
+// strictly after the previous key in this map.
+// `done` is the result of stepping the key; not done means it opened a composite.
+func (d *Decoder) checkKeyOrder(done bool) error {
+	k := d.r.StopTrack()
+	if !done {
+		return d.errNonCanonical(len(k), "map key is a map or array")
+	}
+	n := len(d.keys) - 1
+	prev := d.keys[n]
+	if len(prev) > 0 {
+		switch c := bytes.Compare(prev, k); {
+		case c == 0:
+			return d.errNonCanonical(len(k), "duplicate map key")
+		case c > 0:
+			return d.errNonCanonical(len(k), "map keys not in bytewise sorted order")
+		}
+	}
+	d.keys[n] = append(prev[:0], k...)
+	return nil
+}
+
+// Check that reading `n` more bytes would stay within MaxTotalBytes.
+func (d *Decoder) checkTotalBytes(n int) error {
+	return shared.CheckLimit("MaxTotalBytes", d.cfg.MaxTotalBytes, int64(d.r.NumRead()-d.base)+int64(n))
 }
 
 // The original step, where any value is accepted, and no terminators for composites are valid.
@@ -67,6 +144,7 @@ func (d *Decoder) step_acceptValue(tokenSlot *Token) (done bool, err error) {
 		return true, err
 	}
 	tokenSlot.Tagged = false
+	tokenSlot.OuterTags = tokenSlot.OuterTags[:0]
 	return d.stepHelper_acceptValue(majorByte, tokenSlot)
 }
 
@@ -77,11 +155,16 @@ func (d *Decoder) step_acceptArrValueOrBreak(tokenSlot *Token) (done bool, err e
 		return true, err
 	}
 	tokenSlot.Tagged = false
+	tokenSlot.OuterTags = tokenSlot.OuterTags[:0]
 	switch majorByte {
 	case cborSigilBreak:
+		d.left = d.left[0 : len(d.left)-1]
 		tokenSlot.Type = TArrClose
 		return true, nil
 	default:
+		if err := d.countIndefEntry(); err != nil {
+			return true, err
+		}
 		_, err := d.stepHelper_acceptValue(majorByte, tokenSlot)
 		return false, err
 	}
@@ -94,11 +177,16 @@ func (d *Decoder) step_acceptMapIndefKey(tokenSlot *Token) (done bool, err error
 		return true, err
 	}
 	tokenSlot.Tagged = false
+	tokenSlot.OuterTags = tokenSlot.OuterTags[:0]
 	switch majorByte {
 	case cborSigilBreak:
+		d.left = d.left[0 : len(d.left)-1]
 		tokenSlot.Type = TMapClose
 		return true, nil
 	default:
+		if err := d.countIndefEntry(); err != nil {
+			return true, err
+		}
 		d.step = d.step_acceptMapIndefValueOrBreak
 		_, err := d.stepHelper_acceptValue(majorByte, tokenSlot) // FIXME surely not *any* value?  not composites, at least?
 		return false, err
@@ -112,6 +200,7 @@ func (d *Decoder) step_acceptMapIndefValueOrBreak(tokenSlot *Token) (done bool,
 		return true, err
 	}
 	tokenSlot.Tagged = false
+	tokenSlot.OuterTags = tokenSlot.OuterTags[:0]
 	switch majorByte {
 	case cborSigilBreak:
 		return true, fmt.Errorf("unexpected break; expected value in indefinite-length map")
@@ -128,6 +217,8 @@ func (d *Decoder) step_acceptArrValue(tokenSlot *Token) (done bool, err error) {
 	ll := len(d.left) - 1
 	if d.left[ll] == 0 {
 		d.left = d.left[0:ll]
+		tokenSlot.Tagged = false
+		tokenSlot.OuterTags = tokenSlot.OuterTags[:0]
 		tokenSlot.Type = TArrClose
 		return true, nil
 	}
@@ -138,6 +229,7 @@ func (d *Decoder) step_acceptArrValue(tokenSlot *Token) (done bool, err error) {
 		return true, err
 	}
 	tokenSlot.Tagged = false
+	tokenSlot.OuterTags = tokenSlot.OuterTags[:0]
 	_, err = d.stepHelper_acceptValue(majorByte, tokenSlot)
 	return false, err
 }
@@ -148,18 +240,30 @@ func (d *Decoder) step_acceptMapKey(tokenSlot *Token) (done bool, err error) {
 	ll := len(d.left) - 1
 	if d.left[ll] == 0 {
 		d.left = d.left[0:ll]
+		if d.cfg.Strict {
+			d.keys = d.keys[0 : len(d.keys)-1]
+		}
+		tokenSlot.Tagged = false
+		tokenSlot.OuterTags = tokenSlot.OuterTags[:0]
 		tokenSlot.Type = TMapClose
 		return true, nil
 	}
 	d.left[ll]--
 	// Read next key.
+	if d.cfg.Strict {
+		d.r.Track()
+	}
 	majorByte, err := d.r.Readn1()
 	if err != nil {
 		return true, err
 	}
 	d.step = d.step_acceptMapValue
 	tokenSlot.Tagged = false
-	_, err = d.stepHelper_acceptValue(majorByte, tokenSlot) // FIXME surely not *any* value?  not composites, at least?
+	tokenSlot.OuterTags = tokenSlot.OuterTags[:0]
+	done, err = d.stepHelper_acceptValue(majorByte, tokenSlot) // FIXME surely not *any* value?  not composites, at least?
+	if d.cfg.Strict && err == nil {
+		err = d.checkKeyOrder(done)
+	}
 	return false, err
 }
 
@@ -172,15 +276,32 @@ func (d *Decoder) step_acceptMapValue(tokenSlot *Token) (done bool, err error) {
 	}
 	d.step = d.step_acceptMapKey
 	tokenSlot.Tagged = false
+	tokenSlot.OuterTags = tokenSlot.OuterTags[:0]
 	_, err = d.stepHelper_acceptValue(majorByte, tokenSlot)
 	return false, err
 }
 
 func (d *Decoder) stepHelper_acceptValue(majorByte byte, tokenSlot *Token) (done bool, err error) {
+	tokenSlot.Width = 0
 	switch majorByte {
 	case cborSigilNil:
 		tokenSlot.Type = TNull
 		return true, nil
+	case cborSigilUndefined:
+		tokenSlot.Type = TUndefined
+		return true, nil
+	case cborSigilSimple8:
+		var v byte
+		v, err = d.r.Readn1()
+		if err != nil {
+			return true, err
+		}
+		if v < 32 { // The two-byte form of simple values below 32 is not well-formed.
+			return true, fmt.Errorf("cbor: invalid simple value %d in two-byte form", v)
+		}
+		tokenSlot.Type = TSimple
+		tokenSlot.Uint = uint64(v)
+		return true, nil
 	case cborSigilFalse:
 		tokenSlot.Type = TBool
 		tokenSlot.Bool = false
@@ -191,34 +312,49 @@ func (d *Decoder) stepHelper_acceptValue(majorByte byte, tokenSlot *Token) (done
 		return true, nil
 	case cborSigilFloat16, cborSigilFloat32, cborSigilFloat64:
 		tokenSlot.Type = TFloat64
+		tokenSlot.Width = 2 << (majorByte - cborSigilFloat16)
 		tokenSlot.Float64, err = d.decodeFloat(majorByte)
 		return true, err
 	case cborSigilIndefiniteBytes:
+		if d.cfg.Strict {
+			return true, d.errNonCanonical(1, "indefinite-length byte string")
+		}
 		tokenSlot.Type = TBytes
 		tokenSlot.Bytes, err = d.decodeBytesIndefinite(nil)
 		return true, err
 	case cborSigilIndefiniteString:
+		if d.cfg.Strict {
+			return true, d.errNonCanonical(1, "indefinite-length string")
+		}
 		tokenSlot.Type = TString
 		tokenSlot.Str, err = d.decodeStringIndefinite()
 		return true, err
 	case cborSigilIndefiniteArray:
+		if d.cfg.Strict {
+			return true, d.errNonCanonical(1, "indefinite-length array")
+		}
 		tokenSlot.Type = TArrOpen
 		tokenSlot.Length = -1
-		d.pushPhase(d.step_acceptArrValueOrBreak)
-		return false, nil
+		d.left = append(d.left, 0)
+		return false, d.pushPhase(d.step_acceptArrValueOrBreak)
 	case cborSigilIndefiniteMap:
+		if d.cfg.Strict {
+			return true, d.errNonCanonical(1, "indefinite-length map")
+		}
 		tokenSlot.Type = TMapOpen
 		tokenSlot.Length = -1
-		d.pushPhase(d.step_acceptMapIndefKey)
-		return false, nil
+		d.left = append(d.left, 0)
+		return false, d.pushPhase(d.step_acceptMapIndefKey)
 	default:
 		switch {
 		case majorByte >= cborMajorUint && majorByte < cborMajorNegInt:
 			tokenSlot.Type = TUint
+			tokenSlot.Width = argWidth(majorByte)
 			tokenSlot.Uint, err = d.decodeUint(majorByte)
 			return true, err
 		case majorByte >= cborMajorNegInt && majorByte < cborMajorBytes:
 			tokenSlot.Type = TInt
+			tokenSlot.Width = argWidth(majorByte)
 			tokenSlot.Int, err = d.decodeNegInt(majorByte)
 			return true, err
 		case majorByte >= cborMajorBytes && majorByte < cborMajorString:
@@ -232,31 +368,51 @@ func (d *Decoder) stepHelper_acceptValue(majorByte byte, tokenSlot *Token) (done
 		case majorByte >= cborMajorArray && majorByte < cborMajorMap:
 			var n int
 			n, err = d.decodeLen(majorByte)
+			if err != nil {
+				return true, err
+			}
+			if err = shared.CheckLimit("MaxContainerLength", int64(d.cfg.MaxContainerLength), int64(n)); err != nil {
+				return true, err
+			}
 			tokenSlot.Type = TArrOpen
+			tokenSlot.Width = argWidth(majorByte)
 			tokenSlot.Length = n
 			d.left = append(d.left, n)
-			d.pushPhase(d.step_acceptArrValue)
-			return false, err
+			return false, d.pushPhase(d.step_acceptArrValue)
 		case majorByte >= cborMajorMap && majorByte < cborMajorTag:
 			var n int
 			n, err = d.decodeLen(majorByte)
+			if err != nil {
+				return true, err
+			}
+			if err = shared.CheckLimit("MaxContainerLength", int64(d.cfg.MaxContainerLength), int64(n)); err != nil {
+				return true, err
+			}
 			tokenSlot.Type = TMapOpen
+			tokenSlot.Width = argWidth(majorByte)
 			tokenSlot.Length = n
 			d.left = append(d.left, n)
-			d.pushPhase(d.step_acceptMapKey)
-			return false, err
+			if d.cfg.Strict {
+				d.pushKeys()
+			}
+			return false, d.pushPhase(d.step_acceptMapKey)
 		case majorByte >= cborMajorTag && majorByte < cborMajorSimple:
 			// CBOR tags are, frankly, bonkers, and should not be used.
 			// They break isomorphism to basic standards like JSON.
-			// We'll parse basic integer tag values -- SINGLE layer only.
-			// We will NOT parse the full gamut of recursive tags: doing so
-			// would mean allowing an unbounded number of allocs *during
-			// *processing of a single token*, which is _not reasonable_.
+			// We'll parse integer tag values, and stack them up if there are
+			// several on one item (the innermost stays in `Tag`).
+			// That means allocs *during processing of a single token*,
+			// so the number of them is bounded by `MaxTagNesting`.
 			if tokenSlot.Tagged {
-				return true, fmt.Errorf("unsupported multiple tags on a single data item")
+				tokenSlot.OuterTags = append(tokenSlot.OuterTags, tokenSlot.Tag)
+				if err = shared.CheckLimit("MaxTagNesting", int64(d.cfg.MaxTagNesting), int64(len(tokenSlot.OuterTags)+1)); err != nil {
+					return true, err
+				}
+			} else {
+				tokenSlot.OuterTags = tokenSlot.OuterTags[:0]
 			}
 			tokenSlot.Tagged = true
-			tokenSlot.Tag, err = d.decodeLen(majorByte)
+			tokenSlot.Tag, err = d.decodeUint(majorByte)
 			if err != nil {
 				return true, err
 			}
@@ -267,6 +423,10 @@ func (d *Decoder) stepHelper_acceptValue(majorByte byte, tokenSlot *Token) (done
 				return true, err
 			}
 			return d.stepHelper_acceptValue(majorByte, tokenSlot)
+		case majorByte >= cborMajorSimple && majorByte < cborSigilFalse:
+			tokenSlot.Type = TSimple
+			tokenSlot.Uint = uint64(majorByte - cborMajorSimple)
+			return true, nil
 		default:
 			return true, fmt.Errorf("Invalid majorByte: 0x%x", majorByte)
 		}
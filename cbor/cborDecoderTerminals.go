@@ -4,8 +4,12 @@ import (
 	"encoding/binary"
 	"fmt"
 	"math"
+
+	"github.com/polydawn/refmt/shared"
 )
 
+const maxInt = int(^uint(0) >> 1)
+
 func (d *Decoder) decodeFloat(majorByte byte) (f float64, err error) {
 	var bs []byte
 	switch majorByte {
@@ -19,9 +23,40 @@ func (d *Decoder) decodeFloat(majorByte byte) (f float64, err error) {
 		bs, err = d.r.Readnzc(8)
 		f = math.Float64frombits(binary.BigEndian.Uint64(bs))
 	}
+	if d.cfg.Strict && err == nil {
+		switch d.cfg.FloatMode {
+		case "", FloatMode_Shortest:
+			err = d.checkFloatShortest(majorByte, bs, f)
+		case FloatMode_Float64:
+			if majorByte != cborSigilFloat64 {
+				err = d.errNonCanonical(1+len(bs), "float not encoded as float64")
+			}
+		}
+	}
 	return
 }
 
+// In strict mode, floats must be in the form `Encoder.encodeFloatShortest` would use.
+func (d *Decoder) checkFloatShortest(majorByte byte, bs []byte, f float64) error {
+	if f != f {
+		if majorByte != cborSigilFloat16 || bs[0] != 0x7e || bs[1] != 0x00 {
+			return d.errNonCanonical(1+len(bs), "NaN not encoded as 0xf97e00")
+		}
+		return nil
+	}
+	switch majorByte {
+	case cborSigilFloat32:
+		if _, ok := float32ToFloat16Exact(float32(f)); ok {
+			return d.errNonCanonical(5, "float32 could be float16")
+		}
+	case cborSigilFloat64:
+		if float64(float32(f)) == f {
+			return d.errNonCanonical(9, "float64 could be shorter")
+		}
+	}
+	return nil
+}
+
 // Decode an unsigned int.
 // Must continue to hand down the majorByte because some of its bits are either
 // packed with the value outright, or tell us how many more bytes the value fills.
@@ -50,10 +85,43 @@ func (d *Decoder) decodeUint(majorByte byte) (ui uint64, err error) {
 			err = fmt.Errorf("decodeUint: Invalid descriptor: %v", majorByte)
 			return
 		}
+		if d.cfg.Strict && err == nil {
+			err = d.checkUintShortest(v, ui)
+		}
 	}
 	return
 }
 
+// In strict mode, the additional info `v` must be the shortest that could hold `ui`.
+// (Call only after reading the following bytes, as the error offset assumes that.)
+func (d *Decoder) checkUintShortest(v byte, ui uint64) error {
+	var min uint64
+	var size int
+	switch v {
+	case 0x18:
+		min, size = 0x18, 2
+	case 0x19:
+		min, size = math.MaxUint8+1, 3
+	case 0x1a:
+		min, size = math.MaxUint16+1, 5
+	case 0x1b:
+		min, size = math.MaxUint32+1, 9
+	}
+	if ui < min {
+		return d.errNonCanonical(size, fmt.Sprintf("integer or length %d not in shortest form", ui))
+	}
+	return nil
+}
+
+// The number of bytes following the major byte that hold its argument
+// (zero if the argument is packed into the major byte itself).
+func argWidth(majorByte byte) int {
+	if v := majorByte & 0x1f; v >= 0x18 && v <= 0x1b {
+		return 1 << (v - 0x18)
+	}
+	return 0
+}
+
 // Decode a *negative* integer.
 // Note that CBOR has a very funny-shaped hole here: there is unsigned positive int,
 // and there is explicitly negative signed int... and there is no signed, positive int.
@@ -80,10 +148,21 @@ func (d *Decoder) decodeLen(majorByte byte) (i int, err error) {
 	if err != nil {
 		return 0, err
 	}
-	// TODO needs overflow check
+	if ui > uint64(maxInt) {
+		return 0, fmt.Errorf("cbor: length header %d overflows int", ui)
+	}
 	return int(ui), nil
 }
 
+// Check that a string or byte string of length `n` is within limits,
+// before we go allocating for it.
+func (d *Decoder) checkStringLen(n int) error {
+	if err := shared.CheckLimit("MaxStringLength", int64(d.cfg.MaxStringLength), int64(n)); err != nil {
+		return err
+	}
+	return d.checkTotalBytes(n)
+}
+
 // Decoding indefinite-length byte strings in cbor is actually decoding a sequence of
 // definite-length byte strings until you encounter a break.
 // Caller: use `bs[:0]` if you have something to reuse, or nil
@@ -124,6 +203,12 @@ func (d *Decoder) decodeBytesOrStringIndefinite(bs []byte, majorWanted byte) (bs
 		}
 		oldLen := len(bs)
 		newLen := oldLen + n
+		if err := shared.CheckLimit("MaxStringLength", int64(d.cfg.MaxStringLength), int64(newLen)); err != nil {
+			return bs, err
+		}
+		if err := d.checkTotalBytes(n); err != nil {
+			return bs, err
+		}
 		if newLen > cap(bs) {
 			bs2 := make([]byte, newLen, 2*cap(bs)+n)
 			copy(bs2, bs)
@@ -168,6 +253,9 @@ func (d *Decoder) decodeBytes(majorByte byte) (bs []byte, err error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := d.checkStringLen(n); err != nil {
+		return nil, err
+	}
 	return d.r.Readn(n)
 }
 
@@ -177,6 +265,9 @@ func (d *Decoder) decodeString(majorByte byte) (s string, err error) {
 	if err != nil {
 		return "", err
 	}
+	if err := d.checkStringLen(n); err != nil {
+		return "", err
+	}
 	bs, err := d.r.Readnzc(n)
 	return string(bs), err
 }
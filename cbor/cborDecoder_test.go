@@ -2,9 +2,11 @@ package cbor
 
 import (
 	"bytes"
+	"fmt"
 	"strings"
 	"testing"
 
+	"github.com/polydawn/refmt/shared"
 	. "github.com/polydawn/refmt/tok"
 )
 
@@ -48,3 +50,212 @@ func TestCborDecoder(t *testing.T) {
 		t.Logf("test %q --- done", title)
 	}
 }
+
+func TestCborDecoderTags(t *testing.T) {
+	tt := []struct {
+		title  string
+		serial []byte
+		expect []string
+	}{
+		{"single tag",
+			[]byte{0xd8, 0x2a, 0x61, 'x'},
+			[]string{`_42:<s:"x">`}},
+		{"self-describe around a tag",
+			[]byte{0xd9, 0xd9, 0xf7, 0xd8, 0x2a, 0x61, 'x'},
+			[]string{`_55799:_42:<s:"x">`}},
+		{"tag too big for an int",
+			[]byte{0xdb, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+			[]string{`_18446744073709551615:<u:1>`}},
+		{"tag stack doesn't leak to the next value",
+			[]byte{0x82, 0xc1, 0xc2, 0x01, 0xc3, 0x01},
+			[]string{`<[:2>`, `_1:_2:<u:1>`, `_3:<u:1>`, `<]>`}},
+	}
+	for _, tr := range tt {
+		d := NewDecoder(bytes.NewBuffer(tr.serial))
+		var tok Token
+		for n, expect := range tr.expect {
+			_, err := d.Step(&tok)
+			if err != nil {
+				t.Errorf("test %q step %d errored: %s", tr.title, n, err)
+			}
+			if tok.String() != expect {
+				t.Errorf("test %q step %d yielded wrong token: expected %s, got %s", tr.title, n, expect, tok)
+			}
+		}
+	}
+
+	// Untagged values after a tag stack carry none of it.
+	var buf Buffer
+	err := shared.TokenPump{
+		TokenSource: NewDecoder(bytes.NewBuffer([]byte{0x82, 0xc1, 0xc2, 0x00, 0x05})), // [1(2(0)), 5]
+		TokenSink:   shared.NewValidatingSink(&buf),
+	}.Run()
+	if err != nil {
+		t.Fatalf("tagged then untagged values: unexpected error %v", err)
+	}
+	if last := buf.Tokens[2]; len(last.OuterTags) != 0 {
+		t.Errorf("tagged then untagged values: stale OuterTags %v on %s", last.OuterTags, &last)
+	}
+}
+
+func TestCborDecoderLimits(t *testing.T) {
+	tt := []struct {
+		title     string
+		opts      DecodeOptions
+		serial    []byte
+		expectErr error
+	}{
+		{"huge bytes header",
+			DecodeOptions{MaxStringLength: 1024},
+			[]byte{0x5b, 0, 0, 0, 1, 0, 0, 0, 0},
+			shared.ErrLimitExceeded{Limit: "MaxStringLength", Max: 1024, Got: 1 << 32}},
+		{"string just under limit",
+			DecodeOptions{MaxStringLength: 3},
+			[]byte{0x63, 'a', 'b', 'c'},
+			nil},
+		{"indefinite bytes hunks adding up past limit",
+			DecodeOptions{MaxStringLength: 3},
+			[]byte{0x5f, 0x42, 1, 2, 0x42, 3, 4, 0xff},
+			shared.ErrLimitExceeded{Limit: "MaxStringLength", Max: 3, Got: 4}},
+		{"deep nesting",
+			DecodeOptions{MaxDepth: 2},
+			[]byte{0x81, 0x81, 0x81, 0x01},
+			shared.ErrLimitExceeded{Limit: "MaxDepth", Max: 2, Got: 3}},
+		{"nesting at limit",
+			DecodeOptions{MaxDepth: 2},
+			[]byte{0x81, 0x9f, 0x01, 0xff},
+			nil},
+		{"huge array header",
+			DecodeOptions{MaxContainerLength: 2},
+			[]byte{0x9a, 0x7f, 0xff, 0xff, 0xff},
+			shared.ErrLimitExceeded{Limit: "MaxContainerLength", Max: 2, Got: 0x7fffffff}},
+		{"long indefinite map",
+			DecodeOptions{MaxContainerLength: 1},
+			[]byte{0xbf, 0x61, 'a', 0x01, 0x61, 'b', 0x02, 0xff},
+			shared.ErrLimitExceeded{Limit: "MaxContainerLength", Max: 1, Got: 2}},
+		{"nested tags",
+			DecodeOptions{MaxTagNesting: 2},
+			[]byte{0xc1, 0xc2, 0xc3, 0x01},
+			shared.ErrLimitExceeded{Limit: "MaxTagNesting", Max: 2, Got: 3}},
+		{"nested tags at limit",
+			DecodeOptions{MaxTagNesting: 2},
+			[]byte{0x82, 0xc1, 0xc2, 0x01, 0xc3, 0x01},
+			nil},
+		{"total bytes",
+			DecodeOptions{MaxTotalBytes: 4},
+			[]byte{0x84, 0x01, 0x02, 0x03, 0x04},
+			shared.ErrLimitExceeded{Limit: "MaxTotalBytes", Max: 4, Got: 5}},
+		{"string past total bytes",
+			DecodeOptions{MaxTotalBytes: 4},
+			[]byte{0x81, 0x64, 'a', 'b', 'c', 'd'},
+			shared.ErrLimitExceeded{Limit: "MaxTotalBytes", Max: 4, Got: 6}},
+	}
+	for _, tr := range tt {
+		d := NewDecoderWithOptions(tr.opts, bytes.NewBuffer(tr.serial))
+		var tok Token
+		var err error
+		for done := false; !done; {
+			done, err = d.Step(&tok)
+		}
+		if err != tr.expectErr {
+			t.Errorf("test %q: expected error %v, got %v", tr.title, tr.expectErr, err)
+		}
+	}
+}
+
+func TestCborDecoderStrict(t *testing.T) {
+	tt := []struct {
+		title     string
+		serial    []byte
+		expectErr error
+	}{
+		{"canonical map",
+			[]byte{0xa3, 0x0a, 0x03, 0x20, 0x02, 0x61, 'a', 0xf9, 0x3e, 0x00},
+			nil},
+		{"canonical nested",
+			[]byte{0x82, 0xa1, 0x61, 'y', 0x18, 0x18, 0xc1, 0x1a, 0x00, 0x01, 0x00, 0x00},
+			nil},
+		{"non-minimal int",
+			[]byte{0x18, 0x05},
+			&ErrNonCanonical{0, "integer or length 5 not in shortest form"}},
+		{"non-minimal int in array",
+			[]byte{0x82, 0x01, 0x19, 0x00, 0x10},
+			&ErrNonCanonical{2, "integer or length 16 not in shortest form"}},
+		{"non-minimal string length",
+			[]byte{0x78, 0x01, 'a'},
+			&ErrNonCanonical{0, "integer or length 1 not in shortest form"}},
+		{"non-minimal tag",
+			[]byte{0x81, 0xd8, 0x01, 0x00},
+			&ErrNonCanonical{1, "integer or length 1 not in shortest form"}},
+		{"indefinite array",
+			[]byte{0x81, 0x9f, 0xff},
+			&ErrNonCanonical{1, "indefinite-length array"}},
+		{"indefinite string",
+			[]byte{0x7f, 0x61, 'a', 0xff},
+			&ErrNonCanonical{0, "indefinite-length string"}},
+		{"unsorted keys",
+			[]byte{0xa2, 0x61, 'b', 0x01, 0x61, 'a', 0x02},
+			&ErrNonCanonical{4, "map keys not in bytewise sorted order"}},
+		{"length-first key order",
+			[]byte{0xa2, 0x62, 'a', 'a', 0x01, 0x61, 'b', 0x02},
+			&ErrNonCanonical{5, "map keys not in bytewise sorted order"}},
+		{"duplicate keys",
+			[]byte{0xa2, 0x61, 'a', 0x01, 0x61, 'a', 0x02},
+			&ErrNonCanonical{4, "duplicate map key"}},
+		{"composite key",
+			[]byte{0xa1, 0x80, 0x01},
+			&ErrNonCanonical{1, "map key is a map or array"}},
+		{"float32 could be float16",
+			[]byte{0xfa, 0x3f, 0xc0, 0x00, 0x00},
+			&ErrNonCanonical{0, "float32 could be float16"}},
+		{"float64 could be shorter",
+			[]byte{0x81, 0xfb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0},
+			&ErrNonCanonical{1, "float64 could be shorter"}},
+		{"float64 needed",
+			[]byte{0xfb, 0x3f, 0xf1, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a},
+			nil},
+		{"noncanonical NaN",
+			[]byte{0xf9, 0x7e, 0x01},
+			&ErrNonCanonical{0, "NaN not encoded as 0xf97e00"}},
+	}
+	for _, tr := range tt {
+		d := NewDecoderWithOptions(DecodeOptions{Strict: true}, bytes.NewBuffer(tr.serial))
+		var tok Token
+		var err error
+		for done := false; !done; {
+			done, err = d.Step(&tok)
+		}
+		if fmt.Sprint(err) != fmt.Sprint(tr.expectErr) {
+			t.Errorf("test %q: expected error %v, got %v", tr.title, tr.expectErr, err)
+		}
+	}
+}
+
+func TestCborDecoderStrictFloat64(t *testing.T) {
+	tt := []struct {
+		title     string
+		serial    []byte
+		expectErr error
+	}{
+		{"float64",
+			[]byte{0xfb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0},
+			nil},
+		{"float16",
+			[]byte{0x81, 0xf9, 0x3e, 0x00},
+			&ErrNonCanonical{1, "float not encoded as float64"}},
+		{"float32",
+			[]byte{0xfa, 0x3d, 0xcc, 0xcc, 0xcd},
+			&ErrNonCanonical{0, "float not encoded as float64"}},
+	}
+	for _, tr := range tt {
+		d := NewDecoderWithOptions(DecodeOptions{Strict: true, FloatMode: FloatMode_Float64}, bytes.NewBuffer(tr.serial))
+		var tok Token
+		var err error
+		for done := false; !done; {
+			done, err = d.Step(&tok)
+		}
+		if fmt.Sprint(err) != fmt.Sprint(tr.expectErr) {
+			t.Errorf("test %q: expected error %v, got %v", tr.title, tr.expectErr, err)
+		}
+	}
+}
@@ -1,34 +1,53 @@
 package cbor
 
 import (
+	"fmt"
 	"io"
 
+	"github.com/polydawn/refmt/shared"
 	. "github.com/polydawn/refmt/tok"
 )
 
 type Encoder struct {
-	w quickWriter
+	cfg EncodeOptions
+	w   quickWriter // Where we're writing right now: either `out`, or the innermost frame's buffer.
+	out quickWriter
 
 	stack   []encoderPhase // When empty, and step returns done, all done.
 	current encoderPhase   // Shortcut to end of stack.
 	// Note unlike decoder, we need no statekeeping space for definite-len map and array.
 
+	frames []*detFrame // Buffers for each open map and array.  Only used in deterministic mode.
+
 	spareBytes []byte
 }
 
 func NewEncoder(w io.Writer) (d *Encoder) {
+	return NewEncoderWithOptions(EncodeOptions{}, w)
+}
+
+func NewEncoderWithOptions(cfg EncodeOptions, w io.Writer) (d *Encoder) {
+	switch cfg.FloatMode {
+	case "", FloatMode_Float64, FloatMode_Shortest, FloatMode_Float32:
+	default:
+		panic(fmt.Errorf("invalid float mode %q", cfg.FloatMode))
+	}
 	d = &Encoder{
-		w:          newQuickWriterStream(w),
+		cfg:        cfg,
+		out:        newQuickWriterStream(w),
 		stack:      make([]encoderPhase, 0, 10),
 		current:    phase_anyExpectValue,
 		spareBytes: make([]byte, 8),
 	}
+	d.w = d.out
 	return
 }
 
 func (d *Encoder) Reset() {
 	d.stack = d.stack[0:0]
 	d.current = phase_anyExpectValue
+	d.frames = d.frames[0:0]
+	d.w = d.out
 }
 
 type encoderPhase byte
@@ -72,6 +91,9 @@ func (d *Encoder) Step(tokenSlot *Token) (done bool, err error) {
 		far the shorter volume of code to write.
 	*/
 	phase := d.current
+	if len(d.frames) > 0 {
+		d.frames[len(d.frames)-1].mark(phase, tokenSlot.Type)
+	}
 	switch tokenSlot.Type {
 	case TMapOpen:
 		switch phase {
@@ -79,31 +101,39 @@ func (d *Encoder) Step(tokenSlot *Token) (done bool, err error) {
 			d.current -= 1
 			fallthrough
 		case phase_anyExpectValue, phase_arrDefExpectValueOrEnd, phase_arrIndefExpectValueOrEnd:
-			if tokenSlot.Tagged {
-				d.emitMajorPlusLen(cborMajorTag, uint64(tokenSlot.Tag))
-			}
-			if tokenSlot.Length >= 0 {
+			d.emitTags(tokenSlot)
+			if d.cfg.Deterministic {
+				d.pushPhase(phase_mapDefExpectKeyOrEnd)
+				d.pushFrame()
+			} else if tokenSlot.Length >= 0 {
 				d.pushPhase(phase_mapDefExpectKeyOrEnd)
-				d.emitMajorPlusLen(cborMajorMap, uint64(tokenSlot.Length))
+				if err := d.emitMajorPlusWidth(cborMajorMap, uint64(tokenSlot.Length), d.widthOf(tokenSlot)); err != nil {
+					return true, err
+				}
 			} else {
 				d.pushPhase(phase_mapIndefExpectKeyOrEnd)
 				d.w.writen1(cborSigilIndefiniteMap)
 			}
 			return false, d.w.checkErr()
 		case phase_mapDefExpectKeyOrEnd, phase_mapIndefExpectKeyOrEnd:
-			return true, &ErrInvalidTokenStream{Got: *tokenSlot, Acceptable: tokenTypesForKey}
+			return true, shared.ErrMalformedTokenStream{Got: tokenSlot.Type, Expected: phase.expected()}
 		default:
 			panic("unreachable phase")
 		}
 	case TMapClose:
 		switch phase {
 		case phase_mapDefExpectKeyOrEnd:
+			if d.cfg.Deterministic {
+				if err := d.popFrame(cborMajorMap); err != nil {
+					return true, err
+				}
+			}
 			return d.popPhase(), nil
 		case phase_mapIndefExpectKeyOrEnd:
 			d.w.writen1(cborSigilBreak)
 			return d.popPhase(), d.w.checkErr()
 		case phase_anyExpectValue, phase_mapDefExpectValue, phase_mapIndefExpectValue, phase_arrDefExpectValueOrEnd, phase_arrIndefExpectValueOrEnd:
-			return true, &ErrInvalidTokenStream{Got: *tokenSlot, Acceptable: tokenTypesForValue}
+			return true, shared.ErrMalformedTokenStream{Got: tokenSlot.Type, Expected: phase.expected()}
 		default:
 			panic("unreachable phase")
 		}
@@ -113,33 +143,41 @@ func (d *Encoder) Step(tokenSlot *Token) (done bool, err error) {
 			d.current -= 1
 			fallthrough
 		case phase_anyExpectValue, phase_arrDefExpectValueOrEnd, phase_arrIndefExpectValueOrEnd:
-			if tokenSlot.Tagged {
-				d.emitMajorPlusLen(cborMajorTag, uint64(tokenSlot.Tag))
-			}
-			if tokenSlot.Length >= 0 {
+			d.emitTags(tokenSlot)
+			if d.cfg.Deterministic {
 				d.pushPhase(phase_arrDefExpectValueOrEnd)
-				d.emitMajorPlusLen(cborMajorArray, uint64(tokenSlot.Length))
+				d.pushFrame()
+			} else if tokenSlot.Length >= 0 {
+				d.pushPhase(phase_arrDefExpectValueOrEnd)
+				if err := d.emitMajorPlusWidth(cborMajorArray, uint64(tokenSlot.Length), d.widthOf(tokenSlot)); err != nil {
+					return true, err
+				}
 			} else {
 				d.pushPhase(phase_arrIndefExpectValueOrEnd)
 				d.w.writen1(cborSigilIndefiniteArray)
 			}
 			return false, d.w.checkErr()
 		case phase_mapDefExpectKeyOrEnd, phase_mapIndefExpectKeyOrEnd:
-			return true, &ErrInvalidTokenStream{Got: *tokenSlot, Acceptable: tokenTypesForKey}
+			return true, shared.ErrMalformedTokenStream{Got: tokenSlot.Type, Expected: phase.expected()}
 		default:
 			panic("unreachable phase")
 		}
 	case TArrClose:
 		switch phase {
 		case phase_arrDefExpectValueOrEnd:
+			if d.cfg.Deterministic {
+				if err := d.popFrame(cborMajorArray); err != nil {
+					return true, err
+				}
+			}
 			return d.popPhase(), nil
 		case phase_arrIndefExpectValueOrEnd:
 			d.w.writen1(cborSigilBreak)
 			return d.popPhase(), d.w.checkErr()
 		case phase_anyExpectValue, phase_mapDefExpectValue, phase_mapIndefExpectValue:
-			return true, &ErrInvalidTokenStream{Got: *tokenSlot, Acceptable: tokenTypesForValue}
+			return true, shared.ErrMalformedTokenStream{Got: tokenSlot.Type, Expected: phase.expected()}
 		case phase_mapDefExpectKeyOrEnd, phase_mapIndefExpectKeyOrEnd:
-			return true, &ErrInvalidTokenStream{Got: *tokenSlot, Acceptable: tokenTypesForKey}
+			return true, shared.ErrMalformedTokenStream{Got: tokenSlot.Type, Expected: phase.expected()}
 		default:
 			panic("unreachable phase")
 		}
@@ -149,13 +187,41 @@ func (d *Encoder) Step(tokenSlot *Token) (done bool, err error) {
 			d.current -= 1
 			fallthrough
 		case phase_anyExpectValue, phase_arrDefExpectValueOrEnd, phase_arrIndefExpectValueOrEnd:
-			if tokenSlot.Tagged {
-				d.emitMajorPlusLen(cborMajorTag, uint64(tokenSlot.Tag))
-			}
+			d.emitTags(tokenSlot)
 			d.w.writen1(cborSigilNil)
 			return phase == phase_anyExpectValue, d.w.checkErr()
 		case phase_mapDefExpectKeyOrEnd, phase_mapIndefExpectKeyOrEnd:
-			return true, &ErrInvalidTokenStream{Got: *tokenSlot, Acceptable: tokenTypesForKey}
+			return true, shared.ErrMalformedTokenStream{Got: tokenSlot.Type, Expected: phase.expected()}
+		default:
+			panic("unreachable phase")
+		}
+	case TUndefined: // terminal value; not accepted as map key.
+		switch phase {
+		case phase_mapDefExpectValue, phase_mapIndefExpectValue:
+			d.current -= 1
+			fallthrough
+		case phase_anyExpectValue, phase_arrDefExpectValueOrEnd, phase_arrIndefExpectValueOrEnd:
+			d.emitTags(tokenSlot)
+			d.w.writen1(cborSigilUndefined)
+			return phase == phase_anyExpectValue, d.w.checkErr()
+		case phase_mapDefExpectKeyOrEnd, phase_mapIndefExpectKeyOrEnd:
+			return true, shared.ErrMalformedTokenStream{Got: tokenSlot.Type, Expected: phase.expected()}
+		default:
+			panic("unreachable phase")
+		}
+	case TSimple: // terminal value; not accepted as map key.
+		switch phase {
+		case phase_mapDefExpectValue, phase_mapIndefExpectValue:
+			d.current -= 1
+			fallthrough
+		case phase_anyExpectValue, phase_arrDefExpectValueOrEnd, phase_arrIndefExpectValueOrEnd:
+			d.emitTags(tokenSlot)
+			if err := d.encodeSimple(tokenSlot.Uint); err != nil {
+				return true, err
+			}
+			return phase == phase_anyExpectValue, d.w.checkErr()
+		case phase_mapDefExpectKeyOrEnd, phase_mapIndefExpectKeyOrEnd:
+			return true, shared.ErrMalformedTokenStream{Got: tokenSlot.Type, Expected: phase.expected()}
 		default:
 			panic("unreachable phase")
 		}
@@ -174,9 +240,7 @@ func (d *Encoder) Step(tokenSlot *Token) (done bool, err error) {
 		}
 	emitStr:
 		{
-			if tokenSlot.Tagged {
-				d.emitMajorPlusLen(cborMajorTag, uint64(tokenSlot.Tag))
-			}
+			d.emitTags(tokenSlot)
 			d.encodeString(tokenSlot.Str)
 			return phase == phase_anyExpectValue, d.w.checkErr()
 		}
@@ -186,13 +250,11 @@ func (d *Encoder) Step(tokenSlot *Token) (done bool, err error) {
 			d.current -= 1
 			fallthrough
 		case phase_anyExpectValue, phase_arrDefExpectValueOrEnd, phase_arrIndefExpectValueOrEnd:
-			if tokenSlot.Tagged {
-				d.emitMajorPlusLen(cborMajorTag, uint64(tokenSlot.Tag))
-			}
+			d.emitTags(tokenSlot)
 			d.encodeBytes(tokenSlot.Bytes)
 			return phase == phase_anyExpectValue, d.w.checkErr()
 		case phase_mapDefExpectKeyOrEnd, phase_mapIndefExpectKeyOrEnd:
-			return true, &ErrInvalidTokenStream{Got: *tokenSlot, Acceptable: tokenTypesForKey}
+			return true, shared.ErrMalformedTokenStream{Got: tokenSlot.Type, Expected: phase.expected()}
 		default:
 			panic("unreachable phase")
 		}
@@ -202,13 +264,11 @@ func (d *Encoder) Step(tokenSlot *Token) (done bool, err error) {
 			d.current -= 1
 			fallthrough
 		case phase_anyExpectValue, phase_arrDefExpectValueOrEnd, phase_arrIndefExpectValueOrEnd:
-			if tokenSlot.Tagged {
-				d.emitMajorPlusLen(cborMajorTag, uint64(tokenSlot.Tag))
-			}
+			d.emitTags(tokenSlot)
 			d.encodeBool(tokenSlot.Bool)
 			return phase == phase_anyExpectValue, d.w.checkErr()
 		case phase_mapDefExpectKeyOrEnd, phase_mapIndefExpectKeyOrEnd:
-			return true, &ErrInvalidTokenStream{Got: *tokenSlot, Acceptable: tokenTypesForKey}
+			return true, shared.ErrMalformedTokenStream{Got: tokenSlot.Type, Expected: phase.expected()}
 		default:
 			panic("unreachable phase")
 		}
@@ -227,10 +287,10 @@ func (d *Encoder) Step(tokenSlot *Token) (done bool, err error) {
 		}
 	emitInt:
 		{
-			if tokenSlot.Tagged {
-				d.emitMajorPlusLen(cborMajorTag, uint64(tokenSlot.Tag))
+			d.emitTags(tokenSlot)
+			if err := d.encodeInt64(tokenSlot.Int, d.widthOf(tokenSlot)); err != nil {
+				return true, err
 			}
-			d.encodeInt64(tokenSlot.Int)
 			return phase == phase_anyExpectValue, d.w.checkErr()
 		}
 	case TUint: // terminal value; YES, accepted as map key.
@@ -248,10 +308,10 @@ func (d *Encoder) Step(tokenSlot *Token) (done bool, err error) {
 		}
 	emitUint:
 		{
-			if tokenSlot.Tagged {
-				d.emitMajorPlusLen(cborMajorTag, uint64(tokenSlot.Tag))
+			d.emitTags(tokenSlot)
+			if err := d.encodeUint64(tokenSlot.Uint, d.widthOf(tokenSlot)); err != nil {
+				return true, err
 			}
-			d.encodeUint64(tokenSlot.Uint)
 			return phase == phase_anyExpectValue, d.w.checkErr()
 		}
 	case TFloat64: // terminal value; not accepted as map key.
@@ -260,13 +320,13 @@ func (d *Encoder) Step(tokenSlot *Token) (done bool, err error) {
 			d.current -= 1
 			fallthrough
 		case phase_anyExpectValue, phase_arrDefExpectValueOrEnd, phase_arrIndefExpectValueOrEnd:
-			if tokenSlot.Tagged {
-				d.emitMajorPlusLen(cborMajorTag, uint64(tokenSlot.Tag))
+			d.emitTags(tokenSlot)
+			if err := d.encodeFloat64(tokenSlot.Float64, d.widthOf(tokenSlot)); err != nil {
+				return true, err
 			}
-			d.encodeFloat64(tokenSlot.Float64)
 			return phase == phase_anyExpectValue, d.w.checkErr()
 		case phase_mapDefExpectKeyOrEnd, phase_mapIndefExpectKeyOrEnd:
-			return true, &ErrInvalidTokenStream{Got: *tokenSlot, Acceptable: tokenTypesForKey}
+			return true, shared.ErrMalformedTokenStream{Got: tokenSlot.Type, Expected: phase.expected()}
 		default:
 			panic("unreachable phase")
 		}
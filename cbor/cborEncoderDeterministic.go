@@ -0,0 +1,116 @@
+package cbor
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	. "github.com/polydawn/refmt/tok"
+)
+
+// detFrame buffers the content of one map or array in deterministic mode.
+// The header can't be written until we know the final length,
+// and map entries can't be written until we can sort them,
+// so everything goes to the buffer until the close token arrives.
+type detFrame struct {
+	w       quickWriterBuffer
+	n       int        // Number of values seen so far (arrays only).
+	entries []detEntry // Boundaries of each entry in the buffer (maps only).
+}
+
+type detEntry struct {
+	start  int // Offset of the key.
+	keyEnd int // Offset of the value.
+	end    int // Offset of the next entry; only filled in at the end.
+}
+
+// Called by Step before handling each token, with the phase of the container
+// this frame belongs to, so we can record where each entry begins.
+func (f *detFrame) mark(phase encoderPhase, tt TokenType) {
+	switch phase {
+	case phase_mapDefExpectKeyOrEnd:
+		if tt != TMapClose {
+			f.entries = append(f.entries, detEntry{start: len(f.w.buf)})
+		}
+	case phase_mapDefExpectValue:
+		f.entries[len(f.entries)-1].keyEnd = len(f.w.buf)
+	case phase_arrDefExpectValueOrEnd:
+		if tt != TArrClose {
+			f.n++
+		}
+	}
+}
+
+func (d *Encoder) pushFrame() {
+	n := len(d.frames)
+	if n < cap(d.frames) {
+		d.frames = d.frames[:n+1]
+	} else {
+		d.frames = append(d.frames, nil)
+	}
+	f := d.frames[n]
+	if f == nil {
+		f = &detFrame{}
+		d.frames[n] = f
+	}
+	f.w.buf = f.w.buf[:0]
+	f.n = 0
+	f.entries = f.entries[:0]
+	d.w = &f.w
+}
+
+// Pop the innermost frame, and write it out (with a definite length header,
+// and sorted entries if a map) to the next frame out, or the real output.
+func (d *Encoder) popFrame(majorByte byte) error {
+	n := len(d.frames) - 1
+	f := d.frames[n]
+	d.frames = d.frames[:n]
+	if n > 0 {
+		d.w = &d.frames[n-1].w
+	} else {
+		d.w = d.out
+	}
+
+	buf := f.w.buf
+	if majorByte == cborMajorArray {
+		d.emitMajorPlusLen(majorByte, uint64(f.n))
+		d.w.writeb(buf)
+		return d.w.checkErr()
+	}
+
+	entries := f.entries
+	for i := range entries {
+		if i+1 < len(entries) {
+			entries[i].end = entries[i+1].start
+		} else {
+			entries[i].end = len(buf)
+		}
+	}
+	sort.Sort(detEntry_byKey{entries, buf})
+	for i := 1; i < len(entries); i++ {
+		k := buf[entries[i].start:entries[i].keyEnd]
+		if bytes.Equal(buf[entries[i-1].start:entries[i-1].keyEnd], k) {
+			return fmt.Errorf("cbor: duplicate map key (encoded as %x) not permitted in deterministic encoding", k)
+		}
+	}
+	d.emitMajorPlusLen(majorByte, uint64(len(entries)))
+	for _, e := range entries {
+		d.w.writeb(buf[e.start:e.end])
+	}
+	return d.w.checkErr()
+}
+
+// Sorts entries by the bytewise order of their encoded keys (rfc8949 § 4.2.1).
+type detEntry_byKey struct {
+	entries []detEntry
+	buf     []byte
+}
+
+func (x detEntry_byKey) Len() int      { return len(x.entries) }
+func (x detEntry_byKey) Swap(i, j int) { x.entries[i], x.entries[j] = x.entries[j], x.entries[i] }
+func (x detEntry_byKey) Less(i, j int) bool {
+	return bytes.Compare(
+		x.buf[x.entries[i].start:x.entries[i].keyEnd],
+		x.buf[x.entries[j].start:x.entries[j].keyEnd],
+	) < 0
+}
@@ -2,7 +2,10 @@ package cbor
 
 import (
 	"encoding/binary"
+	"fmt"
 	"math"
+
+	. "github.com/polydawn/refmt/tok"
 )
 
 func (d *Encoder) emitLen(majorByte byte, length int) {
@@ -32,10 +35,87 @@ func (d *Encoder) emitMajorPlusLen(majorByte byte, v uint64) {
 	}
 }
 
+// Like `emitMajorPlusLen`, but the argument takes `width` bytes, if that's
+// nonzero (even if fewer would do).  Errors if the argument doesn't fit.
+func (d *Encoder) emitMajorPlusWidth(majorByte byte, v uint64, width int) error {
+	switch width {
+	case 0:
+		d.emitMajorPlusLen(majorByte, v)
+		return nil
+	case 1:
+		if v <= math.MaxUint8 {
+			d.w.writen2(majorByte+0x18, uint8(v))
+			return nil
+		}
+	case 2:
+		if v <= math.MaxUint16 {
+			d.w.writen1(majorByte + 0x19)
+			d.spareBytes = d.spareBytes[:2]
+			binary.BigEndian.PutUint16(d.spareBytes, uint16(v))
+			d.w.writeb(d.spareBytes)
+			return nil
+		}
+	case 4:
+		if v <= math.MaxUint32 {
+			d.w.writen1(majorByte + 0x1a)
+			d.spareBytes = d.spareBytes[:4]
+			binary.BigEndian.PutUint32(d.spareBytes, uint32(v))
+			d.w.writeb(d.spareBytes)
+			return nil
+		}
+	case 8:
+		d.w.writen1(majorByte + 0x1b)
+		d.spareBytes = d.spareBytes[:8]
+		binary.BigEndian.PutUint64(d.spareBytes, v)
+		d.w.writeb(d.spareBytes)
+		return nil
+	default:
+		return fmt.Errorf("cbor: invalid width %d; must be 1, 2, 4, or 8", width)
+	}
+	return fmt.Errorf("cbor: %d does not fit in %d bytes", v, width)
+}
+
+// The width a token asks for, or zero to leave it to us.
+// We only take any notice if `PreserveWidths` is set; and deterministic
+// mode always chooses for itself, since it must use the shortest forms.
+func (d *Encoder) widthOf(tok *Token) int {
+	if !d.cfg.PreserveWidths || d.cfg.Deterministic {
+		return 0
+	}
+	return tok.Width
+}
+
+// Emit the tag headers for a token, if it's tagged: outermost first.
+func (d *Encoder) emitTags(tok *Token) {
+	if !tok.Tagged {
+		return
+	}
+	for _, tag := range tok.OuterTags {
+		d.emitMajorPlusLen(cborMajorTag, tag)
+	}
+	d.emitMajorPlusLen(cborMajorTag, tok.Tag)
+}
+
 func (d *Encoder) encodeNull() {
 	d.w.writen1(cborSigilNil)
 }
 
+// Simple values 20 through 23 are false, true, null, and undefined,
+// which have their own token types; 24 through 31 are reserved.
+func (d *Encoder) encodeSimple(v uint64) error {
+	switch {
+	case v < 20:
+		d.w.writen1(cborMajorSimple + byte(v))
+	case v < 32:
+		return fmt.Errorf("cbor: cannot encode simple value %d; it is reserved or has its own token type", v)
+	case v <= math.MaxUint8:
+		d.w.writen2(cborSigilSimple8, byte(v))
+	default:
+		return fmt.Errorf("cbor: cannot encode simple value %d; out of range", v)
+	}
+	return nil
+}
+
 func (d *Encoder) encodeString(s string) {
 	d.emitMajorPlusLen(cborMajorString, uint64(len(s)))
 	d.w.writestr(s)
@@ -54,24 +134,142 @@ func (d *Encoder) encodeBool(b bool) {
 	}
 }
 
-func (d *Encoder) encodeInt64(v int64) {
+func (d *Encoder) encodeInt64(v int64, width int) error {
 	if v >= 0 {
-		d.emitMajorPlusLen(cborMajorUint, uint64(v))
-	} else {
-		d.emitMajorPlusLen(cborMajorNegInt, uint64(-1-v))
+		return d.emitMajorPlusWidth(cborMajorUint, uint64(v), width)
+	}
+	return d.emitMajorPlusWidth(cborMajorNegInt, uint64(-1-v), width)
+}
+
+func (d *Encoder) encodeUint64(v uint64, width int) error {
+	return d.emitMajorPlusWidth(cborMajorUint, v, width)
+}
+
+// Floats are emitted as `FloatMode` says if it's set,
+// and otherwise with the given width if that's nonzero.
+func (d *Encoder) encodeFloat64(v float64, width int) error {
+	if width != 0 && d.cfg.FloatMode == "" {
+		return d.encodeFloatWidth(v, width)
+	}
+	mode := d.cfg.FloatMode
+	if d.cfg.Deterministic && mode == "" {
+		mode = FloatMode_Shortest
 	}
+	switch mode {
+	case "", FloatMode_Float64:
+		// Emit the full 64-bit style.  The CBOR spec permits this.
+		d.w.writen1(cborSigilFloat64)
+		d.spareBytes = d.spareBytes[:8]
+		binary.BigEndian.PutUint64(d.spareBytes, math.Float64bits(v))
+		d.w.writeb(d.spareBytes)
+	case FloatMode_Shortest:
+		d.encodeFloatShortest(v)
+	case FloatMode_Float32:
+		// Finite values too big for a float32 would round to infinity;
+		// those are kept as float64 instead.
+		if f32 := float32(v); !math.IsInf(float64(f32), 0) || math.IsInf(v, 0) {
+			v = float64(f32)
+		}
+		d.encodeFloatShortest(v)
+	}
+	return nil
 }
 
-func (d *Encoder) encodeUint64(v uint64) {
-	d.emitMajorPlusLen(cborMajorUint, v)
+// encodeFloatWidth emits a float of `width` bytes, if that holds the value exactly.
+// (NaNs fit any width; in a float16 they're the canonical quiet NaN.)
+func (d *Encoder) encodeFloatWidth(v float64, width int) error {
+	f32 := float32(v)
+	exact := v != v || float64(f32) == v
+	switch width {
+	case 2:
+		if v != v {
+			d.w.writen1(cborSigilFloat16)
+			d.w.writen2(0x7e, 0x00)
+			return nil
+		}
+		if f16, ok := float32ToFloat16Exact(f32); exact && ok {
+			d.w.writen1(cborSigilFloat16)
+			d.w.writen2(byte(f16>>8), byte(f16))
+			return nil
+		}
+	case 4:
+		if exact {
+			d.w.writen1(cborSigilFloat32)
+			d.spareBytes = d.spareBytes[:4]
+			binary.BigEndian.PutUint32(d.spareBytes, math.Float32bits(f32))
+			d.w.writeb(d.spareBytes)
+			return nil
+		}
+	case 8:
+		d.w.writen1(cborSigilFloat64)
+		d.spareBytes = d.spareBytes[:8]
+		binary.BigEndian.PutUint64(d.spareBytes, math.Float64bits(v))
+		d.w.writeb(d.spareBytes)
+		return nil
+	default:
+		return fmt.Errorf("cbor: invalid float width %d; must be 2, 4, or 8", width)
+	}
+	return fmt.Errorf("cbor: float %v does not fit exactly in %d bytes", v, width)
 }
 
-func (d *Encoder) encodeFloat64(v float64) {
-	// Can we pack it into 32?  No idea: float precision is fraught with peril.
-	// See https://play.golang.org/p/u9sN6x0kk6
-	// So we *only* emit the full 64-bit style.  The CBOR spec permits this.
-	d.w.writen1(cborSigilFloat64)
-	d.spareBytes = d.spareBytes[:8]
-	binary.BigEndian.PutUint64(d.spareBytes, math.Float64bits(v))
+// encodeFloatShortest emits the smallest of float16, float32, or float64
+// which holds the value exactly.
+// (Is that safe?  Float precision is fraught with peril -- see
+// https://play.golang.org/p/u9sN6x0kk6 -- but we only shorten when
+// converting back yields the identical value, so nothing is lost.)
+// NaNs are all emitted as the one canonical float16 quiet NaN,
+// as rfc8949 § 4.2.2 suggests.
+func (d *Encoder) encodeFloatShortest(v float64) {
+	if v != v {
+		d.w.writen1(cborSigilFloat16)
+		d.w.writen2(0x7e, 0x00)
+		return
+	}
+	f32 := float32(v)
+	if float64(f32) != v {
+		d.w.writen1(cborSigilFloat64)
+		d.spareBytes = d.spareBytes[:8]
+		binary.BigEndian.PutUint64(d.spareBytes, math.Float64bits(v))
+		d.w.writeb(d.spareBytes)
+		return
+	}
+	if f16, ok := float32ToFloat16Exact(f32); ok {
+		d.w.writen1(cborSigilFloat16)
+		d.w.writen2(byte(f16>>8), byte(f16))
+		return
+	}
+	d.w.writen1(cborSigilFloat32)
+	d.spareBytes = d.spareBytes[:4]
+	binary.BigEndian.PutUint32(d.spareBytes, math.Float32bits(f32))
 	d.w.writeb(d.spareBytes)
 }
+
+// float32ToFloat16Exact returns the IEEE 754 half-precision bits for f,
+// and true, if (and only if) the conversion loses nothing.
+// NaNs are not handled.
+func float32ToFloat16Exact(f float32) (uint16, bool) {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23) & 0xff
+	mant := bits & 0x7fffff
+	switch {
+	case exp == 0xff: // Infinity.
+		return sign | 0x7c00, mant == 0
+	case exp == 0 && mant == 0: // Zero.
+		return sign, true
+	case exp == 0: // Subnormal float32; far too small for float16.
+		return 0, false
+	}
+	e := exp - 127
+	switch {
+	case e > 15:
+		return 0, false
+	case e >= -14: // Normal float16, if no mantissa bits are lost.
+		return sign | uint16(e+15)<<10 | uint16(mant>>13), mant&0x1fff == 0
+	case e >= -24: // Subnormal float16: value is m*2^-24.
+		m := (mant | 0x800000) >> uint(-(e + 1))
+		return sign | uint16(m), (mant|0x800000)&(1<<uint(-(e+1))-1) == 0
+	default:
+		return 0, false
+	}
+}
@@ -2,10 +2,14 @@ package cbor
 
 import (
 	"bytes"
+	"fmt"
+	"math"
 	"strings"
 	"testing"
 
+	"github.com/polydawn/refmt/shared"
 	. "github.com/polydawn/refmt/testutil"
+	. "github.com/polydawn/refmt/tok"
 )
 
 func TestCborEncoder(t *testing.T) {
@@ -47,3 +51,277 @@ func TestCborEncoder(t *testing.T) {
 		t.Logf("test %q --- done", title)
 	}
 }
+
+func TestCborEncoderDeterministic(t *testing.T) {
+	tt := []struct {
+		title     string
+		tokens    []Token
+		serial    []byte
+		expectErr string
+	}{
+		{"indefinite map with unsorted keys",
+			[]Token{
+				{Type: TMapOpen, Length: -1},
+				TokStr("bc"), TokInt(1),
+				TokStr("b"), TokInt(2),
+				TokStr("a"), TokInt(3),
+				{Type: TMapClose},
+			},
+			bcat(b(0xa3), b(0x61), []byte("a"), b(0x03), b(0x61), []byte("b"), b(0x02), b(0x62), []byte("bc"), b(0x01)),
+			""},
+		{"mixed key types sort bytewise",
+			[]Token{
+				{Type: TMapOpen, Length: 3},
+				TokStr("a"), TokInt(1),
+				TokInt(-1), TokInt(2),
+				TokInt(10), TokInt(3),
+				{Type: TMapClose},
+			},
+			bcat(b(0xa3), b(0x0a), b(0x03), b(0x20), b(0x02), b(0x61), []byte("a"), b(0x01)),
+			""},
+		{"nested indefinite containers",
+			[]Token{
+				{Type: TArrOpen, Length: -1},
+				{Type: TMapOpen, Length: -1},
+				TokStr("z"), {Type: TArrOpen, Length: -1}, {Type: TArrClose},
+				TokStr("y"), TokInt(1),
+				{Type: TMapClose},
+				TokInt(2),
+				{Type: TArrClose},
+			},
+			bcat(b(0x82), b(0xa2), b(0x61), []byte("y"), b(0x01), b(0x61), []byte("z"), b(0x80), b(0x02)),
+			""},
+		{"tagged map key",
+			[]Token{
+				{Type: TMapOpen, Length: 2},
+				{Type: TString, Str: "b", Tagged: true, Tag: 1}, TokInt(1),
+				TokStr("c"), TokInt(2),
+				{Type: TMapClose},
+			},
+			bcat(b(0xa2), b(0x61), []byte("c"), b(0x02), b(0xc1), b(0x61), []byte("b"), b(0x01)),
+			""},
+		{"duplicate keys",
+			[]Token{
+				{Type: TMapOpen, Length: 2},
+				TokStr("a"), TokInt(1),
+				TokStr("a"), TokInt(2),
+				{Type: TMapClose},
+			},
+			nil,
+			"cbor: duplicate map key (encoded as 6161) not permitted in deterministic encoding"},
+		{"float16",
+			[]Token{{Type: TFloat64, Float64: 1.5}},
+			[]byte{0xf9, 0x3e, 0x00},
+			""},
+		{"float16 subnormal",
+			[]Token{{Type: TFloat64, Float64: 5.960464477539063e-08}},
+			[]byte{0xf9, 0x00, 0x01},
+			""},
+		{"float16 negative zero",
+			[]Token{{Type: TFloat64, Float64: math.Copysign(0, -1)}},
+			[]byte{0xf9, 0x80, 0x00},
+			""},
+		{"float16 infinity",
+			[]Token{{Type: TFloat64, Float64: math.Inf(-1)}},
+			[]byte{0xf9, 0xfc, 0x00},
+			""},
+		{"float16 nan",
+			[]Token{{Type: TFloat64, Float64: math.NaN()}},
+			[]byte{0xf9, 0x7e, 0x00},
+			""},
+		{"float32",
+			[]Token{{Type: TFloat64, Float64: 100000.0}},
+			[]byte{0xfa, 0x47, 0xc3, 0x50, 0x00},
+			""},
+		{"float64",
+			[]Token{{Type: TFloat64, Float64: 1.1}},
+			[]byte{0xfb, 0x3f, 0xf1, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a},
+			""},
+	}
+	for _, tr := range tt {
+		buf := &bytes.Buffer{}
+		e := NewEncoderWithOptions(EncodeOptions{Deterministic: true}, buf)
+		var err error
+		for _, tok := range tr.tokens {
+			if _, err = e.Step(&tok); err != nil {
+				break
+			}
+		}
+		if tr.expectErr != "" {
+			if err == nil || err.Error() != tr.expectErr {
+				t.Errorf("test %q: expected error %q, got %v", tr.title, tr.expectErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %q: unexpected error %s", tr.title, err)
+		}
+		Assert(t, tr.title, tr.serial, buf.Bytes())
+	}
+}
+
+func TestCborEncoderFloatModes(t *testing.T) {
+	tt := []struct {
+		mode   FloatMode
+		value  float64
+		serial []byte
+	}{
+		{FloatMode_Float64, 1.5, []byte{0xfb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"", 1.5, []byte{0xfb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{FloatMode_Shortest, 1.5, []byte{0xf9, 0x3e, 0x00}},
+		{FloatMode_Shortest, float64(float32(0.1)), []byte{0xfa, 0x3d, 0xcc, 0xcc, 0xcd}},
+		{FloatMode_Shortest, 0.1, []byte{0xfb, 0x3f, 0xb9, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}},
+		{FloatMode_Shortest, 65504, []byte{0xf9, 0x7b, 0xff}},
+		{FloatMode_Shortest, 65505, []byte{0xfa, 0x47, 0x7f, 0xe1, 0x00}},
+		{FloatMode_Float32, 0.1, []byte{0xfa, 0x3d, 0xcc, 0xcc, 0xcd}},
+		{FloatMode_Float32, 1.5, []byte{0xf9, 0x3e, 0x00}},
+		{FloatMode_Float32, 1e300, []byte{0xfb, 0x7e, 0x37, 0xe4, 0x3c, 0x88, 0x00, 0x75, 0x9c}},
+		{FloatMode_Float32, -1e300, []byte{0xfb, 0xfe, 0x37, 0xe4, 0x3c, 0x88, 0x00, 0x75, 0x9c}},
+		{FloatMode_Float32, math.Inf(1), []byte{0xf9, 0x7c, 0x00}},
+	}
+	for _, tr := range tt {
+		title := fmt.Sprintf("%v in mode %q", tr.value, tr.mode)
+		buf := &bytes.Buffer{}
+		e := NewEncoderWithOptions(EncodeOptions{FloatMode: tr.mode}, buf)
+		if _, err := e.Step(&Token{Type: TFloat64, Float64: tr.value}); err != nil {
+			t.Errorf("test %q: unexpected error %s", title, err)
+		}
+		Assert(t, title, tr.serial, buf.Bytes())
+	}
+	// Deterministic mode defaults to shortest, but an explicit mode wins.
+	for _, mode := range []FloatMode{"", FloatMode_Float64} {
+		buf := &bytes.Buffer{}
+		e := NewEncoderWithOptions(EncodeOptions{Deterministic: true, FloatMode: mode}, buf)
+		if _, err := e.Step(&Token{Type: TFloat64, Float64: 1.5}); err != nil {
+			t.Errorf("deterministic mode %q: unexpected error %s", mode, err)
+		}
+		expect := []byte{0xf9, 0x3e, 0x00}
+		if mode == FloatMode_Float64 {
+			expect = []byte{0xfb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}
+		}
+		Assert(t, fmt.Sprintf("deterministic mode %q", mode), expect, buf.Bytes())
+	}
+	// Transcoding cbor to cbor: floats from the Decoder come with the
+	// width they were read with, but the FloatMode still decides.
+	f64 := []byte{0xfb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}                     // 1.5, as float64.
+	f16 := []byte{0xf9, 0x3e, 0x00}                                       // 1.5, as float16.
+	tenth := []byte{0xfb, 0x3f, 0xb9, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a} // 0.1, as float64.
+	for _, tr := range []struct {
+		opts   EncodeOptions
+		serial []byte
+		expect []byte
+	}{
+		{EncodeOptions{FloatMode: FloatMode_Shortest}, f64, f16},
+		{EncodeOptions{FloatMode: FloatMode_Shortest, PreserveWidths: true}, f64, f16},
+		{EncodeOptions{FloatMode: FloatMode_Float32, PreserveWidths: true}, tenth, []byte{0xfa, 0x3d, 0xcc, 0xcc, 0xcd}},
+		{EncodeOptions{FloatMode: FloatMode_Float64, PreserveWidths: true}, f16, f64},
+		{EncodeOptions{PreserveWidths: true}, f16, f16},
+		{EncodeOptions{}, f16, f64},
+	} {
+		title := fmt.Sprintf("transcoding %x with %+v", tr.serial, tr.opts)
+		buf := &bytes.Buffer{}
+		err := shared.TokenPump{
+			TokenSource: NewDecoder(bytes.NewReader(tr.serial)),
+			TokenSink:   NewEncoderWithOptions(tr.opts, buf),
+		}.Run()
+		if err != nil {
+			t.Errorf("test %q: unexpected error %s", title, err)
+		}
+		Assert(t, title, tr.expect, buf.Bytes())
+	}
+	// Unknown modes are rejected up front, not in the middle of encoding.
+	func() {
+		defer func() {
+			if r := recover(); r == nil || fmt.Sprint(r) != `invalid float mode "float8"` {
+				t.Errorf("unknown mode: expected panic, got %v", r)
+			}
+		}()
+		NewEncoderWithOptions(EncodeOptions{FloatMode: "float8"}, &bytes.Buffer{})
+	}()
+}
+
+func TestCborEncoderWidths(t *testing.T) {
+	tt := []struct {
+		title     string
+		tok       Token
+		serial    []byte
+		expectErr string
+	}{
+		{"uint in 1 byte", Token{Type: TUint, Uint: 1, Width: 1}, []byte{0x18, 0x01}, ""},
+		{"uint in 8 bytes", Token{Type: TUint, Uint: 1, Width: 8}, []byte{0x1b, 0, 0, 0, 0, 0, 0, 0, 0x01}, ""},
+		{"negative int in 2 bytes", Token{Type: TInt, Int: -1, Width: 2}, []byte{0x39, 0x00, 0x00}, ""},
+		{"array length in 1 byte", Token{Type: TArrOpen, Length: 0, Width: 1}, []byte{0x98, 0x00}, ""},
+		{"float16", Token{Type: TFloat64, Float64: 1.5, Width: 2}, []byte{0xf9, 0x3e, 0x00}, ""},
+		{"float32", Token{Type: TFloat64, Float64: 1.5, Width: 4}, []byte{0xfa, 0x3f, 0xc0, 0x00, 0x00}, ""},
+		{"NaN float32", Token{Type: TFloat64, Float64: math.NaN(), Width: 4}, []byte{0xfa, 0x7f, 0xc0, 0x00, 0x00}, ""},
+		{"uint too big", Token{Type: TUint, Uint: 256, Width: 1}, nil, "cbor: 256 does not fit in 1 bytes"},
+		{"invalid width", Token{Type: TUint, Uint: 1, Width: 3}, nil, "cbor: invalid width 3; must be 1, 2, 4, or 8"},
+		{"inexact float", Token{Type: TFloat64, Float64: 0.1, Width: 4}, nil, "cbor: float 0.1 does not fit exactly in 4 bytes"},
+		{"invalid float width", Token{Type: TFloat64, Float64: 1.5, Width: 1}, nil, "cbor: invalid float width 1; must be 2, 4, or 8"},
+	}
+	for _, tr := range tt {
+		buf := &bytes.Buffer{}
+		_, err := NewEncoderWithOptions(EncodeOptions{PreserveWidths: true}, buf).Step(&tr.tok)
+		if tr.expectErr != "" {
+			if err == nil || err.Error() != tr.expectErr {
+				t.Errorf("test %q: expected error %q, got %v", tr.title, tr.expectErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %q: unexpected error %s", tr.title, err)
+		}
+		Assert(t, tr.title, tr.serial, buf.Bytes())
+	}
+	// The decoder reports the widths it read, so re-encoding with
+	// PreserveWidths keeps them; without it, or in deterministic mode,
+	// the encoder chooses for itself.
+	serial := []byte{0x98, 0x03, 0x19, 0x00, 0x01, 0xfa, 0x3f, 0xc0, 0x00, 0x00, 0xf9, 0x3e, 0x00}
+	for _, tr := range []struct {
+		title  string
+		opts   EncodeOptions
+		serial []byte
+	}{
+		{"re-encoding preserving widths", EncodeOptions{PreserveWidths: true}, serial},
+		{"re-encoding", EncodeOptions{},
+			[]byte{0x83, 0x01, 0xfb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0, 0xfb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"deterministic re-encoding", EncodeOptions{Deterministic: true, PreserveWidths: true},
+			[]byte{0x83, 0x01, 0xf9, 0x3e, 0x00, 0xf9, 0x3e, 0x00}},
+	} {
+		buf := &bytes.Buffer{}
+		err := shared.TokenPump{
+			TokenSource: NewDecoder(bytes.NewReader(serial)),
+			TokenSink:   NewEncoderWithOptions(tr.opts, buf),
+		}.Run()
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", tr.title, err)
+		}
+		Assert(t, tr.title, tr.serial, buf.Bytes())
+	}
+}
+
+func TestCborEncoderMalformed(t *testing.T) {
+	tt := []struct {
+		tokens    []Token
+		expectErr string
+	}{
+		{[]Token{{Type: TMapClose}},
+			"malformed stream: invalid appearance of map close token; expected start of value"},
+		{[]Token{{Type: TArrOpen, Length: -1}, {Type: TMapClose}},
+			"malformed stream: invalid appearance of map close token; expected start of value or end of array"},
+		{[]Token{{Type: TMapOpen, Length: -1}, {Type: TFloat64, Float64: 1}},
+			"malformed stream: invalid appearance of float token; expected map key or end of map"},
+	}
+	for _, tr := range tt {
+		e := NewEncoder(&bytes.Buffer{})
+		var err error
+		for _, tok := range tr.tokens {
+			if _, err = e.Step(&tok); err != nil {
+				break
+			}
+		}
+		if _, ok := err.(shared.ErrMalformedTokenStream); !ok || err.Error() != tr.expectErr {
+			t.Errorf("expected ErrMalformedTokenStream %q, got %#v", tr.expectErr, err)
+		}
+	}
+}
@@ -285,6 +285,28 @@ var cborFixtures = []struct {
 		nil,
 		nil,
 	},
+	{"",
+		fixtures.SequenceMap["undefined"],
+		b(0xf7),
+		nil,
+		nil,
+	},
+	{"",
+		fixtures.SequenceMap["undefined in map"],
+		bcat(b(0xa0+1),
+			b(0x60+1), []byte(`k`), b(0xf7),
+		),
+		nil,
+		nil,
+	},
+	{"",
+		fixtures.SequenceMap["simple values in array"],
+		bcat(b(0x80+3),
+			b(0xe0+16), b(0xf8), b(32), b(0xf8), b(255),
+		),
+		nil,
+		nil,
+	},
 	{"",
 		fixtures.SequenceMap["null in array"],
 		bcat(b(0x80+1),
@@ -440,6 +462,12 @@ var cborFixtures = []struct {
 		nil,
 		nil,
 	},
+	{"",
+		fixtures.SequenceMap["string with nested tags"],
+		bcat(b(0xc0+(0x20-7)), []byte{0xd9, 0xf7}, b(0xc0+(0x20-8)), b(42), b(0x60+1), []byte(`x`)),
+		nil,
+		nil,
+	},
 	{"",
 		fixtures.SequenceMap["array with mixed tagged values"],
 		bcat(b(0x80+2),
@@ -42,6 +42,14 @@ func MarshalAtlased(v interface{}, atl atlas.Atlas) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+func MarshalWithOptions(opts EncodeOptions, v interface{}, atl atlas.Atlas) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := NewMarshallerWithOptions(opts, &buf, atl).Marshal(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 type Marshaller struct {
 	marshaller *obj.Marshaller
 	encoder    *Encoder
@@ -59,9 +67,13 @@ func NewMarshaller(wr io.Writer) *Marshaller {
 }
 
 func NewMarshallerAtlased(wr io.Writer, atl atlas.Atlas) *Marshaller {
+	return NewMarshallerWithOptions(EncodeOptions{}, wr, atl)
+}
+
+func NewMarshallerWithOptions(opts EncodeOptions, wr io.Writer, atl atlas.Atlas) *Marshaller {
 	x := &Marshaller{
 		marshaller: obj.NewMarshaller(atl),
-		encoder:    NewEncoder(wr),
+		encoder:    NewEncoderWithOptions(opts, wr),
 	}
 	x.pump = shared.TokenPump{
 		x.marshaller,
@@ -78,6 +90,10 @@ func UnmarshalAtlased(data []byte, v interface{}, atl atlas.Atlas) error {
 	return NewUnmarshallerAtlased(bytes.NewBuffer(data), atl).Unmarshal(v)
 }
 
+func UnmarshalWithOptions(opts DecodeOptions, data []byte, v interface{}, atl atlas.Atlas) error {
+	return NewUnmarshallerWithOptions(opts, bytes.NewBuffer(data), atl).Unmarshal(v)
+}
+
 type Unmarshaller struct {
 	unmarshaller *obj.Unmarshaller
 	decoder      *Decoder
@@ -94,10 +110,14 @@ func NewUnmarshaller(r io.Reader) *Unmarshaller {
 	return NewUnmarshallerAtlased(r, atlas.MustBuild())
 }
 func NewUnmarshallerAtlased(r io.Reader, atl atlas.Atlas) *Unmarshaller {
+	return NewUnmarshallerWithOptions(DecodeOptions{}, r, atl)
+}
+func NewUnmarshallerWithOptions(opts DecodeOptions, r io.Reader, atl atlas.Atlas) *Unmarshaller {
 	x := &Unmarshaller{
 		unmarshaller: obj.NewUnmarshaller(atl),
-		decoder:      NewDecoder(r),
+		decoder:      NewDecoderWithOptions(opts, r),
 	}
+	x.unmarshaller.SetAllocBudget(opts.MaxAllocBudget)
 	x.pump = shared.TokenPump{
 		x.decoder,
 		x.unmarshaller,
@@ -1,16 +1,99 @@
 package cbor
 
 type EncodeOptions struct {
-	// there aren't a ton of options for cbor, but we still need this
-	// for use as a sigil for the top-level refmt methods to demux on.
+	// Deterministic enables the "core deterministic encoding" of
+	// RFC 8949 section 4.2.1, so that equal data always encodes to equal bytes
+	// (and can be hashed, used as a content address, etc):
+	//
+	//   - integers, lengths, and tags use the shortest form
+	//     (disregarding `PreserveWidths`);
+	//   - floats use the shortest of float16, float32, or float64
+	//     that represents the value exactly; NaN is always 0xf97e00
+	//     (unless `FloatMode` is set to something else; see below);
+	//   - maps and arrays are always emitted with definite lengths,
+	//     even if the token stream gave them as indefinite;
+	//   - map entries are sorted by the bytewise order of their encoded keys,
+	//     regardless of the order the token stream gave them in.
+	//
+	// Since lengths and key order can't be known until a map or array ends,
+	// the encoder buffers each top-level map or array entirely in memory
+	// before writing it.  Duplicate map keys are rejected with an error.
+	Deterministic bool
+
+	// FloatMode selects how wide a float to emit.
+	// The default, `FloatMode_Float64`, always emits 8-byte doubles.
+	// `FloatMode_Shortest` emits float16 or float32 whenever that's lossless;
+	// since any float32 value fits exactly, this means `float32` fields
+	// go on the wire as float32 (or smaller), and round-trip exactly.
+	// `FloatMode_Float32` always emits float32 (or float16 if that's no
+	// further loss), rounding any doubles that don't fit -- except finite
+	// values too large for a float32, which are emitted as float64 rather
+	// than rounded to infinity.
+	// Any other mode is invalid, and `NewEncoderWithOptions` panics on it.
+	// In deterministic mode, leaving this unset means `FloatMode_Shortest`,
+	// as RFC 8949 prescribes; other modes may still be set explicitly
+	// (DAG-CBOR, for example, requires `FloatMode_Float64`).
+	// The mode applies to every float, whatever its source -- including
+	// floats read by a Decoder, even with `PreserveWidths` set.
+	FloatMode FloatMode
+
+	// PreserveWidths makes the encoder honor each token's `Width`:
+	// ints, and map and array lengths, are emitted with an argument of
+	// the number of bytes it asks for, and so are floats, unless
+	// `FloatMode` is set -- an explicit FloatMode always wins.
+	// A value that doesn't fit the width it asks for is an error.
+	// The Decoder (and `diag.Decoder`) set `Width` to the form they read,
+	// so with this, re-encoding what they yield keeps that form.
+	// Ignored in deterministic mode, which must use the shortest forms.
+	PreserveWidths bool
 }
 
+// A type to enumerate float emission modes.
+type FloatMode string
+
+const (
+	FloatMode_Float64  = "float64"  // Always 8 bytes.  The default.
+	FloatMode_Shortest = "shortest" // Shortest of float16, float32, float64 that's exact.
+	FloatMode_Float32  = "float32"  // Round to float32, then shorten to float16 if exact.  Lossy!
+)
+
 // marker method -- you may use this type to instruct `refmt.Marshal`
 // what kind of encoder to use.
 func (EncodeOptions) IsEncodeOptions() {}
 
 type DecodeOptions struct {
-	// future: options to validate canonical serial order
+	// Strict rejects any input that isn't in the "core deterministic encoding"
+	// of RFC 8949 section 4.2.1 -- that is, anything `EncodeOptions.Deterministic`
+	// would not have produced: integers, lengths, or tags not in their shortest form,
+	// floats that would fit exactly in a shorter float, indefinite-length
+	// items of any kind, map keys that are maps or arrays, and map keys that are
+	// duplicated or not in bytewise order of their encoding.
+	// Violations halt decoding with an `*ErrNonCanonical` error.
+	Strict bool
+
+	// FloatMode sets how wide floats must be in strict mode.
+	// Leaving it unset means `FloatMode_Shortest`, as described above;
+	// `FloatMode_Float64` instead requires every float to be 8 bytes.
+	// (`FloatMode_Float32` is lossy, so can't be required;
+	// `NewDecoderWithOptions` panics on it, as on any unknown mode.)
+	// Ignored outside of strict mode.
+	FloatMode FloatMode
+
+	// Resource limits, for use when decoding untrusted input.
+	// CBOR length headers can claim arbitrarily large sizes up front,
+	// so without these a few hostile bytes can demand gigabytes of memory.
+	// Zero means unlimited.  Exceeding any limit halts decoding with a
+	// `shared.ErrLimitExceeded` error.
+
+	MaxDepth           int   // Maximum nesting depth of maps and arrays.
+	MaxStringLength    int   // Maximum length in bytes of any single string or byte string.
+	MaxContainerLength int   // Maximum number of entries in any single map or array.
+	MaxTotalBytes      int64 // Maximum number of bytes to consume from the reader (per value; counted from `Reset`).
+	MaxTagNesting      int   // Maximum number of tags wrapped around any single item.
+
+	// Allocation budget for the `obj.Unmarshaller` when using the
+	// unmarshal helpers in this package.  See `obj.Unmarshaller.SetAllocBudget`.
+	MaxAllocBudget int64
 }
 
 // marker method -- you may use this type to instruct `refmt.Marshal`
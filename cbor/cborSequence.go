@@ -0,0 +1,215 @@
+package cbor
+
+import (
+	"io"
+
+	"github.com/polydawn/refmt/obj"
+	"github.com/polydawn/refmt/obj/atlas"
+	"github.com/polydawn/refmt/shared"
+	. "github.com/polydawn/refmt/tok"
+)
+
+/*
+	SequenceDecoder reads a CBOR Sequence (RFC 8742): any number of top-level
+	data items, simply concatenated, with nothing in between.
+
+	It's a TokenSource like Decoder, but instead of being done after one item,
+	each time it's stepped after finishing one it starts on the next.
+	Call `More` before each item to find out if there is one:
+	it returns false only if the input ended cleanly between items.
+	If the input ends partway through an item, stepping returns
+	`io.ErrUnexpectedEOF`.
+
+	Errors are sticky: after one, there's no telling where the next item
+	would start, so every further step returns the same error.
+*/
+type SequenceDecoder struct {
+	d   *Decoder
+	mid bool  // True if we've started an item and not yet finished it.
+	err error // Sticky.  May be io.EOF, meaning we found the clean end.
+}
+
+func NewSequenceDecoder(r io.Reader) *SequenceDecoder {
+	return NewSequenceDecoderWithOptions(DecodeOptions{}, r)
+}
+
+func NewSequenceDecoderWithOptions(cfg DecodeOptions, r io.Reader) *SequenceDecoder {
+	return &SequenceDecoder{d: NewDecoderWithOptions(cfg, r)}
+}
+
+// More reports whether there's another item to read.
+// If reading ahead to find out hits an error (other than a clean EOF),
+// More returns true, so that the next step can report it.
+func (d *SequenceDecoder) More() bool {
+	if d.mid {
+		return true
+	}
+	if d.err == nil {
+		if _, d.err = d.d.r.Readn1(); d.err == nil {
+			d.d.r.Unreadn1()
+		}
+	}
+	return d.err != io.EOF
+}
+
+// Step yields the next token of the current item, starting a new item if the
+// last one is finished.  It returns done at the end of each item.
+// At the clean end of the sequence, it returns done and `io.EOF`.
+func (d *SequenceDecoder) Step(tokenSlot *Token) (done bool, err error) {
+	if !d.mid {
+		if d.err != nil {
+			return true, d.err
+		}
+		d.d.Reset()
+		d.mid = true
+	}
+	done, err = d.d.Step(tokenSlot)
+	if err != nil {
+		d.mid = false
+		d.err = err
+		return true, err
+	}
+	if done {
+		d.mid = false
+	}
+	return done, nil
+}
+
+// Skip the rest of the current item, if we're partway through one.
+// (Errors found on the way are sticky as usual, and returned.)
+func (d *SequenceDecoder) skipItem() error {
+	var tok Token
+	for d.mid {
+		if _, err := d.Step(&tok); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+	SequenceEncoder writes a CBOR Sequence (RFC 8742).
+
+	It's a TokenSink like Encoder, but after each complete item it's ready
+	to accept the start of another, which will be written straight after.
+*/
+type SequenceEncoder struct {
+	e   *Encoder
+	mid bool // True if we've started an item and not yet finished it.
+}
+
+func NewSequenceEncoder(w io.Writer) *SequenceEncoder {
+	return NewSequenceEncoderWithOptions(EncodeOptions{}, w)
+}
+
+func NewSequenceEncoderWithOptions(cfg EncodeOptions, w io.Writer) *SequenceEncoder {
+	return &SequenceEncoder{e: NewEncoderWithOptions(cfg, w)}
+}
+
+func (d *SequenceEncoder) Step(tokenSlot *Token) (done bool, err error) {
+	if !d.mid {
+		d.e.Reset()
+		d.mid = true
+	}
+	done, err = d.e.Step(tokenSlot)
+	if done {
+		d.mid = false
+	}
+	return done, err
+}
+
+/*
+	SequenceUnmarshaller unmarshals each item of a CBOR Sequence in turn.
+
+	Use it like this:
+
+		for dec.More() {
+			var v T
+			if err := dec.Unmarshal(&v); err != nil {
+				return err
+			}
+		}
+
+	If an item can't be unmarshalled into v (e.g. it's the wrong type),
+	the rest of that item is skipped, so you can carry on with the next.
+	Errors in the sequence itself, though, are sticky; see `SequenceDecoder`.
+*/
+type SequenceUnmarshaller struct {
+	unmarshaller *obj.Unmarshaller
+	decoder      *SequenceDecoder
+	pump         shared.TokenPump
+}
+
+// More reports whether there's another item to unmarshal.
+// See `SequenceDecoder.More`.
+func (x *SequenceUnmarshaller) More() bool {
+	return x.decoder.More()
+}
+
+// Unmarshal the next item into v.
+// Returns `io.EOF` if there are no more items.
+func (x *SequenceUnmarshaller) Unmarshal(v interface{}) error {
+	if !x.decoder.More() {
+		return io.EOF
+	}
+	x.unmarshaller.Bind(v)
+	err := x.pump.Run()
+	if err != nil {
+		x.decoder.skipItem()
+	}
+	return err
+}
+
+func NewSequenceUnmarshaller(r io.Reader) *SequenceUnmarshaller {
+	return NewSequenceUnmarshallerAtlased(r, atlas.MustBuild())
+}
+func NewSequenceUnmarshallerAtlased(r io.Reader, atl atlas.Atlas) *SequenceUnmarshaller {
+	return NewSequenceUnmarshallerWithOptions(DecodeOptions{}, r, atl)
+}
+func NewSequenceUnmarshallerWithOptions(opts DecodeOptions, r io.Reader, atl atlas.Atlas) *SequenceUnmarshaller {
+	x := &SequenceUnmarshaller{
+		unmarshaller: obj.NewUnmarshaller(atl),
+		decoder:      NewSequenceDecoderWithOptions(opts, r),
+	}
+	x.unmarshaller.SetAllocBudget(opts.MaxAllocBudget)
+	x.pump = shared.TokenPump{
+		TokenSource: x.decoder,
+		TokenSink:   x.unmarshaller,
+	}
+	return x
+}
+
+/*
+	SequenceMarshaller marshals each value it's given as the next item
+	of a CBOR Sequence.
+*/
+type SequenceMarshaller struct {
+	marshaller *obj.Marshaller
+	encoder    *SequenceEncoder
+	pump       shared.TokenPump
+}
+
+func (x *SequenceMarshaller) Marshal(v interface{}) error {
+	x.marshaller.Bind(v)
+	return x.pump.Run()
+}
+
+func NewSequenceMarshaller(wr io.Writer) *SequenceMarshaller {
+	return NewSequenceMarshallerAtlased(wr, atlas.MustBuild())
+}
+
+func NewSequenceMarshallerAtlased(wr io.Writer, atl atlas.Atlas) *SequenceMarshaller {
+	return NewSequenceMarshallerWithOptions(EncodeOptions{}, wr, atl)
+}
+
+func NewSequenceMarshallerWithOptions(opts EncodeOptions, wr io.Writer, atl atlas.Atlas) *SequenceMarshaller {
+	x := &SequenceMarshaller{
+		marshaller: obj.NewMarshaller(atl),
+		encoder:    NewSequenceEncoderWithOptions(opts, wr),
+	}
+	x.pump = shared.TokenPump{
+		TokenSource: x.marshaller,
+		TokenSink:   x.encoder,
+	}
+	return x
+}
@@ -0,0 +1,127 @@
+package cbor
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	. "github.com/polydawn/refmt/tok"
+)
+
+func TestCborSequenceDecoder(t *testing.T) {
+	tt := []struct {
+		title     string
+		serial    []byte
+		expect    [][]string // Tokens of each item (the last may be cut short).
+		expectErr error      // What the step after those returns.
+	}{
+		{"empty",
+			[]byte{},
+			nil,
+			io.EOF},
+		{"three items",
+			[]byte{0x81, 0x01, 0x61, 'a', 0xa1, 0x61, 'k', 0xf6},
+			[][]string{
+				{`<[:1>`, `<u:1>`, `<]>`},
+				{`<s:"a">`},
+				{`<{:1>`, `<s:"k">`, `<0>`, `<}>`},
+			},
+			io.EOF},
+		{"truncated in a header",
+			[]byte{0x01, 0x19, 0x01},
+			[][]string{{`<u:1>`}},
+			io.ErrUnexpectedEOF},
+		{"truncated in an array",
+			[]byte{0x01, 0x82, 0x01},
+			[][]string{{`<u:1>`}, {`<[:2>`, `<u:1>`}},
+			io.ErrUnexpectedEOF},
+		{"truncated after a tag",
+			[]byte{0xc1},
+			nil,
+			io.ErrUnexpectedEOF},
+	}
+	for _, tr := range tt {
+		d := NewSequenceDecoder(bytes.NewBuffer(tr.serial))
+		var tok Token
+		for i, item := range tr.expect {
+			if !d.More() {
+				t.Errorf("test %q: expected item %d, but More says no", tr.title, i)
+			}
+			for n, expect := range item {
+				done, err := d.Step(&tok)
+				if err != nil {
+					t.Errorf("test %q item %d step %d errored: %s", tr.title, i, n, err)
+				}
+				if tok.String() != expect {
+					t.Errorf("test %q item %d step %d yielded wrong token: expected %s, got %s", tr.title, i, n, expect, tok)
+				}
+				if done && n != len(item)-1 {
+					t.Errorf("test %q item %d done early at step %d", tr.title, i, n)
+				}
+			}
+		}
+		if more := d.More(); more != (tr.expectErr != io.EOF) {
+			t.Errorf("test %q: at end, More says %v", tr.title, more)
+		}
+		if _, err := d.Step(&tok); err != tr.expectErr {
+			t.Errorf("test %q: at end, expected error %v, got %v", tr.title, tr.expectErr, err)
+		}
+	}
+}
+
+func TestCborSequenceRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewSequenceMarshaller(&buf)
+	for _, v := range []map[string]int{{"a": 1}, {"b": 2}, {}} {
+		if err := enc.Marshal(v); err != nil {
+			t.Fatalf("marshal failed: %s", err)
+		}
+	}
+	if !bytes.Equal(buf.Bytes(), []byte{0xa1, 0x61, 'a', 0x01, 0xa1, 0x61, 'b', 0x02, 0xa0}) {
+		t.Errorf("wrong serial: %x", buf.Bytes())
+	}
+
+	dec := NewSequenceUnmarshaller(&buf)
+	var got []map[string]int
+	for dec.More() {
+		var v map[string]int
+		if err := dec.Unmarshal(&v); err != nil {
+			t.Fatalf("unmarshal failed: %s", err)
+		}
+		got = append(got, v)
+	}
+	if len(got) != 3 || got[0]["a"] != 1 || got[1]["b"] != 2 || len(got[2]) != 0 {
+		t.Errorf("wrong result: %v", got)
+	}
+	if err := dec.Unmarshal(new(interface{})); err != io.EOF {
+		t.Errorf("expected EOF after the last item, got %v", err)
+	}
+}
+
+func TestCborSequenceUnmarshalPastBadItem(t *testing.T) {
+	serial := []byte{
+		0xa1, 0x61, 'a', 0x01, // {"a": 1}
+		0xa2, 0x61, 'a', 0x61, 'x', 0x61, 'b', 0x02, // {"a": "x", "b": 2}
+		0xa1, 0x61, 'c', 0x03, // {"c": 3}
+	}
+	dec := NewSequenceUnmarshaller(bytes.NewReader(serial))
+	var got []map[string]int
+	var errs int
+	for dec.More() {
+		var v map[string]int
+		if err := dec.Unmarshal(&v); err != nil {
+			errs++
+			continue
+		}
+		got = append(got, v)
+	}
+	if errs != 1 {
+		t.Errorf("expected one error, got %d", errs)
+	}
+	if len(got) != 2 || got[0]["a"] != 1 || got[1]["c"] != 3 || len(got[1]) != 1 {
+		t.Errorf("wrong result: %v", got)
+	}
+	if err := dec.Unmarshal(new(interface{})); err != io.EOF {
+		t.Errorf("expected EOF after the last item, got %v", err)
+	}
+}
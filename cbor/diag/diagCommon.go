@@ -0,0 +1,95 @@
+package diag
+
+import (
+	"math"
+)
+
+// The most heavily used words, cached as byte slices.
+var (
+	wordTrue      = []byte("true")
+	wordFalse     = []byte("false")
+	wordNull      = []byte("null")
+	wordUndefined = []byte("undefined")
+	wordNaN       = []byte("NaN")
+	wordInf       = []byte("Infinity")
+	wordNegInf    = []byte("-Infinity")
+	wordArrOpen   = []byte("[")
+	wordArrClose  = []byte("]")
+	wordMapOpen   = []byte("{")
+	wordMapClose  = []byte("}")
+	wordIndef     = []byte("_ ")
+	wordColon     = []byte(": ")
+	wordComma     = []byte(", ")
+	wordTagClose  = []byte(")")
+)
+
+// The width, in bytes, of the shortest cbor encoding of the argument `v`
+// (zero if it fits in the initial byte).
+func argWidth(v uint64) int {
+	switch {
+	case v < 24:
+		return 0
+	case v <= 0xff:
+		return 1
+	case v <= 0xffff:
+		return 2
+	case v <= 0xffffffff:
+		return 4
+	}
+	return 8
+}
+
+// The width, in bytes, of the shortest cbor float that holds `f` exactly.
+func floatWidth(f float64) int {
+	switch {
+	case f != f:
+		return 2
+	case float64(float32(f)) != f:
+		return 8
+	case fitsFloat16(f):
+		return 2
+	}
+	return 4
+}
+
+// Whether a float16 holds `f` exactly: normal float16s have 11 significant
+// bits and exponents down to -14; below that, they're multiples of 2^-24.
+func fitsFloat16(f float64) bool {
+	if f == 0 || math.IsInf(f, 0) {
+		return true
+	}
+	frac, exp := math.Frexp(math.Abs(f)) // f is frac * 2^exp, and frac is in [0.5, 1).
+	if exp > 16 {
+		return false
+	}
+	if exp < -13 {
+		x := math.Ldexp(frac, exp+24)
+		return x == math.Trunc(x)
+	}
+	x := math.Ldexp(frac, 11)
+	return x == math.Trunc(x)
+}
+
+// The encoding indicator for each float width, and for each argument width.
+// (Argument widths of zero, for arguments in the initial byte, get none.)
+func floatIndicator(width int) string {
+	switch width {
+	case 2:
+		return "_1"
+	case 4:
+		return "_2"
+	}
+	return "_3"
+}
+
+func argIndicator(width int) string {
+	switch width {
+	case 1:
+		return "_0"
+	case 2:
+		return "_1"
+	case 4:
+		return "_2"
+	}
+	return "_3"
+}
@@ -0,0 +1,234 @@
+package diag
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	. "github.com/polydawn/refmt/tok"
+)
+
+/*
+	A diag.Decoder is a TokenSource implementation that reads CBOR
+	diagnostic notation.
+
+	Definite-length maps and arrays must report their length in the first
+	token, and in diagnostic notation that's only known by looking ahead
+	to the end of them, so the Decoder reads all of its input into memory
+	on the first step.  (Diagnostic notation is meant for small things
+	written by humans, so this shouldn't be a problem.)
+*/
+type Decoder struct {
+	r    io.Reader
+	buf  []byte // The whole input, once read.
+	read bool   // Set once `buf` is filled.
+	pos  int    // Offset in `buf` of the next byte to look at.
+
+	stack []decoderStep // When empty, and step returns done, all done.
+	step  decoderStep   // Shortcut to end of stack.
+	some  bool          // Set to true after first value in any context; use to decide if a comma must precede the next value.
+	tags  []int         // Number of tags wrapped around each open map and array, whose closing parens come after it.
+}
+
+func NewDecoder(r io.Reader) (d *Decoder) {
+	d = &Decoder{
+		r:     r,
+		stack: make([]decoderStep, 0, 10),
+		tags:  make([]int, 0, 10),
+	}
+	d.step = d.step_acceptValue
+	return
+}
+
+// Reset the decoder to read another value.
+// Input already read will continue from where the last value ended.
+func (d *Decoder) Reset() {
+	d.stack = d.stack[0:0]
+	d.step = d.step_acceptValue
+	d.some = false
+	d.tags = d.tags[0:0]
+}
+
+type decoderStep func(tokenSlot *Token) (done bool, err error)
+
+func (d *Decoder) Step(tokenSlot *Token) (done bool, err error) {
+	if !d.read {
+		d.read = true
+		if d.buf, err = ioutil.ReadAll(d.r); err != nil {
+			return true, err
+		}
+	}
+	done, err = d.step(tokenSlot)
+	// If the step errored: out, entirely.
+	if err != nil {
+		return true, err
+	}
+	// If the step wasn't done, return same status.
+	if !done {
+		return false, nil
+	}
+	// If it WAS done, and stack empty, we're entirely done.
+	nSteps := len(d.stack) - 1
+	if nSteps <= 0 {
+		return true, nil // that's all folks
+	}
+	// Pop the stack.  Reset "some" to true.
+	d.step = d.stack[nSteps]
+	d.stack = d.stack[0:nSteps]
+	d.some = true
+	return false, nil
+}
+
+func (d *Decoder) pushPhase(newPhase decoderStep, tags int) {
+	d.stack = append(d.stack, d.step)
+	d.step = newPhase
+	d.some = false
+	d.tags = append(d.tags, tags)
+}
+
+// Finish off a map or array: consume the closing parens of any tags around it.
+func (d *Decoder) closeComposite() error {
+	nt := len(d.tags) - 1
+	err := d.closeTags(d.tags[nt])
+	d.tags = d.tags[0:nt]
+	return err
+}
+
+func (d *Decoder) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("diag: %s at offset %d", fmt.Sprintf(format, args...), d.pos)
+}
+
+// The original step, where any value is accepted, and no terminators for composites are valid.
+// ONLY used in the original step; all other steps handle leaf nodes internally.
+func (d *Decoder) step_acceptValue(tokenSlot *Token) (done bool, err error) {
+	if err := d.skipSpace(); err != nil {
+		return true, err
+	}
+	if d.pos == len(d.buf) {
+		return true, io.EOF
+	}
+	return d.stepHelper_acceptValue(tokenSlot)
+}
+
+// Step in midst of decoding an array.
+func (d *Decoder) step_acceptArrValueOrBreak(tokenSlot *Token) (done bool, err error) {
+	c, err := d.nextEntry(']')
+	if err != nil {
+		return true, err
+	}
+	if c == ']' {
+		tokenSlot.Type = TArrClose
+		return true, d.closeComposite()
+	}
+	d.some = true
+	_, err = d.stepHelper_acceptValue(tokenSlot)
+	return false, err
+}
+
+// Step in midst of decoding a map, key expected up next, or end.
+func (d *Decoder) step_acceptMapKeyOrBreak(tokenSlot *Token) (done bool, err error) {
+	c, err := d.nextEntry('}')
+	if err != nil {
+		return true, err
+	}
+	if c == '}' {
+		tokenSlot.Type = TMapClose
+		return true, d.closeComposite()
+	}
+	d.some = true
+	done, err = d.stepHelper_acceptValue(tokenSlot)
+	if err != nil {
+		return true, err
+	}
+	if !done {
+		return true, d.errorf("maps and arrays as map keys are not supported")
+	}
+	// Now scan up to consume the colon as well, which is required next.
+	if err := d.expect(':'); err != nil {
+		return true, err
+	}
+	// Next up: expect a value.
+	d.step = d.step_acceptMapValue
+	return false, nil
+}
+
+// Step in midst of decoding a map, value expected up next.
+func (d *Decoder) step_acceptMapValue(tokenSlot *Token) (done bool, err error) {
+	if err := d.skipSpace(); err != nil {
+		return true, err
+	}
+	d.step = d.step_acceptMapKeyOrBreak
+	_, err = d.stepHelper_acceptValue(tokenSlot)
+	return false, err
+}
+
+// Skip to the start of the next entry in a map or array, past a comma if
+// one is needed, and return its first byte (which is `close` if there's
+// no next entry).
+func (d *Decoder) nextEntry(close byte) (byte, error) {
+	c, err := d.peekSkippingSpace()
+	if err != nil {
+		return 0, err
+	}
+	if c == close {
+		d.pos++
+		return c, nil
+	}
+	if d.some {
+		if c != ',' {
+			return 0, d.errorf("expected comma or %q", close)
+		}
+		d.pos++
+		if c, err = d.peekSkippingSpace(); err != nil {
+			return 0, err
+		}
+		if c == close {
+			return 0, d.errorf("unexpected %q after comma", close)
+		}
+	}
+	return c, nil
+}
+
+func (d *Decoder) stepHelper_acceptValue(tokenSlot *Token) (done bool, err error) {
+	tokenSlot.Tagged = false
+	tokenSlot.OuterTags = tokenSlot.OuterTags[:0]
+	tokenSlot.Width = 0
+	ntags, err := d.decodeTags(tokenSlot)
+	if err != nil {
+		return true, err
+	}
+	switch c := d.buf[d.pos]; c {
+	case '[', '{':
+		d.pos++
+		tokenSlot.Length, tokenSlot.Width, err = d.decodeLength(c)
+		if err != nil {
+			return true, err
+		}
+		if c == '[' {
+			tokenSlot.Type = TArrOpen
+			d.pushPhase(d.step_acceptArrValueOrBreak, ntags)
+		} else {
+			tokenSlot.Type = TMapOpen
+			d.pushPhase(d.step_acceptMapKeyOrBreak, ntags)
+		}
+		return false, nil
+	case '"':
+		tokenSlot.Type = TString
+		tokenSlot.Str, err = d.decodeString()
+	case '\'', 'h', 'b':
+		tokenSlot.Type = TBytes
+		tokenSlot.Bytes, err = d.decodeBytes()
+	case '(':
+		// An indefinite-length string, `(_ "a", "b")`.  Tokens can't carry
+		// its chunks, and we'd rather not quietly join them into one string.
+		return true, d.errorf("indefinite-length strings are not supported")
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', 'N', 'I':
+		err = d.decodeNumber(tokenSlot)
+	default:
+		err = d.decodeWord(tokenSlot)
+	}
+	if err != nil {
+		return true, err
+	}
+	return true, d.closeTags(ntags)
+}
@@ -0,0 +1,485 @@
+package diag
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"math"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	. "github.com/polydawn/refmt/tok"
+)
+
+// Skip whitespace and comments (which are written `/like this/`).
+func (d *Decoder) skipSpace() error {
+	for d.pos < len(d.buf) {
+		switch d.buf[d.pos] {
+		case ' ', '\t', '\r', '\n':
+			d.pos++
+		case '/':
+			start := d.pos
+			for d.pos++; d.pos < len(d.buf) && d.buf[d.pos] != '/'; d.pos++ {
+			}
+			if d.pos == len(d.buf) {
+				d.pos = start
+				return d.errorf("unterminated comment")
+			}
+			d.pos++
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// Skip whitespace, then return the next byte without consuming it.
+// Running out of input is an error, since this is only used mid-value.
+func (d *Decoder) peekSkippingSpace() (byte, error) {
+	if err := d.skipSpace(); err != nil {
+		return 0, err
+	}
+	if d.pos == len(d.buf) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return d.buf[d.pos], nil
+}
+
+// Skip whitespace, then consume the byte `c`, or error if it's something else.
+func (d *Decoder) expect(c byte) error {
+	c2, err := d.peekSkippingSpace()
+	if err != nil {
+		return err
+	}
+	if c2 != c {
+		return d.errorf("expected %q", c)
+	}
+	d.pos++
+	return nil
+}
+
+// Consume any tags (`123(`) at the start of a value, stacking them up
+// in the token, and return how many there were.
+// Leaves the position at the start of the value itself.
+func (d *Decoder) decodeTags(tokenSlot *Token) (int, error) {
+	n := 0
+	for {
+		if _, err := d.peekSkippingSpace(); err != nil {
+			return n, err
+		}
+		end := d.pos
+		for end < len(d.buf) && '0' <= d.buf[end] && d.buf[end] <= '9' {
+			end++
+		}
+		if end == d.pos || end == len(d.buf) || d.buf[end] != '(' {
+			return n, nil
+		}
+		tag, err := strconv.ParseUint(string(d.buf[d.pos:end]), 10, 64)
+		if err != nil {
+			return n, d.errorf("invalid tag number")
+		}
+		if tokenSlot.Tagged {
+			tokenSlot.OuterTags = append(tokenSlot.OuterTags, tokenSlot.Tag)
+		} else {
+			tokenSlot.OuterTags = tokenSlot.OuterTags[:0]
+		}
+		tokenSlot.Tagged = true
+		tokenSlot.Tag = tag
+		d.pos = end + 1
+		n++
+	}
+}
+
+// Consume the closing parens of `n` tags.
+func (d *Decoder) closeTags(n int) error {
+	for ; n > 0; n-- {
+		if err := d.expect(')'); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Called just after the opening bracket of a map or array.
+// Consumes the indefinite-length marker if there is one, and returns -1;
+// otherwise looks ahead and returns the number of entries, along with
+// the width given by the encoding indicator, if there is one.
+func (d *Decoder) decodeLength(open byte) (n int, width int, err error) {
+	if d.pos < len(d.buf) && d.buf[d.pos] == '_' {
+		if d.pos+1 == len(d.buf) || d.buf[d.pos+1] < '0' || d.buf[d.pos+1] > '9' {
+			d.pos++
+			return -1, 0, nil
+		}
+		if width, err = d.decodeEncodingIndicator(false); err != nil {
+			return 0, 0, err
+		}
+	}
+	if n, err = d.countEntries(open); err != nil {
+		return 0, 0, err
+	}
+	if width != 0 && width < argWidth(uint64(n)) {
+		return 0, 0, d.errorf("%d entries don't fit the encoding indicator", n)
+	}
+	return n, width, nil
+}
+
+// Scan ahead to the end of the map or array we're in and count its entries.
+// Anything that's not well-formed is left for the real parse to complain about.
+func (d *Decoder) countEntries(open byte) (int, error) {
+	start := d.pos
+	defer func() { d.pos = start }()
+	if c, err := d.peekSkippingSpace(); err != nil {
+		return 0, err
+	} else if c == open+2 { // ']' and '}' are both two after their open.
+		return 0, nil
+	}
+	n, depth := 1, 0
+	for d.pos < len(d.buf) {
+		switch c := d.buf[d.pos]; c {
+		case '"', '\'':
+			if err := d.skipQuoted(c); err != nil {
+				return 0, err
+			}
+			continue
+		case '/':
+			if err := d.skipSpace(); err != nil {
+				return 0, err
+			}
+			continue
+		case '[', '{', '(':
+			depth++
+		case ']', '}', ')':
+			if depth == 0 {
+				return n, nil
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				n++
+			}
+		}
+		d.pos++
+	}
+	return 0, io.ErrUnexpectedEOF
+}
+
+// Skip past a quoted string, starting at the open quote.
+func (d *Decoder) skipQuoted(quote byte) error {
+	for d.pos++; d.pos < len(d.buf); d.pos++ {
+		switch d.buf[d.pos] {
+		case '\\':
+			d.pos++
+		case quote:
+			d.pos++
+			return nil
+		}
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// Consume a quoted string, starting at the open quote, and return its
+// content with escapes processed.  Escapes are as in JSON, plus `\'`.
+func (d *Decoder) decodeQuoted() ([]byte, error) {
+	quote := d.buf[d.pos]
+	start := d.pos + 1
+	if err := d.skipQuoted(quote); err != nil {
+		return nil, err
+	}
+	s := d.buf[start : d.pos-1]
+	// Fast path: no escapes.
+	escaped := false
+	for _, c := range s {
+		if c == '\\' {
+			escaped = true
+			break
+		}
+	}
+	if !escaped {
+		return s, nil
+	}
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b = append(b, s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case '"', '\\', '/', '\'':
+			b = append(b, s[i])
+		case 'b':
+			b = append(b, '\b')
+		case 'f':
+			b = append(b, '\f')
+		case 'n':
+			b = append(b, '\n')
+		case 'r':
+			b = append(b, '\r')
+		case 't':
+			b = append(b, '\t')
+		case 'u':
+			r := getu4(s[i+1:])
+			if r < 0 {
+				return nil, d.errorf("invalid \\u escape in string")
+			}
+			i += 4
+			if utf16.IsSurrogate(r) && i+6 < len(s) && s[i+1] == '\\' && s[i+2] == 'u' {
+				if dec := utf16.DecodeRune(r, getu4(s[i+3:])); dec != utf8.RuneError {
+					r = dec
+					i += 6
+				}
+			}
+			var tmp [utf8.UTFMax]byte
+			b = append(b, tmp[:utf8.EncodeRune(tmp[:], r)]...)
+		default:
+			return nil, d.errorf("invalid escape %q in string", s[i])
+		}
+	}
+	return b, nil
+}
+
+// getu4 decodes four hex digits from the beginning of s, returning the value,
+// or it returns -1.
+func getu4(s []byte) rune {
+	if len(s) < 4 {
+		return -1
+	}
+	r, err := strconv.ParseUint(string(s[:4]), 16, 32)
+	if err != nil {
+		return -1
+	}
+	return rune(r)
+}
+
+func (d *Decoder) decodeString() (string, error) {
+	b, err := d.decodeQuoted()
+	return string(b), err
+}
+
+// Byte strings may be written `h'0102'`, `b64'AQI'`, or `'text'`.
+func (d *Decoder) decodeBytes() ([]byte, error) {
+	var prefix []byte
+	for start := d.pos; d.pos < len(d.buf) && d.buf[d.pos] != '\''; d.pos++ {
+		prefix = d.buf[start : d.pos+1]
+	}
+	if d.pos == len(d.buf) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	quoteAt := d.pos
+	switch string(prefix) {
+	case "":
+		b, err := d.decodeQuoted()
+		return append([]byte{}, b...), err
+	case "h", "b64":
+		if err := d.skipQuoted('\''); err != nil {
+			return nil, err
+		}
+	default:
+		d.pos -= len(prefix)
+		return nil, d.errorf("unsupported byte string prefix %q", prefix)
+	}
+	// Whitespace is allowed (and ignored) in the encoded content.
+	content := make([]byte, 0, d.pos-quoteAt)
+	for _, c := range d.buf[quoteAt+1 : d.pos-1] {
+		switch c {
+		case ' ', '\t', '\r', '\n':
+		default:
+			content = append(content, c)
+		}
+	}
+	if prefix[0] == 'h' {
+		b, err := hex.DecodeString(string(content))
+		if err != nil {
+			return nil, d.errorf("invalid hex in byte string")
+		}
+		return b, nil
+	}
+	// Base64 may use either alphabet, and padding is optional.
+	for len(content) > 0 && content[len(content)-1] == '=' {
+		content = content[:len(content)-1]
+	}
+	enc := base64.RawStdEncoding
+	for _, c := range content {
+		if c == '-' || c == '_' {
+			enc = base64.RawURLEncoding
+			break
+		}
+	}
+	b, err := enc.DecodeString(string(content))
+	if err != nil {
+		return nil, d.errorf("invalid base64 in byte string")
+	}
+	return b, nil
+}
+
+// Numbers are integers if they have no decimal point or exponent.
+// Hex, octal and binary integers (`0x1f`, `0o17`, `0b11`) are accepted too,
+// and so are encoding indicators (`1.5_1`), which set the token's Width.
+func (d *Decoder) decodeNumber(tokenSlot *Token) error {
+	start := d.pos
+	neg := d.buf[d.pos] == '-'
+	if neg {
+		d.pos++
+	}
+	digits := d.pos
+	switch {
+	case d.hasWord("Infinity"):
+		tokenSlot.Type = TFloat64
+		tokenSlot.Float64 = math.Inf(1)
+		if neg {
+			tokenSlot.Float64 = math.Inf(-1)
+		}
+		return d.decodeFloatWidth(tokenSlot)
+	case !neg && d.hasWord("NaN"):
+		tokenSlot.Type = TFloat64
+		tokenSlot.Float64 = math.Float64frombits(0x7ff8000000000000) // The usual quiet NaN.
+		return d.decodeFloatWidth(tokenSlot)
+	}
+	base := 10
+	if d.pos+1 < len(d.buf) && d.buf[d.pos] == '0' {
+		switch d.buf[d.pos+1] {
+		case 'x':
+			base = 16
+		case 'o':
+			base = 8
+		case 'b':
+			base = 2
+		}
+		if base != 10 {
+			d.pos += 2
+			digits = d.pos
+		}
+	}
+	isFloat := false
+	for ; d.pos < len(d.buf); d.pos++ {
+		c := d.buf[d.pos]
+		switch {
+		case '0' <= c && c <= '9':
+		case base == 16 && ('a' <= c && c <= 'f' || 'A' <= c && c <= 'F'):
+		case base == 10 && (c == '.' || c == 'e' || c == 'E'):
+			isFloat = true
+		case base == 10 && (c == '+' || c == '-') && (d.buf[d.pos-1] == 'e' || d.buf[d.pos-1] == 'E'):
+		default:
+			goto end
+		}
+	}
+end:
+	num := string(d.buf[digits:d.pos])
+	if isFloat {
+		f, err := strconv.ParseFloat(num, 64)
+		if err != nil {
+			d.pos = start
+			return d.errorf("invalid number")
+		}
+		if neg {
+			f = -f
+		}
+		tokenSlot.Type = TFloat64
+		tokenSlot.Float64 = f
+		return d.decodeFloatWidth(tokenSlot)
+	}
+	u, err := strconv.ParseUint(num, base, 64)
+	if err != nil {
+		d.pos = start
+		return d.errorf("invalid integer")
+	}
+	arg := u
+	if !neg {
+		tokenSlot.Type = TUint
+		tokenSlot.Uint = u
+	} else if u > 1<<63 {
+		d.pos = start
+		return d.errorf("negative integer out of range")
+	} else {
+		tokenSlot.Type = TInt
+		tokenSlot.Int = -int64(u)
+		if u > 0 {
+			arg = u - 1 // Negative integers are encoded as -1 minus their argument.
+		}
+	}
+	if tokenSlot.Width, err = d.decodeEncodingIndicator(false); err != nil {
+		return err
+	}
+	if tokenSlot.Width != 0 && tokenSlot.Width < argWidth(arg) {
+		return d.errorf("integer doesn't fit the encoding indicator")
+	}
+	return nil
+}
+
+// Floats without an encoding indicator are the shortest width that holds
+// them exactly, since that's what diagnostic notation means by default.
+func (d *Decoder) decodeFloatWidth(tokenSlot *Token) (err error) {
+	if tokenSlot.Width, err = d.decodeEncodingIndicator(true); err != nil {
+		return err
+	}
+	shortest := floatWidth(tokenSlot.Float64)
+	switch {
+	case tokenSlot.Width == 0:
+		tokenSlot.Width = shortest
+	case tokenSlot.Width < shortest && tokenSlot.Float64 == tokenSlot.Float64:
+		return d.errorf("float doesn't fit the encoding indicator exactly")
+	}
+	return nil
+}
+
+// Consume an encoding indicator, `_0` through `_3`, if present, and return
+// the width in bytes it gives: for floats, `_1` through `_3` are float16,
+// float32, and float64; for anything else, `_0` through `_3` are arguments
+// of 1, 2, 4, and 8 bytes.  Returns zero if there's no indicator.
+func (d *Decoder) decodeEncodingIndicator(float bool) (int, error) {
+	if d.pos+1 >= len(d.buf) || d.buf[d.pos] != '_' {
+		return 0, nil
+	}
+	c := d.buf[d.pos+1]
+	if c < '0' || c > '9' {
+		return 0, nil
+	}
+	if c > '3' || float && c == '0' {
+		return 0, d.errorf("invalid encoding indicator")
+	}
+	d.pos += 2
+	return 1 << (c - '0'), nil
+}
+
+// If the input continues with the given word, consume it and return true.
+func (d *Decoder) hasWord(w string) bool {
+	if len(d.buf)-d.pos < len(w) || string(d.buf[d.pos:d.pos+len(w)]) != w {
+		return false
+	}
+	d.pos += len(w)
+	return true
+}
+
+func (d *Decoder) decodeWord(tokenSlot *Token) error {
+	switch {
+	case d.hasWord("true"):
+		tokenSlot.Type = TBool
+		tokenSlot.Bool = true
+	case d.hasWord("false"):
+		tokenSlot.Type = TBool
+		tokenSlot.Bool = false
+	case d.hasWord("null"):
+		tokenSlot.Type = TNull
+	case d.hasWord("undefined"):
+		tokenSlot.Type = TUndefined
+	case d.hasWord("simple("):
+		start := d.pos
+		for d.pos < len(d.buf) && '0' <= d.buf[d.pos] && d.buf[d.pos] <= '9' {
+			d.pos++
+		}
+		v, err := strconv.ParseUint(string(d.buf[start:d.pos]), 10, 8)
+		if err != nil || (v >= 20 && v < 32) {
+			d.pos = start
+			return d.errorf("invalid simple value")
+		}
+		if err := d.expect(')'); err != nil {
+			return err
+		}
+		tokenSlot.Type = TSimple
+		tokenSlot.Uint = v
+	default:
+		return d.errorf("invalid character %q while expecting start of value", d.buf[d.pos])
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package diag
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/polydawn/refmt/cbor"
+	"github.com/polydawn/refmt/shared"
+)
+
+func TestDiagDecoder(t *testing.T) {
+	tt := []struct {
+		diag   string
+		serial []byte
+	}{
+		// Other ways of writing things, which the encoder doesn't emit.
+		{` [ 1 ,2 ] `, h("820102")},
+		{`[1, /two/ 2]`, h("820102")},
+		{`0x1f`, h("181f")},
+		{`-0b11`, h("22")},
+		{`0o17`, h("0f")},
+		{`1e3`, h("f963d0")},
+		{`1.5_1`, h("f93e00")},
+		{`1.1`, h("fb3ff199999999999a")},
+		{`-0`, h("00")},
+		{`0x1f_1`, h("19001f")},
+		{`-Infinity_2`, h("faff800000")},
+		{`[_0 1, 2]`, h("98020102")},
+		{`'hi'`, h("426869")},
+		{`'it\'s'`, h("44697427" + "73")},
+		{`b64'AQL_'`, h("430102ff")},
+		{`b64'AQL/'`, h("430102ff")},
+		{`h'01 02'`, h("420102")},
+		{`"😀"`, h("64f09f9880")},
+		{`"\/"`, h("612f")},
+	}
+	for _, tr := range diagFixtures {
+		tt = append(tt, struct {
+			diag   string
+			serial []byte
+		}{tr.diag, tr.serial})
+	}
+	for _, tr := range tt {
+		var buf bytes.Buffer
+		err := shared.TokenPump{
+			TokenSource: NewDecoder(strings.NewReader(tr.diag)),
+			TokenSink:   cbor.NewEncoderWithOptions(cbor.EncodeOptions{PreserveWidths: true}, &buf),
+		}.Run()
+		if err != nil {
+			t.Errorf("test %q errored: %s", tr.diag, err)
+		}
+		if !bytes.Equal(buf.Bytes(), tr.serial) {
+			t.Errorf("test %q failed: expected %x, got %x", tr.diag, tr.serial, buf.Bytes())
+		}
+	}
+}
+
+func TestDiagDecoderErrors(t *testing.T) {
+	tt := []struct {
+		diag      string
+		expectErr string
+	}{
+		{``, io.EOF.Error()},
+		{`[1, 2`, io.ErrUnexpectedEOF.Error()},
+		{`42(1`, io.ErrUnexpectedEOF.Error()},
+		{`[1 2]`, `diag: expected comma or ']' at offset 3`},
+		{`[1, ]`, `diag: unexpected ']' after comma at offset 4`},
+		{`{"a" 1}`, `diag: expected ':' at offset 5`},
+		{`{[1]: 2}`, `diag: maps and arrays as map keys are not supported at offset 2`},
+		{`h'0'`, `diag: invalid hex in byte string at offset 4`},
+		{`b32'00'`, `diag: unsupported byte string prefix "b32" at offset 0`},
+		{`(_ h'01', h'02')`, `diag: indefinite-length strings are not supported at offset 0`},
+		{`["a", (_ "b", "c")]`, `diag: indefinite-length strings are not supported at offset 6`},
+		{`simple(21)`, `diag: invalid simple value at offset 7`},
+		{`1.1_2`, `diag: float doesn't fit the encoding indicator exactly at offset 5`},
+		{`1.5_0`, `diag: invalid encoding indicator at offset 3`},
+		{`1_4`, `diag: invalid encoding indicator at offset 1`},
+		{`256_0`, `diag: integer doesn't fit the encoding indicator at offset 5`},
+		{`-257_0`, `diag: integer doesn't fit the encoding indicator at offset 6`},
+		{`[_7 1]`, `diag: invalid encoding indicator at offset 1`},
+		{`-18446744073709551616`, `diag: invalid integer at offset 0`},
+		{`-9223372036854775809`, `diag: negative integer out of range at offset 0`},
+		{`nope`, `diag: invalid character 'n' while expecting start of value at offset 0`},
+		{`[1] /x`, ``},
+		{`/x`, `diag: unterminated comment at offset 0`},
+	}
+	for _, tr := range tt {
+		err := shared.TokenPump{
+			TokenSource: NewDecoder(strings.NewReader(tr.diag)),
+			TokenSink:   cbor.NewEncoderWithOptions(cbor.EncodeOptions{PreserveWidths: true}, &bytes.Buffer{}),
+		}.Run()
+		errStr := ""
+		if err != nil {
+			errStr = err.Error()
+		}
+		if errStr != tr.expectErr {
+			t.Errorf("test %q: expected error %q, got %q", tr.diag, tr.expectErr, errStr)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package diag
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/polydawn/refmt/shared"
+	. "github.com/polydawn/refmt/tok"
+)
+
+/*
+	A diag.Encoder is a TokenSink implementation that emits CBOR
+	diagnostic notation.
+*/
+type Encoder struct {
+	wr io.Writer
+
+	// Stack, tracking how many array and map opens are outstanding.
+	// (Values are only 'phase_mapExpectKeyOrEnd' and 'phase_arrExpectValueOrEnd'.)
+	stack   []phase
+	current phase // shortcut to value at end of stack
+	some    bool  // set to true after first value in any context; use to append commas.
+	tags    []int // Number of tags wrapped around each open map and array, to close after it.
+
+	// Spare memory, for use in operations on leaf nodes (e.g. temp space for an int serialization).
+	scratch [64]byte
+}
+
+func NewEncoder(wr io.Writer) *Encoder {
+	return &Encoder{
+		wr:    wr,
+		stack: make([]phase, 0, 10),
+		tags:  make([]int, 0, 10),
+	}
+}
+
+func (d *Encoder) Reset() {
+	d.stack = d.stack[0:0]
+	d.current = phase_anyExpectValue
+	d.some = false
+	d.tags = d.tags[0:0]
+}
+
+type phase int
+
+const (
+	phase_anyExpectValue phase = iota
+	phase_mapExpectKeyOrEnd
+	phase_mapExpectValue
+	phase_arrExpectValueOrEnd
+)
+
+func (d *Encoder) Step(tok *Token) (done bool, err error) {
+	switch d.current {
+	case phase_anyExpectValue:
+		switch tok.Type {
+		case TMapOpen, TArrOpen:
+			d.openComposite(tok)
+			return false, nil
+		case TMapClose:
+			return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "start of value"}
+		case TArrClose:
+			return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "start of value"}
+		default:
+			// It's a value; handle it.
+			return true, d.flushValue(tok)
+		}
+	case phase_mapExpectKeyOrEnd:
+		switch tok.Type {
+		case TMapOpen:
+			return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "map key or end of map"}
+		case TArrOpen:
+			return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "map key or end of map"}
+		case TMapClose:
+			d.wr.Write(wordMapClose)
+			return d.popPhase()
+		case TArrClose:
+			return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "map key or end of map"}
+		default:
+			// It's a key.  Diagnostic notation is happy with any kind of key.
+			d.entrySep()
+			if err := d.flushValue(tok); err != nil {
+				return true, err
+			}
+			d.wr.Write(wordColon)
+			d.current = phase_mapExpectValue
+			return false, nil
+		}
+	case phase_mapExpectValue:
+		switch tok.Type {
+		case TMapOpen, TArrOpen:
+			d.current = phase_mapExpectKeyOrEnd
+			d.openComposite(tok)
+			return false, nil
+		case TMapClose:
+			return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "start of value"}
+		case TArrClose:
+			return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "start of value"}
+		default:
+			// It's a value; handle it.
+			d.current = phase_mapExpectKeyOrEnd
+			return false, d.flushValue(tok)
+		}
+	case phase_arrExpectValueOrEnd:
+		switch tok.Type {
+		case TMapOpen, TArrOpen:
+			d.entrySep()
+			d.openComposite(tok)
+			return false, nil
+		case TMapClose:
+			return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "start of value or end of array"}
+		case TArrClose:
+			d.wr.Write(wordArrClose)
+			return d.popPhase()
+		default:
+			// It's a value; handle it.
+			d.entrySep()
+			return false, d.flushValue(tok)
+		}
+	default:
+		panic("Unreachable")
+	}
+}
+
+// Emit the tags and opening bracket of a map or array, and push a phase for it.
+func (d *Encoder) openComposite(tok *Token) {
+	d.tags = append(d.tags, d.emitTags(tok))
+	if tok.Type == TMapOpen {
+		d.pushPhase(phase_mapExpectKeyOrEnd)
+		d.wr.Write(wordMapOpen)
+	} else {
+		d.pushPhase(phase_arrExpectValueOrEnd)
+		d.wr.Write(wordArrOpen)
+	}
+	if tok.Length < 0 {
+		d.wr.Write(wordIndef)
+	} else if tok.Width != 0 && tok.Width != argWidth(uint64(tok.Length)) {
+		io.WriteString(d.wr, argIndicator(tok.Width))
+		d.writeByte(' ')
+	}
+}
+
+func (d *Encoder) pushPhase(p phase) {
+	d.current = p
+	d.stack = append(d.stack, d.current)
+	d.some = false
+}
+
+// Pop a phase from the stack, closing any tags around the composite just ended;
+// return 'true' if stack now empty.
+func (d *Encoder) popPhase() (bool, error) {
+	nt := len(d.tags) - 1
+	d.closeTags(d.tags[nt])
+	d.tags = d.tags[0:nt]
+	n := len(d.stack) - 1
+	if n == 0 {
+		return true, nil
+	}
+	if n < 0 { // the state machines are supposed to have already errored better
+		panic("diagEncoder stack overpopped")
+	}
+	d.current = d.stack[n-1]
+	d.stack = d.stack[0:n]
+	d.some = true
+	return false, nil
+}
+
+// Emit an entry separater (comma), unless we're at the start of an object.
+// Mark that we *do* have some content, regardless, so next time will need a sep.
+func (d *Encoder) entrySep() {
+	if d.some {
+		d.wr.Write(wordComma)
+	}
+	d.some = true
+}
+
+func (d *Encoder) flushValue(tok *Token) error {
+	n := d.emitTags(tok)
+	switch tok.Type {
+	case TString:
+		d.emitString(tok.Str)
+	case TBytes:
+		d.emitBytes(tok.Bytes)
+	case TBool:
+		switch tok.Bool {
+		case true:
+			d.wr.Write(wordTrue)
+		case false:
+			d.wr.Write(wordFalse)
+		}
+	case TInt:
+		d.emitInt(tok.Int, tok.Width)
+	case TUint:
+		d.emitUint(tok.Uint, tok.Width)
+	case TFloat64:
+		d.emitFloat(tok.Float64, tok.Width)
+	case TNull:
+		d.wr.Write(wordNull)
+	case TUndefined:
+		d.wr.Write(wordUndefined)
+	case TSimple:
+		d.emitSimple(tok.Uint)
+	default:
+		return fmt.Errorf("diag: cannot encode token %s", tok)
+	}
+	d.closeTags(n)
+	return nil
+}
@@ -0,0 +1,181 @@
+package diag
+
+import (
+	"io"
+	"math"
+	"strconv"
+	"unicode/utf8"
+
+	. "github.com/polydawn/refmt/tok"
+)
+
+var hexDigits = "0123456789abcdef"
+
+// Emit the opening half of each tag on a token, outermost first,
+// and return how many closing parens will be needed after the value.
+func (d *Encoder) emitTags(tok *Token) int {
+	if !tok.Tagged {
+		return 0
+	}
+	for _, tag := range tok.OuterTags {
+		d.emitTag(tag)
+	}
+	d.emitTag(tok.Tag)
+	return len(tok.OuterTags) + 1
+}
+
+func (d *Encoder) emitTag(tag uint64) {
+	d.wr.Write(strconv.AppendUint(d.scratch[:0], tag, 10))
+	d.writeByte('(')
+}
+
+func (d *Encoder) closeTags(n int) {
+	for ; n > 0; n-- {
+		d.wr.Write(wordTagClose)
+	}
+}
+
+// Integers (and floats) get an encoding indicator if they're given a width
+// other than the shortest.
+func (d *Encoder) emitInt(v int64, width int) {
+	d.wr.Write(strconv.AppendInt(d.scratch[:0], v, 10))
+	if v < 0 {
+		d.emitArgIndicator(uint64(-1-v), width)
+	} else {
+		d.emitArgIndicator(uint64(v), width)
+	}
+}
+
+func (d *Encoder) emitUint(v uint64, width int) {
+	d.wr.Write(strconv.AppendUint(d.scratch[:0], v, 10))
+	d.emitArgIndicator(v, width)
+}
+
+func (d *Encoder) emitArgIndicator(arg uint64, width int) {
+	if width != 0 && width != argWidth(arg) {
+		io.WriteString(d.wr, argIndicator(width))
+	}
+}
+
+func (d *Encoder) emitSimple(v uint64) {
+	b := append(d.scratch[:0], "simple("...)
+	b = strconv.AppendUint(b, v, 10)
+	b = append(b, ')')
+	d.wr.Write(b)
+}
+
+// Floats are written with the fewest digits that read back as the same value,
+// and always with a decimal point, so they can't be mistaken for integers.
+func (d *Encoder) emitFloat(f float64, width int) {
+	d.emitFloatValue(f)
+	if width != 0 && width != floatWidth(f) {
+		io.WriteString(d.wr, floatIndicator(width))
+	}
+}
+
+func (d *Encoder) emitFloatValue(f float64) {
+	switch {
+	case math.IsNaN(f):
+		d.wr.Write(wordNaN)
+		return
+	case math.IsInf(f, 1):
+		d.wr.Write(wordInf)
+		return
+	case math.IsInf(f, -1):
+		d.wr.Write(wordNegInf)
+		return
+	}
+	b := strconv.AppendFloat(d.scratch[:0], f, 'g', -1, 64)
+	mant := len(b)
+	for i, c := range b {
+		if c == '.' {
+			d.wr.Write(b)
+			return
+		}
+		if c == 'e' {
+			mant = i
+			break
+		}
+	}
+	// No decimal point: insert ".0" at the end of the mantissa.
+	d.wr.Write(b[:mant])
+	io.WriteString(d.wr, ".0")
+	d.wr.Write(b[mant:])
+}
+
+func (d *Encoder) emitBytes(v []byte) {
+	d.writeByte('h')
+	d.writeByte('\'')
+	for len(v) > 0 {
+		chunk := v
+		if len(chunk) > len(d.scratch)/2 {
+			chunk = chunk[:len(d.scratch)/2]
+		}
+		for i, b := range chunk {
+			d.scratch[i*2] = hexDigits[b>>4]
+			d.scratch[i*2+1] = hexDigits[b&0xF]
+		}
+		d.wr.Write(d.scratch[:len(chunk)*2])
+		v = v[len(chunk):]
+	}
+	d.writeByte('\'')
+}
+
+// Strings are written like JSON strings, except that unicode is left as-is.
+func (d *Encoder) emitString(s string) {
+	d.writeByte('"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if 0x20 <= b && b != '\\' && b != '"' && b != 0x7f {
+				i++
+				continue
+			}
+			if start < i {
+				io.WriteString(d.wr, s[start:i])
+			}
+			switch b {
+			case '\\', '"':
+				d.writeByte('\\')
+				d.writeByte(b)
+			case '\n':
+				d.writeByte('\\')
+				d.writeByte('n')
+			case '\r':
+				d.writeByte('\\')
+				d.writeByte('r')
+			case '\t':
+				d.writeByte('\\')
+				d.writeByte('t')
+			default:
+				// This encodes control characters except for \t, \n and \r.
+				io.WriteString(d.wr, `\u00`)
+				d.writeByte(hexDigits[b>>4])
+				d.writeByte(hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			if start < i {
+				io.WriteString(d.wr, s[start:i])
+			}
+			io.WriteString(d.wr, `\ufffd`)
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	if start < len(s) {
+		io.WriteString(d.wr, s[start:])
+	}
+	d.writeByte('"')
+}
+
+func (d *Encoder) writeByte(b byte) {
+	d.scratch[0] = b
+	d.wr.Write(d.scratch[0:1])
+}
@@ -0,0 +1,25 @@
+package diag
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/polydawn/refmt/cbor"
+	"github.com/polydawn/refmt/shared"
+)
+
+func TestDiagEncoder(t *testing.T) {
+	for _, tr := range diagFixtures {
+		var buf bytes.Buffer
+		err := shared.TokenPump{
+			TokenSource: cbor.NewDecoder(bytes.NewBuffer(tr.serial)),
+			TokenSink:   NewEncoder(&buf),
+		}.Run()
+		if err != nil {
+			t.Errorf("test %q errored: %s", tr.diag, err)
+		}
+		if buf.String() != tr.diag {
+			t.Errorf("test %q failed: got %s", tr.diag, buf.String())
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package diag
+
+import (
+	"encoding/hex"
+)
+
+// Pairs of diagnostic notation and the CBOR it describes.
+// The diagnostic notation is exactly how the Encoder writes it,
+// and the CBOR is exactly what `cbor.Encoder` writes for the tokens
+// the Decoder yields from it.
+var diagFixtures = []struct {
+	diag   string
+	serial []byte
+}{
+	{`0`, h("00")},
+	{`24`, h("1818")},
+	{`18446744073709551615`, h("1bffffffffffffffff")},
+	{`-1`, h("20")},
+	{`-9223372036854775808`, h("3b7fffffffffffffff")},
+	{`0_0`, h("1800")},
+	{`24_1`, h("190018")},
+	{`-1_2`, h("3a00000000")},
+	{`1_3`, h("1b0000000000000001")},
+	{`1.5`, h("f93e00")},
+	{`1.5_2`, h("fa3fc00000")},
+	{`1.5_3`, h("fb3ff8000000000000")},
+	{`100000.0`, h("fa47c35000")},
+	{`5.960464477539063e-08`, h("f90001")},
+	{`-0.0`, h("f98000")},
+	{`1.0e+300`, h("fb7e37e43c8800759c")},
+	{`Infinity`, h("f97c00")},
+	{`-Infinity`, h("f9fc00")},
+	{`NaN`, h("f97e00")},
+	{`NaN_3`, h("fb7ff8000000000000")},
+	{`true`, h("f5")},
+	{`null`, h("f6")},
+	{`undefined`, h("f7")},
+	{`simple(16)`, h("f0")},
+	{`simple(255)`, h("f8ff")},
+	{`""`, h("60")},
+	{`"a\"\\\n\u0001ü"`, h("6761225c0a01c3bc")},
+	{`h''`, h("40")},
+	{`h'0102ff'`, h("430102ff")},
+	{`[]`, h("80")},
+	{`[1, [2, 3]]`, h("8201820203")},
+	{`[_ 1, [2, 3]]`, h("9f01820203ff")},
+	{`[_ ]`, h("9fff")},
+	{`[_0 1]`, h("980101")},
+	{`[_1 ]`, h("990000")},
+	{`{}`, h("a0")},
+	{`{"a": 1, "b": [2]}`, h("a2616101616281" + "02")},
+	{`{_ "a": {_ }}`, h("bf6161bfffff")},
+	{`{_3 1: 2}`, h("bb00000000000000010102")},
+	{`{1: 2, -1: h'00'}`, h("a2010220" + "4100")},
+	{`42(h'cafe')`, h("d82a42cafe")},
+	{`55799(42(h'cafe'))`, h("d9d9f7d82a42cafe")},
+	{`[1(2), 3([_ ])]`, h("82c102c39fff")},
+	{`5({"a": 6(7)})`, h("c5a16161c607")},
+}
+
+func h(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
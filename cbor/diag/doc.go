@@ -0,0 +1,34 @@
+/*
+	Package diag implements CBOR diagnostic notation, as described in
+	RFC 8949 section 8 (and extended in RFC 8610 appendix G): a human-readable
+	text form of CBOR, handy for debugging and for writing test fixtures.
+
+		{"a": 1, "b": [_ h'0102', 42(h'cafe')], "c": 1.5}
+
+	The `diag.Encoder` is a TokenSink which emits diagnostic notation,
+	and the `diag.Decoder` is a TokenSource which reads it;
+	pump either to or from a `cbor.Encoder` or `cbor.Decoder` to convert.
+
+	Tags (including nested tags), byte strings, simple values, and the
+	indefinite-length markers on maps and arrays are all carried through.
+	Indefinite-length byte and text strings, written `(_ h'01', h'02')`,
+	aren't supported: tokens have no way to carry their chunks, so rather
+	than joining the chunks into one definite-length string, the Decoder
+	rejects them.  (`cbor.Decoder` joins them, so the Encoder never
+	sees any.)
+
+	Floats are written so that they always read back as floats, with
+	exactly the same value.
+
+	Encoding indicators say how many bytes an item takes in CBOR, and are
+	carried in the token's `Width`: on floats, `_1`, `_2`, and `_3` mean
+	float16, float32, and float64 (`1.5_3`); on integers, and after the
+	opening bracket of a map or array, `_0` through `_3` mean an argument
+	of 1, 2, 4, or 8 bytes (`1_0`, `[_1 1]`).  The Encoder writes them only
+	where the width isn't the shortest; correspondingly, the Decoder gives
+	floats without one the shortest width that holds them exactly, as the
+	notation prescribes.  To keep them when converting to CBOR, set
+	`cbor.EncodeOptions.PreserveWidths`.  Encoding indicators on strings
+	aren't supported.
+*/
+package diag
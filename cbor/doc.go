@@ -23,5 +23,9 @@
 	The `cbor.Encoder` and `cbor.Decoder` types implement the low-level functionality
 	of converting serial CBOR byte streams into refmt Token streams.
 	Users don't usually need to use these directly.
+
+	For CBOR Sequences (RFC 8742) -- many items simply concatenated, as in a
+	log -- use `cbor.NewSequenceMarshaller` and `cbor.NewSequenceUnmarshaller`
+	(or the `SequenceEncoder` and `SequenceDecoder` token-level types).
 */
 package cbor
@@ -7,6 +7,7 @@ import (
 
 var (
 	_ quickWriter = &quickWriterStream{}
+	_ quickWriter = &quickWriterBuffer{}
 )
 
 // quickWriter is implements several methods that are specificly useful to the performance
@@ -105,3 +106,16 @@ func (z *quickWriterStream) checkErr() error {
 func (z *quickWriterStream) clearErr() {
 	z.err = nil
 }
+
+// quickWriterBuffer is a quickWriter that accumulates bytes in memory.
+// It never errors.
+type quickWriterBuffer struct {
+	buf []byte
+}
+
+func (z *quickWriterBuffer) writeb(bs []byte)    { z.buf = append(z.buf, bs...) }
+func (z *quickWriterBuffer) writestr(s string)   { z.buf = append(z.buf, s...) }
+func (z *quickWriterBuffer) writen1(b byte)      { z.buf = append(z.buf, b) }
+func (z *quickWriterBuffer) writen2(b1, b2 byte) { z.buf = append(z.buf, b1, b2) }
+func (z *quickWriterBuffer) checkErr() error     { return nil }
+func (z *quickWriterBuffer) clearErr()           {}
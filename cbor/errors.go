@@ -2,21 +2,29 @@ package cbor
 
 import (
 	"fmt"
-
-	. "github.com/polydawn/refmt/tok"
 )
 
-// Error raised by Encoder when invalid tokens or invalid ordering, e.g. a MapClose with no matching open.
-// Should never be seen by the user in practice unless generating their own token streams.
-type ErrInvalidTokenStream struct {
-	Got        Token
-	Acceptable []TokenType
+// Describes what's acceptable next in each encoder phase.
+// The Encoder reports invalid tokens or invalid ordering, e.g. a MapClose
+// with no matching open, as a `shared.ErrMalformedTokenStream` saying this,
+// like every other codec.
+func (p encoderPhase) expected() string {
+	switch p {
+	case phase_mapDefExpectKeyOrEnd, phase_mapIndefExpectKeyOrEnd:
+		return "map key or end of map"
+	case phase_arrDefExpectValueOrEnd, phase_arrIndefExpectValueOrEnd:
+		return "start of value or end of array"
+	}
+	return "start of value"
 }
 
-func (e *ErrInvalidTokenStream) Error() string {
-	return fmt.Sprintf("ErrInvalidTokenStream: unexpected %v, expected %v", e.Got, e.Acceptable)
-	// More comprehensible strings might include "start of value", "start of key or end of map", "start of value or end of array".
+// Error raised by Decoder in strict mode when the input is well-formed
+// but not in the canonical (deterministic) encoding.
+type ErrNonCanonical struct {
+	Offset int    // Byte offset of the start of the offending item, counted from the start of the value.
+	Reason string // What's wrong with it.
 }
 
-var tokenTypesForKey = []TokenType{TString, TInt, TUint}
-var tokenTypesForValue = []TokenType{TMapOpen, TArrOpen, TNull, TString, TBytes, TInt, TUint, TFloat64}
+func (e *ErrNonCanonical) Error() string {
+	return fmt.Sprintf("cbor: non-canonical encoding at byte %d: %s", e.Offset, e.Reason)
+}
@@ -0,0 +1,150 @@
+package cid
+
+import (
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/polydawn/refmt/misc"
+)
+
+/*
+	Cid is a content identifier: a self-describing hash, which IPLD uses
+	to link one document to another.
+
+	A Cid holds the binary form, and is comparable, so it can be used as a
+	map key.  The zero value is not a valid CID; see `Defined`.
+*/
+type Cid struct {
+	b string // The binary form.
+}
+
+// Error returned when parsing or casting something that isn't a valid CID.
+type ErrInvalidCid struct {
+	Reason string
+}
+
+func (e ErrInvalidCid) Error() string {
+	return "invalid cid: " + e.Reason
+}
+
+// The base32 flavor multibase calls "b": lowercase, no padding.
+var base32Lower = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+/*
+	Cast validates the binary form of a CID, and returns it as a Cid.
+
+	Both versions are accepted: a CIDv0 is a bare sha2-256 multihash;
+	a CIDv1 is the version, the content codec, and then any multihash,
+	each of the numbers being an unsigned varint.
+*/
+func Cast(b []byte) (Cid, error) {
+	if len(b) == 34 && b[0] == 0x12 && b[1] == 0x20 {
+		return Cid{string(b)}, nil
+	}
+	version, n := binary.Uvarint(b)
+	if n <= 0 {
+		return Cid{}, ErrInvalidCid{"missing version"}
+	}
+	if version != 1 {
+		return Cid{}, ErrInvalidCid{fmt.Sprintf("unknown version %d", version)}
+	}
+	// The rest is the codec, and then the multihash: its code, the digest length, and the digest.
+	rest := b[n:]
+	var length uint64
+	for _, field := range []string{"codec", "multihash code", "multihash length"} {
+		length, n = binary.Uvarint(rest)
+		if n <= 0 {
+			return Cid{}, ErrInvalidCid{"missing or malformed " + field}
+		}
+		rest = rest[n:]
+	}
+	if uint64(len(rest)) != length {
+		return Cid{}, ErrInvalidCid{fmt.Sprintf("multihash length %d doesn't match digest length %d", length, len(rest))}
+	}
+	return Cid{string(b)}, nil
+}
+
+/*
+	Parse reads the string form of a CID.
+
+	CIDv0 is written in bare base58btc (these strings begin with "Qm").
+	CIDv1 is written in multibase: one character naming the base, and then
+	the binary form in that base.  The bases accepted are base32 ("b" or "B"),
+	base58btc ("z"), and hex ("f" or "F").
+*/
+func Parse(s string) (Cid, error) {
+	if len(s) == 46 && s[0:2] == "Qm" {
+		b := misc.Base58Decode(s)
+		if len(b) != 34 {
+			return Cid{}, ErrInvalidCid{"malformed base58"}
+		}
+		return Cast(b)
+	}
+	if len(s) < 2 {
+		return Cid{}, ErrInvalidCid{"too short"}
+	}
+	var b []byte
+	var err error
+	switch s[0] {
+	case 'b':
+		b, err = base32Lower.DecodeString(s[1:])
+	case 'B':
+		b, err = base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(s[1:])
+	case 'z':
+		b = misc.Base58Decode(s[1:])
+		if len(b) == 0 {
+			err = fmt.Errorf("malformed base58")
+		}
+	case 'f', 'F':
+		b, err = hex.DecodeString(s[1:])
+	default:
+		return Cid{}, ErrInvalidCid{fmt.Sprintf("unsupported multibase prefix %q", s[0])}
+	}
+	if err != nil {
+		return Cid{}, ErrInvalidCid{err.Error()}
+	}
+	c, err := Cast(b)
+	if err != nil {
+		return Cid{}, err
+	}
+	if c.Version() == 0 {
+		return Cid{}, ErrInvalidCid{"CIDv0 must be written in bare base58btc"}
+	}
+	return c, nil
+}
+
+// Defined returns false for the zero value, and true for any real CID.
+func (c Cid) Defined() bool {
+	return c.b != ""
+}
+
+// Version returns 0 or 1.
+func (c Cid) Version() int {
+	if len(c.b) == 34 && c.b[0] == 0x12 && c.b[1] == 0x20 {
+		return 0
+	}
+	return 1
+}
+
+// Bytes returns the binary form.
+func (c Cid) Bytes() []byte {
+	return []byte(c.b)
+}
+
+/*
+	String returns the canonical string form: bare base58btc for CIDv0,
+	and multibase base32 (with the "b" prefix) for CIDv1.
+	The zero value returns the empty string.
+*/
+func (c Cid) String() string {
+	switch {
+	case !c.Defined():
+		return ""
+	case c.Version() == 0:
+		return misc.Base58Encode([]byte(c.b))
+	default:
+		return "b" + base32Lower.EncodeToString([]byte(c.b))
+	}
+}
@@ -0,0 +1,109 @@
+package cid
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/polydawn/refmt/cbor"
+	"github.com/polydawn/refmt/obj/atlas"
+	. "github.com/polydawn/refmt/tok"
+)
+
+// Both are the sha2-256 of nothing; the v1 one is with the dag-cbor codec.
+const (
+	v0Str = "QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n"
+	v1Str = "bafyreihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku"
+	v1Hex = "01711220e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+func TestParse(t *testing.T) {
+	v1Bin, _ := hex.DecodeString(v1Hex)
+	tt := []struct {
+		str     string
+		version int
+		bin     []byte
+		canon   string
+	}{
+		{v0Str, 0, append([]byte{0x12, 0x20}, v1Bin[4:]...), v0Str},
+		{v1Str, 1, v1Bin, v1Str},
+		{"zdpuB1kFN1Bub2mmZB1rJaF8rypCQop6trg9PSs7nACqwqdvc", 1, v1Bin, v1Str},
+		{"f" + v1Hex, 1, v1Bin, v1Str},
+	}
+	for _, tr := range tt {
+		c, err := Parse(tr.str)
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", tr.str, err)
+			continue
+		}
+		if c.Version() != tr.version {
+			t.Errorf("%q: expected version %d, got %d", tr.str, tr.version, c.Version())
+		}
+		if !bytes.Equal(c.Bytes(), tr.bin) {
+			t.Errorf("%q: expected binary %x, got %x", tr.str, tr.bin, c.Bytes())
+		}
+		if c.String() != tr.canon {
+			t.Errorf("%q: expected string %q, got %q", tr.str, tr.canon, c.String())
+		}
+		if c2, _ := Cast(c.Bytes()); c2 != c {
+			t.Errorf("%q: cast of binary form didn't match", tr.str)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"b",
+		"xabc",                   // unknown multibase
+		"bafyreihdwdcefgh4dqkjv", // truncated
+		"f02711220" + v1Hex[8:],  // version 2
+		"zQmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n", // v0 in multibase
+		"QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR10",  // '0' isn't base58
+	} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestLinks(t *testing.T) {
+	c, _ := Parse(v1Str)
+	var tok Token
+	LinkToken(c, &tok)
+	if !tok.Tagged || tok.Tag != LinkTag || tok.Type != TBytes || tok.Bytes[0] != 0 {
+		t.Errorf("unexpected link token %s", tok)
+	}
+	if c2, err := FromLinkToken(&tok); err != nil || c2 != c {
+		t.Errorf("link token didn't read back: %v, %s", c2, err)
+	}
+	tok.Tag = 43
+	if _, err := FromLinkToken(&tok); err == nil {
+		t.Errorf("expected error for wrong tag")
+	}
+
+	// Through the atlas entry, in cbor: {"link": 42(h'00' followed by the binary CID)}.
+	type Doc struct {
+		Link Cid
+	}
+	atl := atlas.MustBuild(AtlasEntry,
+		atlas.BuildEntry(Doc{}).StructMap().Autogenerate().Complete())
+	serial, err := cbor.MarshalAtlased(Doc{c}, atl)
+	if err != nil {
+		t.Fatalf("marshal failed: %s", err)
+	}
+	expect, _ := hex.DecodeString("a1646c696e6bd82a5825" + "00" + v1Hex)
+	if !bytes.Equal(serial, expect) {
+		t.Errorf("expected %x, got %x", expect, serial)
+	}
+	var doc Doc
+	if err := cbor.UnmarshalAtlased(serial, &doc, atl); err != nil {
+		t.Fatalf("unmarshal failed: %s", err)
+	}
+	if doc.Link != c {
+		t.Errorf("expected %s, got %s", c, doc.Link)
+	}
+	if _, err := cbor.MarshalAtlased(Doc{}, atl); err == nil {
+		t.Errorf("expected error marshalling the zero Cid")
+	}
+}
@@ -0,0 +1,16 @@
+/*
+	Package cid implements CIDs ("content identifiers"), the hashes IPLD
+	uses to link documents together, and their representation as links
+	in refmt token streams.
+
+	Only what's needed to carry CIDs through serialization is here:
+	reading and writing their binary and string forms, and checking they're
+	well-formed.  Nothing in this package computes hashes.
+
+	In token streams, a link is a bytes token tagged with tag 42, holding a
+	zero byte and then the binary CID; see `LinkToken` and `FromLinkToken`.
+	Add `AtlasEntry` to your atlas to marshal `Cid` values as links.
+	The `dagcbor` and `dagjson` packages encode and decode links in
+	their respective forms.
+*/
+package cid
@@ -0,0 +1,74 @@
+package cid
+
+import (
+	"github.com/polydawn/refmt/obj/atlas"
+	. "github.com/polydawn/refmt/tok"
+)
+
+// The CBOR tag which marks links in IPLD.
+const LinkTag = 42
+
+/*
+	AtlasEntry maps `Cid` to an IPLD link: a byte string of the binary CID,
+	with a zero byte prepended (the multibase prefix for "raw binary"),
+	marked with tag 42.
+
+	This is the form DAG-CBOR uses on the wire, and the form the `dagjson`
+	package turns into `{"/": "bafy..."}`.  When unmarshalling, untagged
+	bytes in the same form are also accepted.
+*/
+var AtlasEntry = atlas.BuildEntry(Cid{}).UseTag(LinkTag).Transform().
+	TransformMarshal(atlas.MakeMarshalTransformFunc(
+		func(c Cid) ([]byte, error) {
+			if !c.Defined() {
+				return nil, ErrInvalidCid{"cannot marshal the zero value"}
+			}
+			return linkBytes(c), nil
+		})).
+	TransformUnmarshalTagged(atlas.MakeUnmarshalTaggedTransformFunc(
+		func(tag int, b []byte) (Cid, error) {
+			if tag != -1 && tag != LinkTag {
+				return Cid{}, ErrInvalidCid{"link must have tag 42"}
+			}
+			return castLinkBytes(b)
+		})).
+	Complete()
+
+/*
+	LinkToken fills in `tok` as a link to `c`, in the same form as `AtlasEntry`.
+	Use this when producing token streams by hand.
+*/
+func LinkToken(c Cid, tok *Token) {
+	tok.Type = TBytes
+	tok.Bytes = linkBytes(c)
+	tok.Tagged = true
+	tok.Tag = LinkTag
+	tok.OuterTags = tok.OuterTags[:0]
+}
+
+/*
+	FromLinkToken returns the CID a link token points to.
+
+	It's an error if the token isn't a link: it must be bytes, tagged with
+	tag 42 and no other tags, and contain a zero byte and then a valid CID.
+*/
+func FromLinkToken(tok *Token) (Cid, error) {
+	if !tok.Tagged || tok.Tag != LinkTag || len(tok.OuterTags) > 0 {
+		return Cid{}, ErrInvalidCid{"link must have tag 42 and no other tags"}
+	}
+	if tok.Type != TBytes {
+		return Cid{}, ErrInvalidCid{"link must be bytes"}
+	}
+	return castLinkBytes(tok.Bytes)
+}
+
+func linkBytes(c Cid) []byte {
+	return append([]byte{0}, c.b...)
+}
+
+func castLinkBytes(b []byte) (Cid, error) {
+	if len(b) == 0 || b[0] != 0 {
+		return Cid{}, ErrInvalidCid{"link bytes must begin with a zero byte"}
+	}
+	return Cast(b[1:])
+}
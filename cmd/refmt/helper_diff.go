@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/polydawn/refmt/cbor"
+	"github.com/polydawn/refmt/diff"
+	"github.com/polydawn/refmt/json"
+	"github.com/polydawn/refmt/shared"
+)
+
+/*
+	Open a file as a TokenSource, picking the codec by its extension.
+*/
+func fileTokenSource(filename string) shared.TokenSource {
+	byts, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return errthunkTokenSource{fmt.Errorf("refmt: error reading: %s", err)}
+	}
+	switch filepath.Ext(filename) {
+	case ".json":
+		return json.NewDecoder(bytes.NewReader(byts))
+	case ".cbor":
+		return cbor.NewDecoder(bytes.NewReader(byts))
+	case ".yaml", ".yml":
+		return newYamlTokenSource(bytes.NewReader(byts))
+	default:
+		return errthunkTokenSource{fmt.Errorf("refmt: can't tell the format of %q; expected a .json, .cbor, or .yaml file", filename)}
+	}
+}
+
+/*
+	Diff two files, printing each difference on a line.
+	It's an error if there are any (so the exit code is like diff(1)'s).
+*/
+func diffFiles(a, b string, out io.Writer) error {
+	diffs, err := diff.Diff(fileTokenSource(a), fileTokenSource(b))
+	if err != nil {
+		return err
+	}
+	for _, d := range diffs {
+		fmt.Fprintln(out, d)
+	}
+	if len(diffs) > 0 {
+		return fmt.Errorf("%d differences", len(diffs))
+	}
+	return nil
+}
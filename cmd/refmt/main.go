@@ -151,6 +151,21 @@ func Main(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
 				}.Run()
 			},
 		},
+		//
+		// Comparison
+		//
+		cli.Command{
+			Category:  "compare",
+			Name:      "diff",
+			Usage:     "read two json, cbor, or yaml files (by extension), and list their differences",
+			ArgsUsage: "<file> <file>",
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 2 {
+					return fmt.Errorf("diff needs two files to compare")
+				}
+				return diffFiles(c.Args().Get(0), c.Args().Get(1), stdout)
+			},
+		},
 	}
 	app.Writer = stdout
 	app.ErrWriter = stderr
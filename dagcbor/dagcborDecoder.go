@@ -0,0 +1,49 @@
+package dagcbor
+
+import (
+	"io"
+
+	"github.com/polydawn/refmt/cbor"
+	. "github.com/polydawn/refmt/tok"
+)
+
+// A dagcbor.Decoder is a TokenSource implementation that reads DAG-CBOR,
+// rejecting anything which isn't exactly in that form.
+type Decoder struct {
+	dec   *cbor.Decoder
+	check checker
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return NewDecoderWithOptions(DecodeOptions{}, r)
+}
+
+func NewDecoderWithOptions(cfg DecodeOptions, r io.Reader) *Decoder {
+	return &Decoder{
+		dec: cbor.NewDecoderWithOptions(cbor.DecodeOptions{
+			Strict:             true,
+			FloatMode:          cbor.FloatMode_Float64,
+			MaxDepth:           cfg.MaxDepth,
+			MaxStringLength:    cfg.MaxStringLength,
+			MaxContainerLength: cfg.MaxContainerLength,
+			MaxTotalBytes:      cfg.MaxTotalBytes,
+			MaxTagNesting:      1,
+		}, r),
+	}
+}
+
+func (d *Decoder) Reset() {
+	d.dec.Reset()
+	d.check.reset()
+}
+
+func (d *Decoder) Step(tokenSlot *Token) (done bool, err error) {
+	done, err = d.dec.Step(tokenSlot)
+	if err != nil {
+		return
+	}
+	if err := d.check.step(tokenSlot); err != nil {
+		return true, err
+	}
+	return
+}
@@ -0,0 +1,44 @@
+package dagcbor
+
+import (
+	"io"
+
+	"github.com/polydawn/refmt/cbor"
+	. "github.com/polydawn/refmt/tok"
+)
+
+/*
+	A dagcbor.Encoder is a TokenSink implementation that emits DAG-CBOR.
+
+	Map keys are sorted and lengths filled in as needed, so each map or array
+	is buffered until it ends (as with `cbor.EncodeOptions.Deterministic`).
+*/
+type Encoder struct {
+	enc   *cbor.Encoder
+	check checker
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return NewEncoderWithOptions(EncodeOptions{}, w)
+}
+
+func NewEncoderWithOptions(cfg EncodeOptions, w io.Writer) *Encoder {
+	return &Encoder{
+		enc: cbor.NewEncoderWithOptions(cbor.EncodeOptions{
+			Deterministic: true,
+			FloatMode:     cbor.FloatMode_Float64,
+		}, w),
+	}
+}
+
+func (d *Encoder) Reset() {
+	d.enc.Reset()
+	d.check.reset()
+}
+
+func (d *Encoder) Step(tokenSlot *Token) (done bool, err error) {
+	if err := d.check.step(tokenSlot); err != nil {
+		return true, err
+	}
+	return d.enc.Step(tokenSlot)
+}
@@ -0,0 +1,29 @@
+package dagcbor
+
+type EncodeOptions struct {
+	// future: there's nothing to choose; DAG-CBOR permits only one encoding.
+}
+
+// marker method -- you may use this type to instruct `refmt.Marshal`
+// what kind of encoder to use.
+func (EncodeOptions) IsEncodeOptions() {}
+
+type DecodeOptions struct {
+	// Resource limits, for use when decoding untrusted input.
+	// These are as described for `cbor.DecodeOptions`.
+	// Zero means unlimited.  Exceeding any limit halts decoding with a
+	// `shared.ErrLimitExceeded` error.
+
+	MaxDepth           int   // Maximum nesting depth of maps and arrays.
+	MaxStringLength    int   // Maximum length in bytes of any single string or byte string.
+	MaxContainerLength int   // Maximum number of entries in any single map or array.
+	MaxTotalBytes      int64 // Maximum number of bytes to consume from the reader (per value; counted from `Reset`).
+
+	// Allocation budget for the `obj.Unmarshaller` when using the
+	// unmarshal helpers in this package.  See `obj.Unmarshaller.SetAllocBudget`.
+	MaxAllocBudget int64
+}
+
+// marker method -- you may use this type to instruct `refmt.Marshal`
+// what kind of encoder to use.
+func (DecodeOptions) IsDecodeOptions() {}
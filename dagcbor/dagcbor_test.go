@@ -0,0 +1,143 @@
+package dagcbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/polydawn/refmt/cid"
+	. "github.com/polydawn/refmt/tok"
+)
+
+// The sha2-256 of nothing, as a CIDv1 with the dag-cbor codec.
+const linkHex = "01711220e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func linkToken() Token {
+	b, _ := hex.DecodeString(linkHex)
+	c, _ := cid.Cast(b)
+	var tok Token
+	cid.LinkToken(c, &tok)
+	return tok
+}
+
+func TestEncoder(t *testing.T) {
+	// {"bb": 1.5, "a": [_ 1], "c": link}, to be sorted and made definite.
+	tokens := []Token{
+		{Type: TMapOpen, Length: -1},
+		{Type: TString, Str: "bb"},
+		{Type: TFloat64, Float64: 1.5},
+		{Type: TString, Str: "a"},
+		{Type: TArrOpen, Length: -1},
+		{Type: TUint, Uint: 1},
+		{Type: TArrClose},
+		{Type: TString, Str: "c"},
+		linkToken(),
+		{Type: TMapClose},
+	}
+	expect, _ := hex.DecodeString("a3" + "6161" + "8101" + "6163" + "d82a5825" + "00" + linkHex + "626262" + "fb3ff8000000000000")
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for n, tok := range tokens {
+		done, err := enc.Step(&tok)
+		if err != nil {
+			t.Fatalf("step %d errored: %s", n, err)
+		}
+		if done != (n == len(tokens)-1) {
+			t.Errorf("step %d: unexpected done=%v", n, done)
+		}
+	}
+	if !bytes.Equal(buf.Bytes(), expect) {
+		t.Errorf("expected %x, got %x", expect, buf.Bytes())
+	}
+
+	badLink := linkToken()
+	badLink.Bytes = badLink.Bytes[1:]
+	for _, tr := range []struct {
+		title  string
+		tokens []Token
+	}{
+		{"undefined", []Token{{Type: TUndefined}}},
+		{"simple value", []Token{{Type: TSimple, Uint: 16}}},
+		{"NaN", []Token{{Type: TFloat64, Float64: math.NaN()}}},
+		{"infinity", []Token{{Type: TFloat64, Float64: math.Inf(-1)}}},
+		{"other tag", []Token{{Type: TUint, Uint: 1, Tagged: true, Tag: 1}}},
+		{"link without zero byte", []Token{badLink}},
+		{"int key", []Token{{Type: TMapOpen, Length: 1}, {Type: TUint, Uint: 1}}},
+		{"int key after nested value", []Token{
+			{Type: TMapOpen, Length: 2},
+			{Type: TString, Str: "a"}, {Type: TArrOpen, Length: 0}, {Type: TArrClose},
+			{Type: TInt, Int: -1},
+		}},
+	} {
+		enc := NewEncoder(&bytes.Buffer{})
+		var err error
+		for _, tok := range tr.tokens {
+			if _, err = enc.Step(&tok); err != nil {
+				break
+			}
+		}
+		if _, ok := err.(*ErrInvalid); !ok {
+			t.Errorf("test %q: expected ErrInvalid, got %v", tr.title, err)
+		}
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	decode := func(serial string) error {
+		b, _ := hex.DecodeString(serial)
+		dec := NewDecoder(bytes.NewReader(b))
+		for {
+			var tok Token
+			done, err := dec.Step(&tok)
+			if err != nil || done {
+				return err
+			}
+		}
+	}
+	for _, tr := range []struct {
+		title  string
+		serial string
+		expect string // Error type, or empty for none.
+	}{
+		{"canonical",
+			"a3" + "6161" + "8101" + "6163" + "d82a5825" + "00" + linkHex + "626262" + "fb3ff8000000000000",
+			""},
+		{"keys in bytewise but not length-first order", "a2626262f66163f6", "*cbor.ErrNonCanonical"},
+		{"indefinite map", "bf6161f6ff", "*cbor.ErrNonCanonical"},
+		{"float16", "f93e00", "*cbor.ErrNonCanonical"},
+		{"non-minimal int", "1801", "*cbor.ErrNonCanonical"},
+		{"int key", "a101f6", "*dagcbor.ErrInvalid"},
+		{"undefined", "f7", "*dagcbor.ErrInvalid"},
+		{"other tag", "c101", "*dagcbor.ErrInvalid"},
+		{"link without zero byte", "d82a5824" + linkHex, "*dagcbor.ErrInvalid"},
+		{"nested tags", "d82ad82a5825" + "00" + linkHex, "shared.ErrLimitExceeded"},
+	} {
+		err := decode(tr.serial)
+		got := ""
+		if err != nil {
+			got = fmt.Sprintf("%T", err)
+		}
+		if got != tr.expect {
+			t.Errorf("test %q: expected %s, got %v", tr.title, tr.expect, err)
+		}
+	}
+}
+
+func TestHelpers(t *testing.T) {
+	tok := linkToken()
+	c, _ := cid.FromLinkToken(&tok)
+	v := map[string]interface{}{"z": c, "n": 2.0, "list": []interface{}{"x", []byte{1}}}
+	serial, err := Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal failed: %s", err)
+	}
+	var v2 map[string]interface{}
+	if err := Unmarshal(serial, &v2); err != nil {
+		t.Fatalf("unmarshal failed: %s", err)
+	}
+	if fmt.Sprint(v2) != fmt.Sprint(v) {
+		t.Errorf("round trip mismatch: %v != %v", v2, v)
+	}
+}
@@ -0,0 +1,27 @@
+/*
+	Package dagcbor implements DAG-CBOR, the strict subset of CBOR which IPLD
+	uses for content-addressed data: any data model value has exactly one
+	encoding, so it always hashes the same.
+
+	It's a thin layer over the `cbor` package.  Compared to plain CBOR:
+
+	  - map keys must be strings, and are sorted (length first, then bytewise);
+	  - maps, arrays, and strings always have definite lengths;
+	  - integers and lengths are always in their shortest form;
+	  - floats are always 64 bits, and may not be NaN or infinite;
+	  - undefined and other simple values (besides true, false, and null)
+	     are not permitted;
+	  - tag 42, marking links, is the only tag permitted, and may only be
+	     used on bytes containing a valid CID (see the `cid` package).
+
+	The `dagcbor.Encoder` sorts and fills in lengths as needed, and rejects
+	anything else outside these rules with an `*ErrInvalid` error.
+	The `dagcbor.Decoder` rejects any input not encoded exactly this way,
+	with a `*cbor.ErrNonCanonical` error for non-canonical encodings, and
+	an `*ErrInvalid` error for everything else.
+
+	The marshal and unmarshal helpers without an atlas use one containing
+	`cid.AtlasEntry`, so `cid.Cid` values become links.  If you supply an
+	atlas, include that entry yourself.
+*/
+package dagcbor
@@ -0,0 +1,69 @@
+package dagjson
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/polydawn/refmt/cid"
+	. "github.com/polydawn/refmt/tok"
+)
+
+// Error raised by Encoder or Decoder for a token outside the rules of DAG-JSON.
+type ErrInvalid struct {
+	Token  Token  // The offending token.
+	Reason string // What's wrong with it.
+}
+
+func (e *ErrInvalid) Error() string {
+	return fmt.Sprintf("dagjson: invalid %s: %s", e.Token, e.Reason)
+}
+
+// Checks each token in a stream against the rules of the IPLD data model
+// which the json encoder doesn't already enforce for us.
+// (Only the Encoder needs this; the Decoder only produces what's permitted.)
+type checker struct {
+	stack []bool // For each open map or array, true if it's a map.
+	key   bool   // True if the next token is a map key (or the end of the map).
+}
+
+func (c *checker) reset() {
+	c.stack = c.stack[0:0]
+	c.key = false
+}
+
+func (c *checker) step(tok *Token) error {
+	if c.key && tok.Type != TMapClose && tok.Type != TString {
+		return &ErrInvalid{*tok, "map keys must be strings"}
+	}
+	switch tok.Type {
+	case TUndefined, TSimple:
+		return &ErrInvalid{*tok, "not in the IPLD data model"}
+	case TFloat64:
+		if math.IsNaN(tok.Float64) || math.IsInf(tok.Float64, 0) {
+			return &ErrInvalid{*tok, "NaN and infinities are not in the IPLD data model"}
+		}
+	}
+	if tok.Tagged {
+		if _, err := cid.FromLinkToken(tok); err != nil {
+			return &ErrInvalid{*tok, err.Error()}
+		}
+	}
+	switch tok.Type {
+	case TMapOpen:
+		c.stack = append(c.stack, true)
+		c.key = true
+	case TArrOpen:
+		c.stack = append(c.stack, false)
+		c.key = false
+	case TMapClose, TArrClose:
+		if len(c.stack) > 0 {
+			c.stack = c.stack[0 : len(c.stack)-1]
+		}
+		c.key = len(c.stack) > 0 && c.stack[len(c.stack)-1]
+	default:
+		if len(c.stack) > 0 && c.stack[len(c.stack)-1] {
+			c.key = !c.key
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package dagjson
+
+import (
+	"io"
+
+	"github.com/polydawn/refmt/cid"
+	"github.com/polydawn/refmt/json"
+	"github.com/polydawn/refmt/shared"
+	. "github.com/polydawn/refmt/tok"
+)
+
+/*
+	A dagjson.Decoder is a TokenSource implementation that reads DAG-JSON.
+
+	Links, `{"/": "bafy..."}`, are yielded as link tokens (see the `cid`
+	package), and bytes, `{"/": {"bytes": "..."}}`, as bytes tokens.
+	Maps with a "/" key which aren't in exactly one of these forms are
+	yielded as ordinary maps.
+*/
+type Decoder struct {
+	dec   *json.Decoder
+	ahead *shared.Lookahead // Reads ahead while looking for the link form.
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return NewDecoderWithOptions(DecodeOptions{}, r)
+}
+
+func NewDecoderWithOptions(cfg DecodeOptions, r io.Reader) *Decoder {
+	d := &Decoder{
+		dec: json.NewDecoderWithOptions(json.DecodeOptions{
+			Bytes:              json.BytesMode_DagJSON,
+			MaxDepth:           cfg.MaxDepth,
+			MaxStringLength:    cfg.MaxStringLength,
+			MaxContainerLength: cfg.MaxContainerLength,
+			MaxTotalBytes:      cfg.MaxTotalBytes,
+		}, r),
+	}
+	d.ahead = shared.NewLookahead(d.dec.Step)
+	return d
+}
+
+func (d *Decoder) Reset() {
+	d.dec.Reset()
+	d.ahead.Reset()
+}
+
+// CheckTrailing checks that there's nothing after the value just decoded
+// but whitespace; see `json.Decoder.CheckTrailing`.
+func (d *Decoder) CheckTrailing() error {
+	return d.dec.CheckTrailing()
+}
+
+// The shape of the link form, `{"/":"..."}`, after the opening of the map.
+var linkShape = []func(*Token) bool{
+	func(tok *Token) bool { return tok.Type == TString && tok.Str == "/" },
+	func(tok *Token) bool { return tok.Type == TString },
+	func(tok *Token) bool { return tok.Type == TMapClose },
+}
+
+/*
+	Step works like `json.Decoder`'s handling of the bytes form:
+	whenever a map opens, we read ahead to see if it's a link.
+	If it is, we yield one link token in place of all of it;
+	if it isn't, we yield the tokens we read ahead one at a time.
+*/
+func (d *Decoder) Step(tokenSlot *Token) (done bool, err error) {
+	tokenSlot.Tagged = false // The json decoder knows nothing of tags, so won't clear one we left last time.
+	tokenSlot.OuterTags = tokenSlot.OuterTags[:0]
+	if d.ahead.Pending() {
+		return d.ahead.Step(tokenSlot)
+	}
+	done, err = d.ahead.Step(tokenSlot)
+	if err != nil || done || tokenSlot.Type != TMapOpen {
+		return
+	}
+	toks, done := d.ahead.Match(linkShape)
+	if toks == nil {
+		return false, nil
+	}
+	str := toks[1].Str
+	c, err := cid.Parse(str)
+	if err != nil {
+		return true, &ErrInvalid{Token{Type: TString, Str: str}, err.Error()}
+	}
+	cid.LinkToken(c, tokenSlot)
+	return done, nil
+}
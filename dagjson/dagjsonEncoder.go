@@ -0,0 +1,85 @@
+package dagjson
+
+import (
+	"io"
+
+	"github.com/polydawn/refmt/cid"
+	"github.com/polydawn/refmt/json"
+	"github.com/polydawn/refmt/shared"
+	. "github.com/polydawn/refmt/tok"
+)
+
+/*
+	A dagjson.Encoder is a TokenSink implementation that emits DAG-JSON.
+
+	Map keys are sorted by a `shared.Canonicalizer`, so each top-level map
+	or array is buffered until it ends; then it's all written at once.
+*/
+type Encoder struct {
+	check checker
+	canon *shared.Canonicalizer
+	links linkExpander
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return NewEncoderWithOptions(EncodeOptions{}, w)
+}
+
+func NewEncoderWithOptions(cfg EncodeOptions, w io.Writer) *Encoder {
+	d := &Encoder{
+		links: linkExpander{json.NewEncoderWithOptions(json.EncodeOptions{
+			Bytes:        json.BytesMode_DagJSON,
+			Undefined:    json.UnrepresentableMode_Error,
+			SimpleValues: json.UnrepresentableMode_Error,
+		}, w)},
+	}
+	d.canon = shared.NewCanonicalizer(shared.CanonicalizeOptions{
+		KeySortMode:    shared.KeySortMode_Lexical,
+		MaxBufferBytes: cfg.MaxBufferBytes,
+	}, d.links)
+	return d
+}
+
+func (d *Encoder) Reset() {
+	d.check.reset()
+	d.canon.Reset()
+	d.links.enc.Reset()
+}
+
+func (d *Encoder) Step(tokenSlot *Token) (done bool, err error) {
+	if err := d.check.step(tokenSlot); err != nil {
+		return true, err
+	}
+	done, err = d.canon.Step(tokenSlot)
+	if e, ok := err.(shared.ErrDuplicateKey); ok {
+		err = &ErrInvalid{e.Key, "duplicate map key"}
+	}
+	return done, err
+}
+
+// A TokenSink which passes tokens on to the json encoder,
+// expanding links into their map form.
+type linkExpander struct {
+	enc *json.Encoder
+}
+
+func (x linkExpander) Step(tok *Token) (done bool, err error) {
+	if !tok.Tagged {
+		return x.enc.Step(tok)
+	}
+	c, err := cid.FromLinkToken(tok)
+	if err != nil {
+		return true, err
+	}
+	for _, t := range []Token{
+		{Type: TMapOpen, Length: 1},
+		{Type: TString, Str: "/"},
+		{Type: TString, Str: c.String()},
+		{Type: TMapClose},
+	} {
+		if done, err = x.enc.Step(&t); err != nil {
+			return true, err
+		}
+	}
+	return done, nil
+}
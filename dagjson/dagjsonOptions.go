@@ -0,0 +1,34 @@
+package dagjson
+
+type EncodeOptions struct {
+	// There's nothing to choose about the output; DAG-JSON permits only one encoding.
+
+	// Maximum memory, in bytes, to spend buffering any one map or array
+	// while its keys are sorted.  See `shared.CanonicalizeOptions`.
+	// Zero means unlimited.
+	MaxBufferBytes int64
+}
+
+// marker method -- you may use this type to instruct `refmt.Marshal`
+// what kind of encoder to use.
+func (EncodeOptions) IsEncodeOptions() {}
+
+type DecodeOptions struct {
+	// Resource limits, for use when decoding untrusted input.
+	// These are as described for `json.DecodeOptions`.
+	// Zero means unlimited.  Exceeding any limit halts decoding with a
+	// `shared.ErrLimitExceeded` error.
+
+	MaxDepth           int   // Maximum nesting depth of maps and arrays.
+	MaxStringLength    int   // Maximum length in bytes of any single string (after unescaping).
+	MaxContainerLength int   // Maximum number of entries in any single map or array.
+	MaxTotalBytes      int64 // Maximum number of bytes to consume from the reader (per value; counted from `Reset`).
+
+	// Allocation budget for the `obj.Unmarshaller` when using the
+	// unmarshal helpers in this package.  See `obj.Unmarshaller.SetAllocBudget`.
+	MaxAllocBudget int64
+}
+
+// marker method -- you may use this type to instruct `refmt.Marshal`
+// what kind of encoder to use.
+func (DecodeOptions) IsDecodeOptions() {}
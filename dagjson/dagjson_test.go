@@ -0,0 +1,190 @@
+package dagjson
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/polydawn/refmt/cid"
+	"github.com/polydawn/refmt/dagcbor"
+	"github.com/polydawn/refmt/shared"
+	. "github.com/polydawn/refmt/tok"
+)
+
+// The sha2-256 of nothing, as a CIDv1 with the dag-cbor codec.
+const (
+	linkHex = "01711220e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	linkStr = "bafyreihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku"
+)
+
+func linkToken() Token {
+	b, _ := hex.DecodeString(linkHex)
+	c, _ := cid.Cast(b)
+	var tok Token
+	cid.LinkToken(c, &tok)
+	return tok
+}
+
+// {"bb": 1.5, "a": [h'01', -1], "c": link}, in that order.
+var fixture = []Token{
+	{Type: TMapOpen, Length: 3},
+	{Type: TString, Str: "bb"},
+	{Type: TFloat64, Float64: 1.5},
+	{Type: TString, Str: "a"},
+	{Type: TArrOpen, Length: 2},
+	{Type: TBytes, Bytes: []byte{1}},
+	{Type: TInt, Int: -1},
+	{Type: TArrClose},
+	{Type: TString, Str: "c"},
+	linkToken(),
+	{Type: TMapClose},
+}
+
+const fixtureJson = `{"a":[{"/":{"bytes":"AQ"}},-1],"bb":1.5,"c":{"/":"` + linkStr + `"}}`
+
+func TestEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for n, tok := range fixture {
+		done, err := enc.Step(&tok)
+		if err != nil {
+			t.Fatalf("step %d errored: %s", n, err)
+		}
+		if done != (n == len(fixture)-1) {
+			t.Errorf("step %d: unexpected done=%v", n, done)
+		}
+	}
+	if buf.String() != fixtureJson {
+		t.Errorf("expected %s, got %s", fixtureJson, buf.String())
+	}
+
+	buf.Reset()
+	link := linkToken()
+	if _, err := NewEncoder(&buf).Step(&link); err != nil || buf.String() != `{"/":"`+linkStr+`"}` {
+		t.Errorf("top-level link: got %s, error %v", buf.String(), err)
+	}
+
+	for _, tr := range []struct {
+		title  string
+		tokens []Token
+	}{
+		{"undefined", []Token{{Type: TUndefined}}},
+		{"NaN", []Token{{Type: TFloat64, Float64: math.NaN()}}},
+		{"other tag", []Token{{Type: TUint, Uint: 1, Tagged: true, Tag: 1}}},
+		{"duplicate key", []Token{
+			{Type: TMapOpen, Length: 2},
+			{Type: TString, Str: "a"}, {Type: TNull},
+			{Type: TString, Str: "a"}, {Type: TNull},
+			{Type: TMapClose},
+		}},
+		{"int key", []Token{{Type: TMapOpen, Length: 1}, {Type: TUint, Uint: 1}, {Type: TNull}, {Type: TMapClose}}},
+	} {
+		enc := NewEncoder(&bytes.Buffer{})
+		var err error
+		for _, tok := range tr.tokens {
+			if _, err = enc.Step(&tok); err != nil {
+				break
+			}
+		}
+		if _, ok := err.(*ErrInvalid); !ok {
+			t.Errorf("test %q: expected ErrInvalid, got %v", tr.title, err)
+		}
+	}
+
+	enc = NewEncoderWithOptions(EncodeOptions{MaxBufferBytes: 200}, &bytes.Buffer{})
+	var err error
+	for _, tok := range fixture {
+		if _, err = enc.Step(&tok); err != nil {
+			break
+		}
+	}
+	if _, ok := err.(shared.ErrLimitExceeded); !ok {
+		t.Errorf("buffer limit: expected ErrLimitExceeded, got %v", err)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	decode := func(serial string) ([]Token, error) {
+		dec := NewDecoder(strings.NewReader(serial))
+		var toks []Token
+		for {
+			var tok Token
+			done, err := dec.Step(&tok)
+			if err != nil {
+				return toks, err
+			}
+			toks = append(toks, tok)
+			if done {
+				return toks, nil
+			}
+		}
+	}
+	link := linkToken()
+	for _, tr := range []struct {
+		title  string
+		serial string
+		expect []Token
+	}{
+		{"link", `{"/":"` + linkStr + `"}`, []Token{link}},
+		{"link in array", `[{ "/" : "` + linkStr + `" }]`, []Token{
+			{Type: TArrOpen, Length: -1}, link, {Type: TArrClose},
+		}},
+		{"not a link: more entries", `{"/":"x","y":1}`, []Token{
+			{Type: TMapOpen, Length: -1},
+			{Type: TString, Str: "/"}, {Type: TString, Str: "x"},
+			{Type: TString, Str: "y"}, {Type: TInt, Int: 1},
+			{Type: TMapClose},
+		}},
+		{"not a link: not a string", `{"/":1}`, []Token{
+			{Type: TMapOpen, Length: -1},
+			{Type: TString, Str: "/"}, {Type: TInt, Int: 1},
+			{Type: TMapClose},
+		}},
+	} {
+		toks, err := decode(tr.serial)
+		if err != nil {
+			t.Errorf("test %q: unexpected error %s", tr.title, err)
+		}
+		if fmt.Sprint(toks) != fmt.Sprint(tr.expect) {
+			t.Errorf("test %q:\n\texpected %v\n\t     got %v", tr.title, tr.expect, toks)
+		}
+	}
+	if _, err := decode(`{"/":"bnotacid"}`); err == nil {
+		t.Errorf("expected error for an invalid CID")
+	} else if _, ok := err.(*ErrInvalid); !ok {
+		t.Errorf("expected ErrInvalid for an invalid CID, got %v", err)
+	}
+}
+
+func TestDagCborRoundTrip(t *testing.T) {
+	var cborBuf, jsonBuf, cborBuf2 bytes.Buffer
+	enc := dagcbor.NewEncoder(&cborBuf)
+	for _, tok := range fixture {
+		if _, err := enc.Step(&tok); err != nil {
+			t.Fatalf("encoding dag-cbor failed: %s", err)
+		}
+	}
+	err := shared.TokenPump{
+		TokenSource: dagcbor.NewDecoder(bytes.NewReader(cborBuf.Bytes())),
+		TokenSink:   NewEncoder(&jsonBuf),
+	}.Run()
+	if err != nil {
+		t.Fatalf("transcoding to dag-json failed: %s", err)
+	}
+	if jsonBuf.String() != fixtureJson {
+		t.Errorf("expected %s, got %s", fixtureJson, jsonBuf.String())
+	}
+	err = shared.TokenPump{
+		TokenSource: NewDecoder(&jsonBuf),
+		TokenSink:   dagcbor.NewEncoder(&cborBuf2),
+	}.Run()
+	if err != nil {
+		t.Fatalf("transcoding back to dag-cbor failed: %s", err)
+	}
+	if !bytes.Equal(cborBuf.Bytes(), cborBuf2.Bytes()) {
+		t.Errorf("round trip not lossless: %x != %x", cborBuf2.Bytes(), cborBuf.Bytes())
+	}
+}
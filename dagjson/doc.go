@@ -0,0 +1,30 @@
+/*
+	Package dagjson implements DAG-JSON, the JSON form IPLD uses for
+	content-addressed data.  It's a thin layer over the `json` package.
+
+	DAG-JSON adds two special forms to JSON, using maps with a single
+	key of "/":
+
+		{"/": "bafy..."}                  -- a link: a CID in string form
+		{"/": {"bytes": "AQID"}}          -- bytes, in unpadded base64
+
+	The encoder writes links (bytes tokens with tag 42, as described in the
+	`cid` package) in the first form, and all bytes in the second form.
+	The decoder recognizes both forms and yields link and bytes tokens,
+	so data moves between DAG-JSON and DAG-CBOR without loss.
+
+	The `dagjson.Encoder` also sorts map keys (bytewise), so any data model
+	value has exactly one encoding.  To do that, it buffers each top-level
+	map or array entirely in memory before writing anything (up to
+	`EncodeOptions.MaxBufferBytes`, if that's set).
+	Data outside the IPLD data model (undefined and other simple values,
+	NaN and infinities, and tags other than links) is rejected with an
+	`*ErrInvalid` error.
+
+	The decoder does not insist that map keys are sorted.
+
+	The marshal and unmarshal helpers without an atlas use one containing
+	`cid.AtlasEntry`, so `cid.Cid` values become links.  If you supply an
+	atlas, include that entry yourself.
+*/
+package dagjson
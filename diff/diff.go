@@ -0,0 +1,230 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/polydawn/refmt/query"
+	"github.com/polydawn/refmt/shared"
+	. "github.com/polydawn/refmt/tok"
+)
+
+// Kind describes a Difference.
+type Kind string
+
+const (
+	Kind_Added   = "added"   // A map or array entry only in the second document.
+	Kind_Removed = "removed" // A map or array entry only in the first document.
+	Kind_Changed = "changed" // A value that's different in each document.
+)
+
+/*
+	Difference is one way in which two documents differ.
+
+	Old and New are the tokens of the value in the first and second
+	document, respectively; Old is empty for Kind_Added, and New is empty
+	for Kind_Removed.  (They can be replayed into any TokenSink with
+	a `tok.Buffer`, e.g. to encode them.)
+*/
+type Difference struct {
+	Kind Kind
+	Path query.Pointer
+	Old  []Token
+	New  []Token
+}
+
+// String renders the difference for people, e.g. `changed at "/a": <i:1> -> <s:"x">`.
+func (d Difference) String() string {
+	switch d.Kind {
+	case Kind_Added:
+		return fmt.Sprintf("%s at %q: %s", d.Kind, d.Path.String(), tokensString(d.New))
+	case Kind_Removed:
+		return fmt.Sprintf("%s at %q: %s", d.Kind, d.Path.String(), tokensString(d.Old))
+	default:
+		return fmt.Sprintf("%s at %q: %s -> %s", d.Kind, d.Path.String(), tokensString(d.Old), tokensString(d.New))
+	}
+}
+
+func tokensString(toks []Token) string {
+	var buf bytes.Buffer
+	for _, t := range toks {
+		buf.WriteString(t.String())
+	}
+	return buf.String()
+}
+
+/*
+	Diff reads one complete value from each of two TokenSources,
+	and returns the differences between them.
+
+	Maps are compared entry by entry, by key, so the order of the entries
+	doesn't matter.  Arrays are compared entry by entry, by index; entries
+	beyond the end of the shorter array are added or removed.  Any other
+	values are compared as a whole: they're the same if they're of the same
+	type and have the same value and tags.  Ints and uints with the same
+	value count as the same, since codecs differ in which they use.
+	Floats are never the same as integers, and NaN is the same as NaN.
+	Declared lengths of maps and arrays are disregarded.
+
+	Differences are listed in the order of the first document, followed by
+	any entries only in the second document.
+
+	Both documents are read into memory before they're compared.
+	It's an error if either stream isn't well-formed.
+*/
+func Diff(a, b shared.TokenSource) ([]Difference, error) {
+	va, err := read(a)
+	if err != nil {
+		return nil, err
+	}
+	vb, err := read(b)
+	if err != nil {
+		return nil, err
+	}
+	var diffs []Difference
+	compare(&diffs, query.Pointer{}, va, vb)
+	return diffs, nil
+}
+
+// A value read from a token stream.
+type value struct {
+	toks    []Token  // All the tokens of the value.
+	keys    []string // If a map: the key of each entry.
+	entries []value  // If a map or array: the value of each entry.
+}
+
+func read(src shared.TokenSource) (value, error) {
+	var buf Buffer
+	err := shared.TokenPump{
+		TokenSource: src,
+		TokenSink:   shared.NewValidatingSink(&buf),
+	}.Run()
+	if err != nil {
+		return value{}, err
+	}
+	v, _ := parse(buf.Tokens)
+	return v, nil
+}
+
+// Parse the value at the start of toks (which are known to be well-formed),
+// returning it and the number of tokens it took.
+func parse(toks []Token) (value, int) {
+	v := value{}
+	i := 1
+	switch toks[0].Type {
+	case TMapOpen:
+		for toks[i].Type != TMapClose {
+			v.keys = append(v.keys, query.KeyStep(&toks[i]))
+			entry, n := parse(toks[i+1:])
+			v.entries = append(v.entries, entry)
+			i += 1 + n
+		}
+		i++
+	case TArrOpen:
+		for toks[i].Type != TArrClose {
+			entry, n := parse(toks[i:])
+			v.entries = append(v.entries, entry)
+			i += n
+		}
+		i++
+	}
+	v.toks = toks[:i]
+	return v, i
+}
+
+// Append the differences between two values at `path` to `diffs`.
+func compare(diffs *[]Difference, path query.Pointer, a, b value) {
+	ta, tb := &a.toks[0], &b.toks[0]
+	if !sameTags(ta, tb) || !sameType(ta, tb) {
+		*diffs = append(*diffs, Difference{Kind: Kind_Changed, Path: entryPath(path), Old: a.toks, New: b.toks})
+		return
+	}
+	switch ta.Type {
+	case TMapOpen:
+		inA := make(map[string]bool, len(a.keys))
+		inB := make(map[string]int, len(b.keys))
+		for j := len(b.keys) - 1; j >= 0; j-- {
+			inB[b.keys[j]] = j // If a key is repeated, the first entry counts.
+		}
+		for i, k := range a.keys {
+			if inA[k] {
+				continue
+			}
+			inA[k] = true
+			if j, ok := inB[k]; ok {
+				compare(diffs, append(path, k), a.entries[i], b.entries[j])
+			} else {
+				*diffs = append(*diffs, Difference{Kind: Kind_Removed, Path: entryPath(path, k), Old: a.entries[i].toks})
+			}
+		}
+		for j, k := range b.keys {
+			if !inA[k] && inB[k] == j {
+				*diffs = append(*diffs, Difference{Kind: Kind_Added, Path: entryPath(path, k), New: b.entries[j].toks})
+			}
+		}
+	case TArrOpen:
+		for i := range a.entries {
+			k := strconv.Itoa(i)
+			if i < len(b.entries) {
+				compare(diffs, append(path, k), a.entries[i], b.entries[i])
+			} else {
+				*diffs = append(*diffs, Difference{Kind: Kind_Removed, Path: entryPath(path, k), Old: a.entries[i].toks})
+			}
+		}
+		for i := len(a.entries); i < len(b.entries); i++ {
+			*diffs = append(*diffs, Difference{Kind: Kind_Added, Path: entryPath(path, strconv.Itoa(i)), New: b.entries[i].toks})
+		}
+	default:
+		if !sameScalar(ta, tb) {
+			*diffs = append(*diffs, Difference{Kind: Kind_Changed, Path: entryPath(path), Old: a.toks, New: b.toks})
+		}
+	}
+}
+
+// A copy of `path`, with any further steps appended.
+// (The path being compared is reused as the comparison goes on, so each
+// Difference needs its own copy.)
+func entryPath(path query.Pointer, steps ...string) query.Pointer {
+	return append(append(make(query.Pointer, 0, len(path)+len(steps)), path...), steps...)
+}
+
+func sameTags(a, b *Token) bool {
+	if a.Tagged != b.Tagged {
+		return false
+	}
+	if !a.Tagged {
+		return true
+	}
+	if a.Tag != b.Tag || len(a.OuterTags) != len(b.OuterTags) {
+		return false
+	}
+	for i := range a.OuterTags {
+		if a.OuterTags[i] != b.OuterTags[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func isInteger(tt TokenType) bool {
+	return tt == TInt || tt == TUint
+}
+
+func sameType(a, b *Token) bool {
+	return a.Type == b.Type || isInteger(a.Type) && isInteger(b.Type)
+}
+
+// Compare two values of the same type (per `sameType`) that aren't maps or arrays.
+func sameScalar(a, b *Token) bool {
+	switch {
+	case a.Type == TInt && b.Type == TUint:
+		return a.Int >= 0 && uint64(a.Int) == b.Uint
+	case a.Type == TUint && b.Type == TInt:
+		return b.Int >= 0 && uint64(b.Int) == a.Uint
+	case a.Type == TFloat64:
+		return a.Float64 == b.Float64 || math.IsNaN(a.Float64) && math.IsNaN(b.Float64)
+	}
+	return IsTokenEqual(*a, *b)
+}
@@ -0,0 +1,91 @@
+package diff
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/polydawn/refmt/cbor"
+	"github.com/polydawn/refmt/json"
+	"github.com/polydawn/refmt/query"
+	"github.com/polydawn/refmt/shared"
+	. "github.com/polydawn/refmt/tok"
+)
+
+func jsonSrc(s string) shared.TokenSource {
+	return json.NewDecoder(strings.NewReader(s))
+}
+
+// Diff two json documents, rendering the differences.
+func diffJson(a, b string) []string {
+	diffs, err := Diff(jsonSrc(a), jsonSrc(b))
+	So(err, ShouldBeNil)
+	strs := []string{}
+	for _, d := range diffs {
+		strs = append(strs, d.String())
+	}
+	return strs
+}
+
+func TestDiff(t *testing.T) {
+	Convey("Diffing documents", t, func() {
+		Convey("the same document has no differences", func() {
+			So(diffJson(`{"a":[1,{"b":null}],"c":"x"}`, `{"a":[1,{"b":null}],"c":"x"}`), ShouldResemble, []string{})
+		})
+		Convey("map entries may be in any order", func() {
+			So(diffJson(`{"a":1,"b":{"x":true,"y":false}}`, `{"b":{"y":false,"x":true},"a":1}`), ShouldResemble, []string{})
+		})
+		Convey("changed, removed, and added map entries are listed", func() {
+			So(diffJson(`{"a":1,"b":2,"c":{"d":"x"}}`, `{"c":{"d":"y"},"e":[],"a":1}`), ShouldResemble, []string{
+				`removed at "/b": <i:2>`,
+				`changed at "/c/d": <s:"x"> -> <s:"y">`,
+				`added at "/e": <[><]>`,
+			})
+		})
+		Convey("array entries are compared by index", func() {
+			So(diffJson(`[1,2,3]`, `[1,3]`), ShouldResemble, []string{
+				`changed at "/1": <i:2> -> <i:3>`,
+				`removed at "/2": <i:3>`,
+			})
+			So(diffJson(`{"a":[]}`, `{"a":[{"x":1}]}`), ShouldResemble, []string{
+				`added at "/a/0": <{><s:"x"><i:1><}>`,
+			})
+		})
+		Convey("values of different types are changed as a whole", func() {
+			So(diffJson(`{"a":{"b":1}}`, `{"a":[1]}`), ShouldResemble, []string{
+				`changed at "/a": <{><s:"b"><i:1><}> -> <[><i:1><]>`,
+			})
+			So(diffJson(`1`, `1.0`), ShouldResemble, []string{
+				`changed at "": <i:1> -> <f:1>`,
+			})
+		})
+		Convey("paths are escaped", func() {
+			So(diffJson(`{"a/b":{"~":1}}`, `{"a/b":{"~":2}}`), ShouldResemble, []string{
+				`changed at "/a~1b/~0": <i:1> -> <i:2>`,
+			})
+		})
+		Convey("json and cbor documents can be compared", func() {
+			serial, err := cbor.Marshal(map[string]interface{}{"n": 5, "s": []string{"x"}, "f": 1.5})
+			So(err, ShouldBeNil)
+			diffs, err := Diff(jsonSrc(`{"s":["x"],"f":1.5,"n":5}`), cbor.NewDecoder(bytes.NewReader(serial)))
+			So(err, ShouldBeNil)
+			So(diffs, ShouldBeEmpty)
+		})
+		Convey("tags count", func() {
+			tagged := &Buffer{Tokens: []Token{{Type: TString, Str: "x", Tagged: true, Tag: 42}}}
+			diffs, err := Diff(jsonSrc(`"x"`), tagged.Source())
+			So(err, ShouldBeNil)
+			So(diffs, ShouldResemble, []Difference{
+				{Kind: Kind_Changed, Path: query.Pointer{}, Old: []Token{{Type: TString, Str: "x"}}, New: tagged.Tokens},
+			})
+		})
+		Convey("malformed streams are an error", func() {
+			_, err := Diff(jsonSrc(`{}`), (&Buffer{Tokens: []Token{{Type: TArrClose}}}).Source())
+			So(err, ShouldResemble, shared.ErrMalformedTokenStream{Got: TArrClose, Expected: "start of value"})
+			_, err = Diff(jsonSrc(`{`), jsonSrc(`{}`))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,12 @@
+/*
+	Package diff compares documents structurally, as token streams,
+	regardless of their formatting or codec: json can be compared against
+	cbor, or two versions of a config file against each other.
+
+	`Diff` takes two TokenSources and returns a list of `Difference`s --
+	values added, removed, or changed, each at a path (a JSON Pointer;
+	see the `query` package).  The order of map entries doesn't matter;
+	the order of array entries does.  An empty list means the documents
+	are the same, which makes `Diff` handy for test assertions.
+*/
+package diff
@@ -6,8 +6,12 @@ import (
 	"strings"
 
 	"github.com/polydawn/refmt"
+	"github.com/polydawn/refmt/cbor"
 	"github.com/polydawn/refmt/json"
+	"github.com/polydawn/refmt/obj"
 	"github.com/polydawn/refmt/obj/atlas"
+	"github.com/polydawn/refmt/shared"
+	"github.com/polydawn/refmt/tok"
 )
 
 func ExampleJsonEncodeAtlasDefaults() {
@@ -103,3 +107,41 @@ func ExampleJsonEncodeAtlas() {
 	// "serializes:as:string!"
 	// <nil>
 }
+
+func Example_teeJsonAndCbor() {
+	marshaller := obj.NewMarshaller(atlas.MustBuild())
+	marshaller.Bind(map[string]interface{}{"a": 1})
+
+	// Walk the object once, encoding it both ways, and recording the tokens.
+	var jsonBuf, cborBuf bytes.Buffer
+	var recording tok.Buffer
+	err := shared.TokenPump{
+		TokenSource: marshaller,
+		TokenSink:   shared.NewTee(json.NewEncoder(&jsonBuf), cbor.NewEncoder(&cborBuf), &recording),
+	}.Run()
+	fmt.Printf("%v\n", err)
+	fmt.Println(jsonBuf.String())
+	fmt.Printf("%x\n", cborBuf.Bytes())
+	fmt.Println(recording.String())
+
+	// Output:
+	// <nil>
+	// {"a":1}
+	// a1616101
+	// <{:1><s:"a"><i:1><}>
+}
+
+func Example_canonicalJsonToCbor() {
+	// Json has no lengths, and keys in any order; canonicalize before encoding as cbor.
+	var buf bytes.Buffer
+	err := shared.TokenPump{
+		TokenSource: json.NewDecoder(strings.NewReader(`{"bb":[1,2],"a":{}}`)),
+		TokenSink:   shared.NewCanonicalizer(shared.CanonicalizeOptions{KeySortMode: shared.KeySortMode_RFC8949}, cbor.NewEncoder(&buf)),
+	}.Run()
+	fmt.Printf("%v\n", err)
+	fmt.Printf("%x\n", buf.Bytes())
+
+	// Output:
+	// <nil>
+	// a26161a0626262820102
+}
@@ -16,5 +16,24 @@
 	The `json.Encoder` and `json.Decoder` types implement the low-level functionality
 	of converting serial JSON byte streams into refmt Token streams.
 	Users don't usually need to use these directly.
+
+	The decoder is strict: it accepts exactly the grammar of RFC 8259,
+	and reports anything else as an `ErrSyntax`.  `json.Unmarshal` (and the
+	other helpers taking a `[]byte`) also reject anything but whitespace
+	after the end of the value; the `Decoder` and `Unmarshaller` stop at the
+	end of each value, so they can read several values from one stream.  For documents edited by hand,
+	`DecodeOptions.Syntax` can relax this to JSONC (comments and trailing
+	commas) or JSON5; either way, the tokens yielded are the same as for
+	the equivalent strict JSON.
+
+	JSON has no bytes type, so bytes are written as strings of base64 by
+	default; see `EncodeOptions.Bytes` for other representations, including
+	the DAG-JSON form, which can be read back as bytes without any help
+	from the type being unmarshalled into.
+
+	For newline-delimited JSON (NDJSON, or JSON Lines), use
+	`json.NewLineMarshaller` and `json.NewLineUnmarshaller`.  Each line is
+	decoded on its own, so a malformed record is reported (as an `ErrLine`,
+	with its line number) without stopping the records after it.
 */
 package json
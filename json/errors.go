@@ -0,0 +1,23 @@
+package json
+
+import (
+	"fmt"
+)
+
+// Error raised by Decoder when the input is not valid JSON.
+type ErrSyntax struct {
+	Offset int    // Byte offset of the problem, counted from the start of the value.
+	Msg    string // What's wrong.
+}
+
+func (e ErrSyntax) Error() string {
+	return fmt.Sprintf("json: syntax error at byte %d: %s", e.Offset, e.Msg)
+}
+
+// Describe a byte for an error message.
+func quoteByte(b byte) string {
+	if b < 0x20 || b >= 0x7f {
+		return fmt.Sprintf("byte 0x%02x", b)
+	}
+	return fmt.Sprintf("%q", b)
+}
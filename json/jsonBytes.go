@@ -0,0 +1,58 @@
+package json
+
+import (
+	"encoding/base64"
+	encodinghex "encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Returns an error if `mode` isn't one of the BytesMode constants (or unset).
+// Encoders and decoders check this when they're built, so the functions
+// below can take the mode as given.
+func checkBytesMode(mode BytesMode) error {
+	switch mode {
+	case "", BytesMode_Base64, BytesMode_Base64URL, BytesMode_Hex, BytesMode_DagJSON:
+		return nil
+	default:
+		return fmt.Errorf("json: invalid bytes mode %q", mode)
+	}
+}
+
+// Returns the string form of bytes for the given mode.
+// (For BytesMode_DagJSON, this is just the part inside the map.)
+func encodeBytes(mode BytesMode, b []byte) string {
+	switch mode {
+	case BytesMode_Base64URL:
+		return base64.RawURLEncoding.EncodeToString(b)
+	case BytesMode_Hex:
+		return encodinghex.EncodeToString(b)
+	case BytesMode_DagJSON:
+		return base64.RawStdEncoding.EncodeToString(b)
+	default: // BytesMode_Base64, or unset.
+		return base64.StdEncoding.EncodeToString(b)
+	}
+}
+
+// Returns a function to parse the string form of bytes for the given mode,
+// or nil if strings aren't bytes in that mode.
+func bytesDecoder(mode BytesMode) func(string) ([]byte, error) {
+	switch mode {
+	case BytesMode_Base64URL:
+		return decodeBase64(base64.RawURLEncoding)
+	case BytesMode_Hex:
+		return encodinghex.DecodeString
+	case BytesMode_DagJSON:
+		return nil
+	default: // BytesMode_Base64, or unset.
+		return decodeBase64(base64.RawStdEncoding)
+	}
+}
+
+// Returns a function decoding base64 with the given (unpadded) encoding,
+// tolerating padding if present.
+func decodeBase64(enc *base64.Encoding) func(string) ([]byte, error) {
+	return func(s string) ([]byte, error) {
+		return enc.DecodeString(strings.TrimRight(s, "="))
+	}
+}
@@ -15,4 +15,7 @@ var (
 	wordMapClose = []byte("}")
 	wordColon    = []byte(":")
 	wordComma    = []byte(",")
+
+	wordDagBytesOpen  = []byte(`{"/":{"bytes":`)
+	wordDagBytesClose = []byte("}}")
 )
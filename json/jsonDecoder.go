@@ -1,7 +1,6 @@
 package json
 
 import (
-	"fmt"
 	"io"
 
 	"github.com/polydawn/refmt/shared"
@@ -9,19 +8,38 @@ import (
 )
 
 type Decoder struct {
-	r shared.SlickReader
+	r   shared.SlickReader
+	cfg DecodeOptions
 
 	stack []decoderStep // When empty, and step returns done, all done.
 	step  decoderStep   // Shortcut to end of stack.
 	some  bool          // Set to true after first value in any context; use to decide if a comma must precede the next value.
+	count []int         // Number of entries seen so far in each open map and array.
+	base  int           // Reader position at last reset; used to count against MaxTotalBytes.
+
+	ahead *shared.Lookahead // Reads ahead while looking for the DAG-JSON bytes form.
 }
 
 func NewDecoder(r io.Reader) (d *Decoder) {
+	return NewDecoderWithOptions(DecodeOptions{}, r)
+}
+
+func NewDecoderWithOptions(cfg DecodeOptions, r io.Reader) (d *Decoder) {
+	return newDecoder(cfg, shared.NewReader(r))
+}
+
+func newDecoder(cfg DecodeOptions, r shared.SlickReader) (d *Decoder) {
+	if err := checkBytesMode(cfg.Bytes); err != nil {
+		panic(err)
+	}
 	d = &Decoder{
-		r:     shared.NewReader(r),
+		r:     r,
+		cfg:   cfg,
 		stack: make([]decoderStep, 0, 10),
+		count: make([]int, 0, 10),
 	}
 	d.step = d.step_acceptValue
+	d.ahead = shared.NewLookahead(d.stepToken)
 	return
 }
 
@@ -29,14 +47,32 @@ func (d *Decoder) Reset() {
 	d.stack = d.stack[0:0]
 	d.step = d.step_acceptValue
 	d.some = false
+	d.count = d.count[0:0]
+	d.base = d.r.NumRead()
+	d.ahead.Reset()
 }
 
 type decoderStep func(tokenSlot *Token) (done bool, err error)
 
 func (d *Decoder) Step(tokenSlot *Token) (done bool, err error) {
+	if d.cfg.Bytes == BytesMode_DagJSON {
+		return d.stepDagBytes(tokenSlot)
+	}
+	return d.stepToken(tokenSlot)
+}
+
+// Like Step, but with no special handling for the DAG-JSON bytes form.
+func (d *Decoder) stepToken(tokenSlot *Token) (done bool, err error) {
 	done, err = d.step(tokenSlot)
 	// If the step errored: out, entirely.
+	// Running out of input is only a clean EOF if we hadn't started on a value yet.
 	if err != nil {
+		if err == io.EOF && len(d.stack) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return true, err
+	}
+	if err := d.checkTotalBytes(); err != nil {
 		return true, err
 	}
 	// If the step wasn't done, return same status.
@@ -44,28 +80,85 @@ func (d *Decoder) Step(tokenSlot *Token) (done bool, err error) {
 		return false, nil
 	}
 	// If it WAS done, and stack empty, we're entirely done.
+	// (We don't look past the end of the value: there may be more values
+	// after it, and the reader may be a stream that isn't closed yet.)
 	nSteps := len(d.stack) - 1
 	if nSteps <= 0 {
-		return true, nil // that's all folks
+		return true, nil
 	}
 	// Pop the stack.  Reset "some" to true.
 	d.step = d.stack[nSteps]
 	d.stack = d.stack[0:nSteps]
 	d.some = true
+	d.count = d.count[0 : len(d.count)-1]
 	return false, nil
 }
 
-func (d *Decoder) pushPhase(newPhase decoderStep) {
+func (d *Decoder) pushPhase(newPhase decoderStep) error {
+	if err := shared.CheckLimit("MaxDepth", int64(d.cfg.MaxDepth), int64(len(d.stack)+1)); err != nil {
+		return err
+	}
 	d.stack = append(d.stack, d.step)
 	d.step = newPhase
 	d.some = false
+	d.count = append(d.count, 0)
+	return nil
+}
+
+// Count one more entry in the map or array we're in the midst of.
+func (d *Decoder) countEntry() error {
+	ll := len(d.count) - 1
+	d.count[ll]++
+	return shared.CheckLimit("MaxContainerLength", int64(d.cfg.MaxContainerLength), int64(d.count[ll]))
+}
+
+// Check that we're still within MaxTotalBytes.
+func (d *Decoder) checkTotalBytes() error {
+	return shared.CheckLimit("MaxTotalBytes", d.cfg.MaxTotalBytes, int64(d.r.NumRead()-d.base))
+}
+
+// Return an ErrSyntax about a problem which began `n` bytes ago.
+func (d *Decoder) errSyntax(n int, msg string) error {
+	return ErrSyntax{d.r.NumRead() - d.base - n, msg}
+}
+
+// CheckTrailing checks that there's nothing after the value just decoded
+// but whitespace, returning an ErrSyntax if there is.
+// It reads to the end of the input, so it's only for input known to hold
+// one value (as `Unmarshal` does), not a stream of several.
+func (d *Decoder) CheckTrailing() error {
+	majorByte, err := d.readn1skippingWhitespace()
+	switch err {
+	case io.EOF:
+		return nil
+	case nil:
+		return d.errSyntax(1, "unexpected "+quoteByte(majorByte)+" after top-level value")
+	default:
+		return err
+	}
 }
 
-func readn1skippingWhitespace(r shared.SlickReader) (majorByte byte, err error) {
+// Read the next byte that isn't whitespace (or, in the lenient grammars,
+// part of a comment).
+func (d *Decoder) readn1skippingWhitespace() (majorByte byte, err error) {
 	for {
-		majorByte, err = r.Readn1()
+		majorByte, err = d.r.Readn1()
+		if err != nil {
+			return
+		}
 		switch majorByte {
 		case ' ', '\t', '\r', '\n': // continue
+		case '\v', '\f':
+			if d.cfg.Syntax != SyntaxMode_JSON5 {
+				return
+			}
+		case '/':
+			if !d.lenient() {
+				return
+			}
+			if err = d.skipComment(); err != nil {
+				return
+			}
 		default:
 			return
 		}
@@ -75,87 +168,80 @@ func readn1skippingWhitespace(r shared.SlickReader) (majorByte byte, err error)
 // The original step, where any value is accepted, and no terminators for composites are valid.
 // ONLY used in the original step; all other steps handle leaf nodes internally.
 func (d *Decoder) step_acceptValue(tokenSlot *Token) (done bool, err error) {
-	majorByte, err := readn1skippingWhitespace(d.r)
+	majorByte, err := d.readn1skippingWhitespace()
 	if err != nil {
 		return true, err
 	}
-	return d.stepHelper_acceptValue(majorByte, tokenSlot)
+	done, err = d.stepHelper_acceptValue(majorByte, tokenSlot)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return done, err
 }
 
 // Step in midst of decoding an array.
 func (d *Decoder) step_acceptArrValueOrBreak(tokenSlot *Token) (done bool, err error) {
-	majorByte, err := readn1skippingWhitespace(d.r)
+	majorByte, err := d.readEntrySep(']')
 	if err != nil {
 		return true, err
 	}
-	if d.some {
-		switch majorByte {
-		case ']':
-			tokenSlot.Type = TArrClose
-			return true, nil
-		case ',':
-			majorByte, err = readn1skippingWhitespace(d.r)
-			if err != nil {
-				return true, err
-			}
-			// and now fall through to the next switch
-		}
-	}
-	switch majorByte {
-	case ']':
+	if majorByte == ']' {
 		tokenSlot.Type = TArrClose
 		return true, nil
-	default:
-		_, err := d.stepHelper_acceptValue(majorByte, tokenSlot)
-		d.some = true
-		return false, err
 	}
+	if err := d.countEntry(); err != nil {
+		return true, err
+	}
+	d.some = true
+	_, err = d.stepHelper_acceptValue(majorByte, tokenSlot)
+	return false, err
 }
 
 // Step in midst of decoding a map, key expected up next, or end.
 func (d *Decoder) step_acceptMapKeyOrBreak(tokenSlot *Token) (done bool, err error) {
-	majorByte, err := readn1skippingWhitespace(d.r)
+	majorByte, err := d.readEntrySep('}')
 	if err != nil {
 		return true, err
 	}
-	if d.some {
-		switch majorByte {
-		case '}':
-			tokenSlot.Type = TMapClose
-			return true, nil
-		case ',':
-			majorByte, err = readn1skippingWhitespace(d.r)
-			if err != nil {
-				return true, err
-			}
-			// and now fall through to the next switch
-		}
-	}
-	switch majorByte {
-	case '}':
+	if majorByte == '}' {
 		tokenSlot.Type = TMapClose
 		return true, nil
+	}
+	if err := d.countEntry(); err != nil {
+		return true, err
+	}
+	// Consume a string for key.
+	tokenSlot.Type = TString
+	switch {
+	case d.cfg.Syntax == SyntaxMode_JSON5 && (majorByte == '"' || majorByte == '\''):
+		tokenSlot.Str, err = d.decodeString5(majorByte)
+	case majorByte == '"':
+		tokenSlot.Str, err = d.decodeString()
+	case d.cfg.Syntax == SyntaxMode_JSON5 && isIdentifierByte(majorByte):
+		tokenSlot.Str, err = d.decodeIdentifier()
 	default:
-		// Consume a string for key.
-		_, err := d.stepHelper_acceptValue(majorByte, tokenSlot) // FIXME surely not *any* value?  not composites, at least?
-		// Now scan up to consume the colon as well, which is required next.
-		majorByte, err = readn1skippingWhitespace(d.r)
-		if err != nil {
-			return true, err
-		}
-		if majorByte != ':' {
-			return true, fmt.Errorf("expected colon after map key; got 0x%x", majorByte)
-		}
-		// Next up: expect a value.
-		d.step = d.step_acceptMapValue
-		d.some = true
-		return false, err
+		return true, d.errSyntax(1, "expected string for map key; got "+quoteByte(majorByte))
+	}
+	if err != nil {
+		return true, err
 	}
+	// Now scan up to consume the colon as well, which is required next.
+	majorByte, err = d.readn1skippingWhitespace()
+	if err != nil {
+		return true, err
+	}
+	if majorByte != ':' {
+		return true, d.errSyntax(1, "expected ':' after map key; got "+quoteByte(majorByte))
+	}
+	// Next up: expect a value.
+	d.step = d.step_acceptMapValue
+	d.some = true
+	return false, nil
 }
 
 // Step in midst of decoding a map, value expected up next.
 func (d *Decoder) step_acceptMapValue(tokenSlot *Token) (done bool, err error) {
-	majorByte, err := readn1skippingWhitespace(d.r)
+	majorByte, err := d.readn1skippingWhitespace()
 	if err != nil {
 		return true, err
 	}
@@ -164,44 +250,71 @@ func (d *Decoder) step_acceptMapValue(tokenSlot *Token) (done bool, err error) {
 	return false, err
 }
 
+// Read the first byte of the next entry in a map or array, consuming the
+// comma before it if one is needed.  Returns `close` if there's no next entry.
+func (d *Decoder) readEntrySep(close byte) (majorByte byte, err error) {
+	majorByte, err = d.readn1skippingWhitespace()
+	if err != nil || majorByte == close {
+		return
+	}
+	if !d.some {
+		return
+	}
+	if majorByte != ',' {
+		return 0, d.errSyntax(1, "expected ',' or "+quoteByte(close)+"; got "+quoteByte(majorByte))
+	}
+	majorByte, err = d.readn1skippingWhitespace()
+	if err != nil {
+		return
+	}
+	if majorByte == close && !d.lenient() {
+		return 0, d.errSyntax(1, "unexpected "+quoteByte(close)+" after ','")
+	}
+	return
+}
+
 func (d *Decoder) stepHelper_acceptValue(majorByte byte, tokenSlot *Token) (done bool, err error) {
+	if d.cfg.Syntax == SyntaxMode_JSON5 {
+		switch majorByte {
+		case '"', '\'':
+			tokenSlot.Type = TString
+			tokenSlot.Str, err = d.decodeString5(majorByte)
+			return true, err
+		case '-', '+', '.', 'I', 'N', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+			return true, d.decodeNumber5(tokenSlot)
+		}
+	}
 	switch majorByte {
 	case '{':
 		tokenSlot.Type = TMapOpen
 		tokenSlot.Length = -1
-		d.pushPhase(d.step_acceptMapKeyOrBreak)
-		return false, nil
+		return false, d.pushPhase(d.step_acceptMapKeyOrBreak)
 	case '[':
 		tokenSlot.Type = TArrOpen
 		tokenSlot.Length = -1
-		d.pushPhase(d.step_acceptArrValueOrBreak)
-		return false, nil
+		return false, d.pushPhase(d.step_acceptArrValueOrBreak)
 	case 'n':
-		d.r.Readnzc(3) // FIXME must check these equal "ull"!
 		tokenSlot.Type = TNull
-		return true, nil
+		return true, d.decodeLiteral(wordNull)
 	case '"':
 		tokenSlot.Type = TString
 		tokenSlot.Str, err = d.decodeString()
 		return true, err
 	case 'f':
-		d.r.Readnzc(4) // FIXME must check these equal "alse"!
 		tokenSlot.Type = TBool
 		tokenSlot.Bool = false
-		return true, nil
+		return true, d.decodeLiteral(wordFalse)
 	case 't':
-		d.r.Readnzc(3) // FIXME must check these equal "rue"!
 		tokenSlot.Type = TBool
 		tokenSlot.Bool = true
-		return true, nil
+		return true, d.decodeLiteral(wordTrue)
 	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
 		// Some kind of numeric... but in json, we *can't tell* if it's float or int.
 		// JSON in general doesn't differentiate.  But we usually try to anyway.
 		// (If this results in us yielding an int, and an obj.Unmarshaller is filling a float,
 		// it's the Unmarshaller responsibility to decide to cast that.)
-		tokenSlot.Type, tokenSlot.Int, tokenSlot.Float64, err = d.decodeNumber(majorByte)
-		return true, err
+		return true, d.decodeNumber(majorByte, tokenSlot)
 	default:
-		return true, fmt.Errorf("Invalid byte while expecting start of value: 0x%x", majorByte)
+		return true, d.errSyntax(1, "unexpected "+quoteByte(majorByte)+" while expecting start of value")
 	}
 }
@@ -0,0 +1,49 @@
+package json
+
+import (
+	"encoding/base64"
+
+	. "github.com/polydawn/refmt/tok"
+)
+
+// The shape of the DAG-JSON bytes form, `{"/":{"bytes":"..."}}`,
+// after the opening of the outer map.
+var dagBytesShape = []func(*Token) bool{
+	func(tok *Token) bool { return tok.Type == TString && tok.Str == "/" },
+	func(tok *Token) bool { return tok.Type == TMapOpen },
+	func(tok *Token) bool { return tok.Type == TString && tok.Str == "bytes" },
+	func(tok *Token) bool { return tok.Type == TString },
+	func(tok *Token) bool { return tok.Type == TMapClose },
+	func(tok *Token) bool { return tok.Type == TMapClose },
+}
+
+/*
+	Step, turning the DAG-JSON bytes form into a single bytes token.
+
+	Whenever a map opens, we read ahead to see if it has exactly that form.
+	If it does, we yield one bytes token in place of all of it.
+	If it doesn't, we yield the tokens we read ahead one at a time, just as
+	if we hadn't looked, and carry on from there.
+	(Maps which were read ahead aren't themselves checked for the form;
+	they're already known to be part of a map which isn't.)
+*/
+func (d *Decoder) stepDagBytes(tokenSlot *Token) (done bool, err error) {
+	if d.ahead.Pending() {
+		return d.ahead.Step(tokenSlot)
+	}
+	done, err = d.ahead.Step(tokenSlot)
+	if err != nil || done || tokenSlot.Type != TMapOpen {
+		return
+	}
+	toks, done := d.ahead.Match(dagBytesShape)
+	if toks == nil {
+		return false, nil
+	}
+	bs, err := base64.RawStdEncoding.DecodeString(toks[3].Str)
+	if err != nil {
+		return true, d.errSyntax(0, "invalid base64 in bytes: "+err.Error())
+	}
+	tokenSlot.Type = TBytes
+	tokenSlot.Bytes = bs
+	return done, nil
+}
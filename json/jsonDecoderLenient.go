@@ -0,0 +1,304 @@
+package json
+
+import (
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/polydawn/refmt/shared"
+	"github.com/polydawn/refmt/tok"
+)
+
+// Reports whether comments and trailing commas are allowed.
+func (d *Decoder) lenient() bool {
+	return d.cfg.Syntax == SyntaxMode_JSONC || d.cfg.Syntax == SyntaxMode_JSON5
+}
+
+// Skip the rest of a comment (its leading '/' has already been eaten).
+func (d *Decoder) skipComment() error {
+	c, err := d.r.Readn1()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	switch c {
+	case '/': // Runs to the end of the line, or of the input.
+		for {
+			c, err = d.r.Readn1()
+			if err == io.EOF || c == '\n' {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := d.checkTotalBytes(); err != nil {
+				return err
+			}
+		}
+	case '*': // Runs to the next "*/".
+		for star := false; ; star = c == '*' {
+			c, err = d.r.Readn1()
+			if err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return err
+			}
+			if err := d.checkTotalBytes(); err != nil {
+				return err
+			}
+			if star && c == '/' {
+				return nil
+			}
+		}
+	default:
+		return d.errSyntax(2, "invalid comment; expected '//' or '/*'")
+	}
+}
+
+// Decode a JSON5 string, quoted with either `"` or `'`.
+// (The first quote has already been eaten.)
+//
+// JSON5 strings have more escapes than JSON's (`\'`, `\v`, `\0`, `\xHH`,
+// and escaped line breaks, which are dropped), and any other character
+// may be escaped to stand for itself.  Unescaped line breaks are invalid,
+// but other control characters are allowed.
+func (d *Decoder) decodeString5(quote byte) (string, error) {
+	var buf []byte
+	hi := rune(-1) // A high surrogate from a \u escape, waiting for its pair.
+	flush := func() { // A high surrogate without its pair comes out as the replacement character.
+		if hi >= 0 {
+			buf = append(buf, string(unicode.ReplacementChar)...)
+			hi = -1
+		}
+	}
+	put := func(r rune) {
+		flush()
+		buf = append(buf, string(r)...)
+	}
+	for {
+		c, err := d.r.Readn1()
+		if err != nil {
+			return "", err
+		}
+		if err := d.checkTotalBytes(); err != nil {
+			return "", err
+		}
+		switch c {
+		case quote:
+			flush()
+			if !utf8.Valid(buf) {
+				// Coerce to well-formed UTF-8, as the strict decoder does.
+				buf = []byte(string([]rune(string(buf))))
+			}
+			return string(buf), nil
+		case '\n', '\r':
+			return "", d.errSyntax(1, "invalid line break in string literal")
+		case '\\':
+			c, err = d.r.Readn1()
+			if err != nil {
+				return "", err
+			}
+			switch c {
+			case 'b':
+				put('\b')
+			case 'f':
+				put('\f')
+			case 'n':
+				put('\n')
+			case 'r':
+				put('\r')
+			case 't':
+				put('\t')
+			case 'v':
+				put('\v')
+			case '0':
+				if c, err := d.r.Readn1(); err == nil {
+					d.r.Unreadn1()
+					if '0' <= c && c <= '9' {
+						return "", d.errSyntax(2, "invalid digit after \\0 in string escape sequence")
+					}
+				}
+				put(0)
+			case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+				return "", d.errSyntax(2, "invalid digit in string escape sequence")
+			case 'x':
+				r, err := d.readHex(2)
+				if err != nil {
+					return "", err
+				}
+				put(r)
+			case 'u':
+				r, err := d.readHex(4)
+				if err != nil {
+					return "", err
+				}
+				switch {
+				case hi >= 0 && 0xDC00 <= r && r < 0xE000:
+					buf = append(buf, string(utf16.DecodeRune(hi, r))...)
+					hi = -1
+				case 0xD800 <= r && r < 0xDC00:
+					flush()
+					hi = r
+				default:
+					put(r) // Lone low surrogates come out as the replacement character.
+				}
+			case '\r': // A line continuation; swallow a following '\n' too.
+				if c, err := d.r.Readn1(); err == nil && c != '\n' {
+					d.r.Unreadn1()
+				}
+			case '\n': // A line continuation.
+			default: // Anything else stands for itself.  (The rest of a multibyte character follows as usual.)
+				flush()
+				buf = append(buf, c)
+			}
+		default:
+			flush()
+			buf = append(buf, c)
+		}
+		if err := shared.CheckLimit("MaxStringLength", int64(d.cfg.MaxStringLength), int64(len(buf))); err != nil {
+			return "", err
+		}
+	}
+}
+
+// Read `n` hex digits of a string escape sequence.
+func (d *Decoder) readHex(n int) (rune, error) {
+	bs, err := d.r.Readnzc(n)
+	if err != nil {
+		return 0, err
+	}
+	r, err := strconv.ParseUint(string(bs), 16, 32)
+	if err != nil {
+		return 0, d.errSyntax(n, "invalid byte in hexadecimal character escape")
+	}
+	return rune(r), nil
+}
+
+// Reports whether `c` can start a JSON5 identifier.
+// Any non-ASCII byte might; decodeIdentifier checks the whole thing.
+func isIdentifierByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_' || c == '$' || c >= utf8.RuneSelf
+}
+
+// Decode an unquoted JSON5 map key (its first byte has already been eaten).
+// Identifiers follow the ECMAScript IdentifierName rules, except that
+// `\u` escapes aren't supported in them.
+func (d *Decoder) decodeIdentifier() (string, error) {
+	d.r.Unreadn1()
+	d.r.Track()
+	d.r.Readn1()
+	for {
+		c, err := d.r.Readn1()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if err := d.checkTotalBytes(); err != nil {
+			return "", err
+		}
+		if !isIdentifierByte(c) && !('0' <= c && c <= '9') {
+			d.r.Unreadn1()
+			break
+		}
+	}
+	s := string(d.r.StopTrack())
+	for i, r := range s {
+		ok := unicode.IsLetter(r) || r == '_' || r == '$' || unicode.Is(unicode.Nl, r)
+		if i > 0 {
+			ok = ok || unicode.IsDigit(r) || unicode.In(r, unicode.Mn, unicode.Mc, unicode.Pc) || r == '\u200C' || r == '\u200D'
+		}
+		if !ok {
+			return "", d.errSyntax(len(s), "invalid identifier "+strconv.Quote(s)+" for map key")
+		}
+	}
+	if err := shared.CheckLimit("MaxStringLength", int64(d.cfg.MaxStringLength), int64(len(s))); err != nil {
+		return "", err
+	}
+	return s, nil
+}
+
+// Decode a JSON5 number (its first byte has already been eaten).
+//
+// On top of JSON's numbers, JSON5 allows a leading '+', leading and
+// trailing decimal points, hex integers, Infinity, and NaN.
+func (d *Decoder) decodeNumber5(tokenSlot *tok.Token) error {
+	d.r.Unreadn1()
+	d.r.Track()
+	d.r.Readn1()
+	// Scan for everything that could be part of a number, and sort it out after.
+	for {
+		c, err := d.r.Readn1()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := d.checkTotalBytes(); err != nil {
+			return err
+		}
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '.' || c == '+' || c == '-') {
+			d.r.Unreadn1()
+			break
+		}
+	}
+	s := string(d.r.StopTrack())
+	body, neg := s, false
+	switch s[0] {
+	case '+':
+		body = s[1:]
+	case '-':
+		body, neg = s[1:], true
+	}
+	switch {
+	case body == "Infinity":
+		tokenSlot.Type, tokenSlot.Float64 = tok.TFloat64, math.Inf(1)
+		if neg {
+			tokenSlot.Float64 = math.Inf(-1)
+		}
+		return nil
+	case body == "NaN":
+		tokenSlot.Type, tokenSlot.Float64 = tok.TFloat64, math.NaN()
+		return nil
+	case len(body) > 2 && body[0] == '0' && (body[1] == 'x' || body[1] == 'X'):
+		u, err := strconv.ParseUint(body[2:], 16, 64)
+		switch {
+		case err != nil && err.(*strconv.NumError).Err == strconv.ErrRange:
+			return d.errSyntax(len(s), "hex numeric literal out of range: "+s)
+		case err != nil:
+			return d.errSyntax(len(s), "invalid hex numeric literal: "+s)
+		case !neg && u <= math.MaxInt64:
+			tokenSlot.Type, tokenSlot.Int = tok.TInt, int64(u)
+		case !neg:
+			tokenSlot.Type, tokenSlot.Uint = tok.TUint, u
+		case u <= 1<<63:
+			tokenSlot.Type, tokenSlot.Int = tok.TInt, -int64(u)
+		default:
+			return d.errSyntax(len(s), "hex numeric literal out of range: "+s)
+		}
+		return nil
+	}
+	// Fill in the zeros JSON requires around a decimal point, then it's a JSON number.
+	if strings.HasPrefix(body, ".") {
+		body = "0" + body
+	}
+	if i := strings.IndexByte(body, '.'); i >= 0 && (i+1 == len(body) || body[i+1] == 'e' || body[i+1] == 'E') {
+		body = body[:i+1] + "0" + body[i+1:]
+	}
+	if neg {
+		body = "-" + body
+	}
+	if !isNumber(body) {
+		return d.errSyntax(len(s), "invalid numeric literal: "+s)
+	}
+	return d.parseNumber(body, tokenSlot)
+}
@@ -6,29 +6,71 @@ package json
 // and is governed by a BSD-style license.
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"math/big"
 	"strconv"
 	"unicode"
 	"unicode/utf16"
 	"unicode/utf8"
 
+	"github.com/polydawn/refmt/shared"
 	"github.com/polydawn/refmt/tok"
 )
 
+// Check the rest of a literal word (its first byte has already been eaten).
+func (d *Decoder) decodeLiteral(word []byte) error {
+	bs, err := d.r.Readnzc(len(word) - 1)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(bs, word[1:]) {
+		return d.errSyntax(len(word), fmt.Sprintf("invalid literal; expected %q", word))
+	}
+	return nil
+}
+
 func (d *Decoder) decodeString() (string, error) {
 	// First quote has already been eaten.
 	// Start tracking the byte slice; real string starts here.
 	d.r.Track()
 	// Scan until scanner tells us end of string.
+	// Meanwhile, count a lower bound on the unescaped length (every escape
+	// sequence stands for at least one byte), so a string that's too long
+	// is rejected before we've buffered much more than the limit.
+	n, esc := 0, 0 // esc: bytes left in the current escape sequence; -1 just after a backslash.
 	for step := strscan_normal; step != nil; {
 		majorByte, err := d.r.Readn1()
 		if err != nil {
 			return "", err
 		}
+		if err := d.checkTotalBytes(); err != nil {
+			return "", err
+		}
 		step, err = step(majorByte)
 		if err != nil {
-			return "", err
+			d.r.StopTrack()
+			return "", d.errSyntax(1, err.Error())
+		}
+		switch {
+		case step == nil: // The closing quote.
+		case esc < 0:
+			esc = 0
+			if majorByte == 'u' {
+				esc = 4
+			}
+		case esc > 0:
+			esc--
+		default:
+			if majorByte == '\\' {
+				esc = -1
+			}
+			n++
+			if err := shared.CheckLimit("MaxStringLength", int64(d.cfg.MaxStringLength), int64(n)); err != nil {
+				d.r.StopTrack()
+				return "", err
+			}
 		}
 	}
 	// Unread one.  The scan loop consumed the trailing quote already,
@@ -41,6 +83,10 @@ func (d *Decoder) decodeString() (string, error) {
 	}
 	// Swallow the trailing quote again.
 	d.r.Readn1()
+	// Escapes may have made it longer than the count above.
+	if err := shared.CheckLimit("MaxStringLength", int64(d.cfg.MaxStringLength), int64(len(s))); err != nil {
+		return "", err
+	}
 	return string(s), nil
 }
 
@@ -230,9 +276,11 @@ func getu4(s []byte) rune {
 	return rune(r)
 }
 
-// Returns *either* an int or a float -- json is ambigous.
-// An int is preferred if possible.
-func (d *Decoder) decodeNumber(majorByte byte) (tok.TokenType, int64, float64, error) {
+// Decodes a number into an int, a uint, or a float -- json is ambiguous.
+// An int is preferred if possible, then a uint (for large positive integers).
+// Numbers a float can't carry without loss are yielded as a string of the
+// literal text, if so configured.
+func (d *Decoder) decodeNumber(majorByte byte, tokenSlot *tok.Token) error {
 	// First byte has already been eaten.
 	// Easiest to unread1, so we can use track, then swallow it again.
 	d.r.Unreadn1()
@@ -240,26 +288,31 @@ func (d *Decoder) decodeNumber(majorByte byte) (tok.TokenType, int64, float64, e
 	d.r.Readn1()
 	// Scan until scanner tells us end of numeric.
 	// Pick the first scanner stepfunc based on the leading byte.
-	var step numscanStep
-	switch majorByte {
-	case '-':
-		step = numscan_neg
-	case '0':
-		step = numscan_0
-	case '1', '2', '3', '4', '5', '6', '7', '8', '9':
-		step = numscan_1
-	default:
+	step := numscan_start(majorByte)
+	if step == nil {
 		panic("unreachable")
 	}
 	for {
 		b, err := d.r.Readn1()
 		if err == io.EOF {
+			// The end of input ends the number too... if it's complete.
+			if _, err = step(' '); err != nil {
+				d.r.StopTrack()
+				return io.ErrUnexpectedEOF
+			}
 			break
 		}
 		if err != nil {
-			return 0, 0, 0, err
+			return err
+		}
+		if err := d.checkTotalBytes(); err != nil {
+			return err
 		}
 		step, err = step(b)
+		if err != nil {
+			d.r.StopTrack()
+			return d.errSyntax(1, err.Error())
+		}
 		if step == nil {
 			// Unread one.  The scan loop consumed one char beyond the end
 			// (this is necessary in json!),
@@ -267,19 +320,76 @@ func (d *Decoder) decodeNumber(majorByte byte) (tok.TokenType, int64, float64, e
 			d.r.Unreadn1()
 			break
 		}
-		if err != nil {
-			return 0, 0, 0, err
-		}
 	}
 	// Parse!
+	return d.parseNumber(string(d.r.StopTrack()), tokenSlot)
+}
+
+// Parses a valid number literal into a token; see decodeNumber.
+func (d *Decoder) parseNumber(s string, tokenSlot *tok.Token) error {
 	// *This is not a fast parse*.
-	// Try int first; if it fails try float; if that fails return the float error.
-	s := string(d.r.StopTrack())
+	// Try int first; then uint; then float.
 	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
-		return tok.TInt, i, 0, nil
+		tokenSlot.Type, tokenSlot.Int = tok.TInt, i
+		return nil
+	}
+	if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+		tokenSlot.Type, tokenSlot.Uint = tok.TUint, u
+		return nil
 	}
 	f, err := strconv.ParseFloat(s, 64)
-	return tok.TFloat64, 0, f, err
+	if d.cfg.BigNumbers == BigNumberMode_String && (err != nil || !floatIsExact(s, f)) {
+		tokenSlot.Type, tokenSlot.Str = tok.TString, s
+		return nil
+	}
+	tokenSlot.Type, tokenSlot.Float64 = tok.TFloat64, f
+	return err
+}
+
+// Reports whether the number literal `s` has the same value as the
+// shortest decimal form of the float `f` it was parsed as.
+// If not, the literal had more precision (or range) than a float can carry.
+func floatIsExact(s string, f float64) bool {
+	if f == 0 {
+		// Checked separately, because exponents of tiny literals (which
+		// round to zero) are unbounded, and big.Rat would go to great
+		// lengths to compute them.
+		for _, c := range s {
+			if c == 'e' || c == 'E' {
+				break
+			}
+			if '1' <= c && c <= '9' {
+				return false
+			}
+		}
+		return true
+	}
+	var lit, short big.Rat
+	if _, ok := lit.SetString(s); !ok {
+		return false
+	}
+	short.SetString(strconv.FormatFloat(f, 'g', -1, 64))
+	return lit.Cmp(&short) == 0
+}
+
+// Reports whether `s` is exactly one valid JSON number.
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	step := numscan_start(s[0])
+	for i := 1; step != nil && i < len(s); i++ {
+		var err error
+		if step, err = step(s[i]); err != nil {
+			return false
+		}
+	}
+	if step == nil {
+		return false
+	}
+	// The end ends the number, if it's complete.
+	_, err := step(' ')
+	return err == nil
 }
 
 // Scan steps are looped over the stream to find how long the number is.
@@ -287,6 +397,21 @@ func (d *Decoder) decodeNumber(majorByte byte) (tok.TokenType, int64, float64, e
 // Actually parsing the string is done by 'parseString()'.
 type numscanStep func(c byte) (numscanStep, error)
 
+// numscan_start returns the state after reading the first byte of a number,
+// or nil if the byte can't start one.
+func numscan_start(c byte) numscanStep {
+	switch {
+	case c == '-':
+		return numscan_neg
+	case c == '0':
+		return numscan_0
+	case '1' <= c && c <= '9':
+		return numscan_1
+	default:
+		return nil
+	}
+}
+
 // numscan_neg is the state after reading `-` during a number.
 func numscan_neg(c byte) (numscanStep, error) {
 	if c == '0' {
@@ -304,11 +429,19 @@ func numscan_1(c byte) (numscanStep, error) {
 	if '0' <= c && c <= '9' {
 		return numscan_1, nil
 	}
-	return numscan_0(c)
+	return numscan_int(c)
 }
 
 // numscan_0 is the state after reading `0` during a number.
 func numscan_0(c byte) (numscanStep, error) {
+	if '0' <= c && c <= '9' {
+		return nil, fmt.Errorf("invalid leading zero in numeric literal")
+	}
+	return numscan_int(c)
+}
+
+// numscan_int is the state after reading the integer part of a number.
+func numscan_int(c byte) (numscanStep, error) {
 	if c == '.' {
 		return numscan_dot, nil
 	}
@@ -3,11 +3,19 @@ package json
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"math"
+	"math/big"
 	"strings"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 
+	"github.com/polydawn/refmt/obj"
+	"github.com/polydawn/refmt/obj/atlas"
+	"github.com/polydawn/refmt/obj/atlas/common"
+	"github.com/polydawn/refmt/shared"
 	. "github.com/polydawn/refmt/tok"
 )
 
@@ -49,10 +57,477 @@ func TestJsonDecoder(t *testing.T) {
 					}
 					Convey("Result", FailureContinues, func() {
 						So(nStep, ShouldEqual, expectSteps)
-						So(err, ShouldEqual, tr.decodeResult)
+						So(err, ShouldResemble, tr.decodeResult)
 					})
 				})
 			})
 		}
 	})
 }
+
+func TestJsonDecoderSyntaxErrors(t *testing.T) {
+	tt := []struct {
+		serial    string
+		expectErr error
+	}{
+		{`nulx`, ErrSyntax{0, `invalid literal; expected "null"`}},
+		{`[tru]`, ErrSyntax{1, `invalid literal; expected "true"`}},
+		{`{1:2}`, ErrSyntax{1, `expected string for map key; got '1'`}},
+		{`{"a":1 "b":2}`, ErrSyntax{7, `expected ',' or '}'; got '"'`}},
+		{`[1 2]`, ErrSyntax{3, `expected ',' or ']'; got '2'`}},
+		{`[,1]`, ErrSyntax{1, `unexpected ',' while expecting start of value`}},
+		{`012`, ErrSyntax{1, `invalid leading zero in numeric literal`}},
+		{`"\x"`, ErrSyntax{2, `invalid byte in string escape sequence: 0x78`}},
+		{`{} x`, ErrSyntax{3, `unexpected 'x' after top-level value`}},
+		{`[1,`, io.ErrUnexpectedEOF},
+		{`-`, io.ErrUnexpectedEOF},
+		{` `, io.EOF},
+	}
+	for _, tr := range tt {
+		d := NewDecoder(bytes.NewBufferString(tr.serial))
+		var tok Token
+		var err error
+		for done := false; !done; {
+			done, err = d.Step(&tok)
+		}
+		if err == nil {
+			err = d.CheckTrailing()
+		}
+		if err != tr.expectErr {
+			t.Errorf("test %q: expected error %v, got %v", tr.serial, tr.expectErr, err)
+		}
+	}
+}
+
+func TestJsonDecoderLenient(t *testing.T) {
+	// Each lenient document should yield exactly the tokens of its strict equivalent.
+	tt := []struct {
+		mode   SyntaxMode
+		serial string
+		strict string
+	}{
+		{SyntaxMode_JSONC, "// lead\n{\"a\": 1, /* mid */ \"b\": [1, 2,],} // trail", `{"a":1,"b":[1,2]}`},
+		{SyntaxMode_JSONC, "[/**/1/***/,/* * / */2]/*", ``},
+		{SyntaxMode_JSONC, "/* only */", ``},
+		{SyntaxMode_JSON5, `{unquoted: 'single', $x_1: "dbl", 'q': 'it\'s "so"'}`, `{"unquoted":"single","$x_1":"dbl","q":"it's \"so\""}`},
+		{SyntaxMode_JSON5, `['\x41\0\v', '\u00e9\uD83D\uDE00', 'a\
+b', '\q']`, `["A\u0000\u000b","\u00e9\ud83d\ude00","ab","q"]`},
+		{SyntaxMode_JSON5, `[0x1F, -0XfF, +1, .5, 5., -.5e1, 0xFFFFFFFFFFFFFFFF, -0x8000000000000000]`, `[31,-255,1,0.5,5.0,-0.5e1,18446744073709551615,-9223372036854775808]`},
+		{SyntaxMode_JSON5, "{\u00e9t\u00e9: 1}", `{"\u00e9t\u00e9":1}`},
+	}
+	collect := func(d *Decoder) (toks []string, err error) {
+		for done := false; !done; {
+			var tok Token
+			done, err = d.Step(&tok)
+			toks = append(toks, tok.String())
+		}
+		if err == nil {
+			err = d.CheckTrailing()
+		}
+		return
+	}
+	for _, tr := range tt {
+		got, err := collect(NewDecoderWithOptions(DecodeOptions{Syntax: tr.mode}, strings.NewReader(tr.serial)))
+		if tr.strict == `` {
+			if err != io.ErrUnexpectedEOF && err != io.EOF {
+				t.Errorf("%s %q: expected EOF error, got %v", tr.mode, tr.serial, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %q: unexpected error %v", tr.mode, tr.serial, err)
+			continue
+		}
+		expect, _ := collect(NewDecoder(strings.NewReader(tr.strict)))
+		if strings.Join(got, " ") != strings.Join(expect, " ") {
+			t.Errorf("%s %q:\n\texpected %v\n\tgot      %v", tr.mode, tr.serial, expect, got)
+		}
+	}
+
+	Convey("JSON5 Infinity and NaN", t, func() {
+		var v []float64
+		So(UnmarshalWithOptions(DecodeOptions{Syntax: SyntaxMode_JSON5}, []byte(`[Infinity, -Infinity, NaN]`), &v, atlas.MustBuild()), ShouldBeNil)
+		So(math.IsInf(v[0], 1), ShouldBeTrue)
+		So(math.IsInf(v[1], -1), ShouldBeTrue)
+		So(math.IsNaN(v[2]), ShouldBeTrue)
+	})
+
+	Convey("Lenient syntax is rejected unless asked for", t, func() {
+		errs := []struct {
+			mode      SyntaxMode
+			serial    string
+			expectErr error
+		}{
+			{"", `[1,]`, ErrSyntax{3, `unexpected ']' after ','`}},
+			{"", `[1]//`, ErrSyntax{3, `unexpected '/' after top-level value`}},
+			{SyntaxMode_JSONC, `[1,,]`, ErrSyntax{3, `unexpected ',' while expecting start of value`}},
+			{SyntaxMode_JSONC, `['a']`, ErrSyntax{1, `unexpected '\'' while expecting start of value`}},
+			{SyntaxMode_JSONC, `{a:1}`, ErrSyntax{1, `expected string for map key; got 'a'`}},
+			{SyntaxMode_JSONC, `[0x1]`, ErrSyntax{2, `expected ',' or ']'; got 'x'`}},
+			{SyntaxMode_JSONC, `[1]/x`, ErrSyntax{3, `invalid comment; expected '//' or '/*'`}},
+			{SyntaxMode_JSON5, `{1a:1}`, ErrSyntax{1, `expected string for map key; got '1'`}},
+			{SyntaxMode_JSON5, `[0x]`, ErrSyntax{1, `invalid numeric literal: 0x`}},
+			{SyntaxMode_JSON5, `[0x10000000000000000]`, ErrSyntax{1, `hex numeric literal out of range: 0x10000000000000000`}},
+			{SyntaxMode_JSON5, `[Infinite]`, ErrSyntax{1, `invalid numeric literal: Infinite`}},
+			{SyntaxMode_JSON5, "['a\nb']", ErrSyntax{3, `invalid line break in string literal`}},
+			{SyntaxMode_JSON5, `['\01']`, ErrSyntax{2, `invalid digit after \0 in string escape sequence`}},
+			{SyntaxMode_JSON5, `['\xZZ']`, ErrSyntax{4, `invalid byte in hexadecimal character escape`}},
+		}
+		for _, tr := range errs {
+			_, err := collect(NewDecoderWithOptions(DecodeOptions{Syntax: tr.mode}, strings.NewReader(tr.serial)))
+			So(err, ShouldResemble, tr.expectErr)
+		}
+	})
+}
+
+func TestJsonDecoderLimits(t *testing.T) {
+	tt := []struct {
+		title     string
+		opts      DecodeOptions
+		serial    string
+		expectErr error
+	}{
+		{"long string",
+			DecodeOptions{MaxStringLength: 3},
+			`"abcd"`,
+			shared.ErrLimitExceeded{Limit: "MaxStringLength", Max: 3, Got: 4}},
+		{"escaped string within limit",
+			DecodeOptions{MaxStringLength: 3},
+			`"a\u00e9"`,
+			nil},
+		{"escaped string over limit",
+			DecodeOptions{MaxStringLength: 3},
+			`"\u00e9\u00e9"`,
+			shared.ErrLimitExceeded{Limit: "MaxStringLength", Max: 3, Got: 4}},
+		{"deep nesting",
+			DecodeOptions{MaxDepth: 2},
+			`[[[1]]]`,
+			shared.ErrLimitExceeded{Limit: "MaxDepth", Max: 2, Got: 3}},
+		{"nesting at limit",
+			DecodeOptions{MaxDepth: 2},
+			`[{"a":1},[2]]`,
+			nil},
+		{"long array",
+			DecodeOptions{MaxContainerLength: 2},
+			`[1,2,3]`,
+			shared.ErrLimitExceeded{Limit: "MaxContainerLength", Max: 2, Got: 3}},
+		{"long map",
+			DecodeOptions{MaxContainerLength: 1},
+			`{"a":[1],"b":2}`,
+			shared.ErrLimitExceeded{Limit: "MaxContainerLength", Max: 1, Got: 2}},
+		{"containers counted separately",
+			DecodeOptions{MaxContainerLength: 2},
+			`[[1,2],[3,4]]`,
+			nil},
+		{"total bytes",
+			DecodeOptions{MaxTotalBytes: 8},
+			`"abcdefghijkl"`,
+			shared.ErrLimitExceeded{Limit: "MaxTotalBytes", Max: 8, Got: 9}},
+	}
+	for _, tr := range tt {
+		d := NewDecoderWithOptions(tr.opts, bytes.NewBufferString(tr.serial))
+		var tok Token
+		var err error
+		for done := false; !done; {
+			done, err = d.Step(&tok)
+		}
+		if err == nil {
+			err = d.CheckTrailing()
+		}
+		if err != tr.expectErr {
+			t.Errorf("test %q: expected error %v, got %v", tr.title, tr.expectErr, err)
+		}
+	}
+	// A string with no end is rejected once it passes the limit, not buffered forever.
+	d := NewDecoderWithOptions(DecodeOptions{MaxStringLength: 1000}, io.MultiReader(strings.NewReader(`"`), endlessReader('a')))
+	var tok Token
+	if _, err := d.Step(&tok); err != (shared.ErrLimitExceeded{Limit: "MaxStringLength", Max: 1000, Got: 1001}) {
+		t.Errorf("endless string: expected limit error, got %v", err)
+	}
+}
+
+// Yields the same byte forever.
+type endlessReader byte
+
+func (r endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(r)
+	}
+	return len(p), nil
+}
+
+func TestJsonUnmarshalConcatenated(t *testing.T) {
+	// Several values on one reader are each unmarshalled in turn.
+	u := NewUnmarshaller(strings.NewReader(`{"a":1} {"a":2}`))
+	var v1, v2 map[string]int
+	if err := u.Unmarshal(&v1); err != nil {
+		t.Errorf("first value: unexpected error %v", err)
+	}
+	if err := u.Unmarshal(&v2); err != nil {
+		t.Errorf("second value: unexpected error %v", err)
+	}
+	if v1["a"] != 1 || v2["a"] != 2 {
+		t.Errorf("expected a=1 then a=2, got %v then %v", v1, v2)
+	}
+	var v3 map[string]int
+	if err := u.Unmarshal(&v3); err != io.EOF {
+		t.Errorf("expected EOF after the last value, got %v", err)
+	}
+
+	// A complete value is unmarshalled without waiting for the writer to close.
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go pw.Write([]byte(`{"a":3}`))
+	result := make(chan error, 1)
+	var v4 map[string]int
+	go func() { result <- NewUnmarshaller(pr).Unmarshal(&v4) }()
+	select {
+	case err := <-result:
+		if err != nil || v4["a"] != 3 {
+			t.Errorf("expected a=3, got %v (error %v)", v4, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("unmarshalling from a pipe blocked after a complete value")
+	}
+
+	// But the []byte helpers still reject trailing garbage.
+	var v5 map[string]int
+	if err := Unmarshal([]byte(`{"a":1} {"a":2}`), &v5); err != (ErrSyntax{8, `unexpected '{' after top-level value`}) {
+		t.Errorf("expected trailing value to be rejected, got %v", err)
+	}
+}
+
+func TestJsonUnmarshalAllocBudget(t *testing.T) {
+	var slot []string
+	err := UnmarshalWithOptions(DecodeOptions{MaxAllocBudget: 9}, []byte(`["abc","def"]`), &slot, atlas.MustBuild())
+	if err != (shared.ErrLimitExceeded{Limit: "AllocBudget", Max: 9, Got: 10}) {
+		t.Errorf("expected budget error, got %v", err)
+	}
+	err = UnmarshalWithOptions(DecodeOptions{MaxAllocBudget: 10}, []byte(`["abc","def"]`), &slot, atlas.MustBuild())
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestJsonDecoderDagBytes(t *testing.T) {
+	tt := []struct {
+		title  string
+		serial string
+		expect []Token
+	}{
+		{"bytes",
+			`{"/":{"bytes":"+/8B"}}`,
+			[]Token{{Type: TBytes, Bytes: []byte{0xfb, 0xff, 0x01}}}},
+		{"bytes with whitespace, in an array",
+			`[ { "/" : { "bytes" : "" } } , 1 ]`,
+			[]Token{
+				{Type: TArrOpen, Length: -1},
+				{Type: TBytes, Bytes: []byte{}},
+				{Type: TInt, Int: 1},
+				{Type: TArrClose},
+			}},
+		{"not bytes: other key",
+			`{"/x":1}`,
+			[]Token{
+				{Type: TMapOpen, Length: -1},
+				{Type: TString, Str: "/x"},
+				{Type: TInt, Int: 1},
+				{Type: TMapClose},
+			}},
+		{"not bytes: extra inner entry",
+			`{"/":{"bytes":"","x":{}}}`,
+			[]Token{
+				{Type: TMapOpen, Length: -1},
+				{Type: TString, Str: "/"},
+				{Type: TMapOpen, Length: -1},
+				{Type: TString, Str: "bytes"},
+				{Type: TString, Str: ""},
+				{Type: TString, Str: "x"},
+				{Type: TMapOpen, Length: -1},
+				{Type: TMapClose},
+				{Type: TMapClose},
+				{Type: TMapClose},
+			}},
+		{"not bytes: extra outer entry",
+			`{"/":{"bytes":""},"x":1}`,
+			[]Token{
+				{Type: TMapOpen, Length: -1},
+				{Type: TString, Str: "/"},
+				{Type: TMapOpen, Length: -1},
+				{Type: TString, Str: "bytes"},
+				{Type: TString, Str: ""},
+				{Type: TMapClose},
+				{Type: TString, Str: "x"},
+				{Type: TInt, Int: 1},
+				{Type: TMapClose},
+			}},
+	}
+	for _, tr := range tt {
+		dec := NewDecoderWithOptions(DecodeOptions{Bytes: BytesMode_DagJSON}, strings.NewReader(tr.serial))
+		var got []Token
+		for {
+			var tok Token
+			done, err := dec.Step(&tok)
+			if err != nil {
+				t.Errorf("test %q errored: %s", tr.title, err)
+				break
+			}
+			got = append(got, tok)
+			if done {
+				break
+			}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tr.expect) {
+			t.Errorf("test %q:\n\texpected %v\n\t     got %v", tr.title, tr.expect, got)
+		}
+	}
+
+	Convey("Invalid base64 in the bytes form is an error", t, func() {
+		dec := NewDecoderWithOptions(DecodeOptions{Bytes: BytesMode_DagJSON}, strings.NewReader(`{"/":{"bytes":"!!"}}`))
+		var tok Token
+		_, err := dec.Step(&tok)
+		So(err, ShouldHaveSameTypeAs, ErrSyntax{})
+	})
+	Convey("Errors while reading ahead come in order", t, func() {
+		dec := NewDecoderWithOptions(DecodeOptions{Bytes: BytesMode_DagJSON}, strings.NewReader(`{"/":{"bytes"]`))
+		var tok Token
+		for _, expect := range []TokenType{TMapOpen, TString, TMapOpen} {
+			done, err := dec.Step(&tok)
+			So(err, ShouldBeNil)
+			So(done, ShouldBeFalse)
+			So(tok.Type, ShouldEqual, expect)
+		}
+		_, err := dec.Step(&tok)
+		So(err, ShouldHaveSameTypeAs, ErrSyntax{})
+	})
+}
+
+func TestJsonUnmarshalBytes(t *testing.T) {
+	tt := []struct {
+		mode   BytesMode
+		serial string
+		expect []byte
+	}{
+		{"", `"+/8B"`, []byte{0xfb, 0xff, 0x01}},
+		{BytesMode_Base64, `"+/8B"`, []byte{0xfb, 0xff, 0x01}},
+		{BytesMode_Base64, `"+/8="`, []byte{0xfb, 0xff}},
+		{BytesMode_Base64, `"+/8"`, []byte{0xfb, 0xff}},
+		{BytesMode_Base64URL, `"-_8"`, []byte{0xfb, 0xff}},
+		{BytesMode_Base64URL, `"-_8="`, []byte{0xfb, 0xff}},
+		{BytesMode_Hex, `"fbff01"`, []byte{0xfb, 0xff, 0x01}},
+		{BytesMode_DagJSON, `{"/":{"bytes":"+/8B"}}`, []byte{0xfb, 0xff, 0x01}},
+	}
+	for _, tr := range tt {
+		var slot []byte
+		err := UnmarshalWithOptions(DecodeOptions{Bytes: tr.mode}, []byte(tr.serial), &slot, atlas.MustBuild())
+		if err != nil {
+			t.Errorf("mode %q, %s: errored: %s", tr.mode, tr.serial, err)
+			continue
+		}
+		if !bytes.Equal(slot, tr.expect) {
+			t.Errorf("mode %q, %s: expected %x, got %x", tr.mode, tr.serial, tr.expect, slot)
+		}
+	}
+
+	Convey("Strings which aren't valid for the mode don't fit", t, func() {
+		var slot []byte
+		err := UnmarshalWithOptions(DecodeOptions{Bytes: BytesMode_Hex}, []byte(`"xyz"`), &slot, atlas.MustBuild())
+		So(err, ShouldNotBeNil)
+		err = UnmarshalWithOptions(DecodeOptions{Bytes: BytesMode_DagJSON}, []byte(`"+/8B"`), &slot, atlas.MustBuild())
+		So(err, ShouldNotBeNil)
+	})
+	Convey("Unknown modes are rejected before decoding", t, func() {
+		var slot []byte
+		err := UnmarshalWithOptions(DecodeOptions{Bytes: "base32"}, []byte(`"AE"`), &slot, atlas.MustBuild())
+		So(err, ShouldResemble, fmt.Errorf(`json: invalid bytes mode "base32"`))
+		So(func() { NewDecoderWithOptions(DecodeOptions{Bytes: "base32"}, strings.NewReader(`"AE"`)) }, ShouldPanic)
+	})
+	Convey("Strings still go into interface{} as strings", t, func() {
+		var slot interface{}
+		err := UnmarshalWithOptions(DecodeOptions{}, []byte(`"+/8B"`), &slot, atlas.MustBuild())
+		So(err, ShouldBeNil)
+		So(slot, ShouldEqual, "+/8B")
+	})
+}
+
+func TestJsonDecoderBigNumbers(t *testing.T) {
+	tt := []struct {
+		serial    string
+		mode      BigNumberMode
+		expect    Token
+		expectErr bool
+	}{
+		{"9223372036854775807", "", Token{Type: TInt, Int: 9223372036854775807}, false},
+		{"-9223372036854775808", "", Token{Type: TInt, Int: -9223372036854775808}, false},
+		{"9223372036854775808", "", Token{Type: TUint, Uint: 9223372036854775808}, false},
+		{"18446744073709551615", "", Token{Type: TUint, Uint: 18446744073709551615}, false},
+		{"18446744073709551616", "", Token{Type: TFloat64, Float64: 18446744073709551616}, false},
+		{"18446744073709551616", BigNumberMode_String, Token{Type: TString, Str: "18446744073709551616"}, false},
+		{"-9223372036854775809", BigNumberMode_String, Token{Type: TString, Str: "-9223372036854775809"}, false},
+		{"0.1", BigNumberMode_String, Token{Type: TFloat64, Float64: 0.1}, false},
+		{"1.5e300", BigNumberMode_String, Token{Type: TFloat64, Float64: 1.5e300}, false},
+		{"3.14159265358979323846", "", Token{Type: TFloat64, Float64: 3.141592653589793}, false},
+		{"3.14159265358979323846", BigNumberMode_String, Token{Type: TString, Str: "3.14159265358979323846"}, false},
+		{"1e400", "", Token{}, true},
+		{"1e400", BigNumberMode_String, Token{Type: TString, Str: "1e400"}, false},
+		{"1e-400", BigNumberMode_String, Token{Type: TString, Str: "1e-400"}, false},
+		{"0.000e-999999999999", BigNumberMode_String, Token{Type: TFloat64, Float64: 0}, false},
+	}
+	for _, tr := range tt {
+		var tok Token
+		_, err := NewDecoderWithOptions(DecodeOptions{BigNumbers: tr.mode}, strings.NewReader(tr.serial)).Step(&tok)
+		if (err != nil) != tr.expectErr {
+			t.Errorf("%s in mode %q: unexpected error state %v", tr.serial, tr.mode, err)
+			continue
+		}
+		if err == nil && tok.String() != tr.expect.String() {
+			t.Errorf("%s in mode %q: expected %s, got %s", tr.serial, tr.mode, tr.expect, tok)
+		}
+	}
+}
+
+func TestJsonUnmarshalBigNumbers(t *testing.T) {
+	Convey("Large integers fit in uint64, but not smaller types", t, func() {
+		var u uint64
+		So(Unmarshal([]byte(`18446744073709551615`), &u), ShouldBeNil)
+		So(u, ShouldEqual, uint64(18446744073709551615))
+		var i int64
+		So(Unmarshal([]byte(`9223372036854775808`), &i), ShouldHaveSameTypeAs, obj.ErrUnmarshalTypeCantFit{})
+		var i8 int8
+		So(Unmarshal([]byte(`128`), &i8), ShouldHaveSameTypeAs, obj.ErrUnmarshalTypeCantFit{})
+		var u8 uint8
+		So(Unmarshal([]byte(`256`), &u8), ShouldHaveSameTypeAs, obj.ErrUnmarshalTypeCantFit{})
+	})
+	Convey("Big numbers as strings land in big.Int", t, func() {
+		atl := atlas.MustBuild(commonatlases.BigInt_AsString)
+		var v []big.Int
+		err := UnmarshalWithOptions(DecodeOptions{BigNumbers: BigNumberMode_String},
+			[]byte(`[123456789012345678901234567890, -1]`), &v, atl)
+		So(err, ShouldBeNil)
+		So(v[0].String(), ShouldEqual, "123456789012345678901234567890")
+		So(v[1].String(), ShouldEqual, "-1")
+	})
+	Convey("Any number lands in a Number", t, func() {
+		var v []Number
+		err := UnmarshalWithOptions(DecodeOptions{BigNumbers: BigNumberMode_String},
+			[]byte(`[1, -2, 18446744073709551615, 0.5, 123456789012345678901234567890, 3.14159265358979323846]`), &v, defaultAtlas)
+		So(err, ShouldBeNil)
+		So(v, ShouldResemble, []Number{"1", "-2", "18446744073709551615", "0.5", "123456789012345678901234567890", "3.14159265358979323846"})
+		var bad []Number
+		So(Unmarshal([]byte(`["x"]`), &bad), ShouldNotBeNil)
+
+		Convey("And marshals back as a number, where a token can carry it", func() {
+			bs, err := Marshal(v[:4])
+			So(err, ShouldBeNil)
+			So(string(bs), ShouldEqual, `[1,-2,18446744073709551615,0.5]`)
+			_, err = Marshal(v)
+			So(err, ShouldResemble, fmt.Errorf("json: cannot marshal number 123456789012345678901234567890 without loss"))
+			_, err = Marshal(Number("3.14159265358979323846"))
+			So(err, ShouldResemble, fmt.Errorf("json: cannot marshal number 3.14159265358979323846 without loss"))
+			_, err = Marshal(Number("x"))
+			So(err, ShouldNotBeNil)
+			_, err = Marshal([]Number{"1", "x"})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
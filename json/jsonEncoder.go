@@ -1,20 +1,42 @@
 package json
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"math"
 	"strconv"
 
+	"github.com/polydawn/refmt/shared"
 	. "github.com/polydawn/refmt/tok"
 )
 
 func NewEncoder(wr io.Writer) *Encoder {
+	return NewEncoderWithOptions(EncodeOptions{}, wr)
+}
+
+func NewEncoderWithOptions(cfg EncodeOptions, wr io.Writer) *Encoder {
+	if err := checkEncodeOptions(cfg); err != nil {
+		panic(err)
+	}
 	return &Encoder{
 		wr:    wr,
+		cfg:   cfg,
 		stack: make([]phase, 0, 10),
 	}
 }
 
+// Returns an error if any of the modes in `cfg` is unknown.
+func checkEncodeOptions(cfg EncodeOptions) error {
+	if err := checkBytesMode(cfg.Bytes); err != nil {
+		return err
+	}
+	if err := checkUnrepresentableMode("undefined", cfg.Undefined); err != nil {
+		return err
+	}
+	return checkUnrepresentableMode("simple values", cfg.SimpleValues)
+}
+
 func (d *Encoder) Reset() {
 	d.stack = d.stack[0:0]
 	d.current = phase_anyExpectValue
@@ -25,7 +47,8 @@ func (d *Encoder) Reset() {
 	A json.Encoder is a TokenSink implementation that emits json bytes.
 */
 type Encoder struct {
-	wr io.Writer
+	wr  io.Writer
+	cfg EncodeOptions
 
 	// Stack, tracking how many array and map opens are outstanding.
 	// (Values are only 'phase_mapExpectKeyOrEnd' and 'phase_arrExpectValueOrEnd'.)
@@ -59,25 +82,25 @@ func (d *Encoder) Step(tok *Token) (done bool, err error) {
 			d.wr.Write(wordArrOpen)
 			return false, nil
 		case TMapClose:
-			return true, fmt.Errorf("unexpected mapClose; expected start of value")
+			return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "start of value"}
 		case TArrClose:
-			return true, fmt.Errorf("unexpected arrClose; expected start of value")
+			return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "start of value"}
 		default:
 			// It's a value; handle it.
-			d.flushValue(tok)
-			return true, nil
+			return true, d.flushValue(tok)
 		}
 	case phase_mapExpectKeyOrEnd:
 		switch tok.Type {
 		case TMapOpen:
-			return true, fmt.Errorf("unexpected mapOpen; expected start of key or end of map")
+			return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "map key or end of map"}
 		case TArrOpen:
-			return true, fmt.Errorf("unexpected arrOpen; expected start of key or end of map")
+			return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "map key or end of map"}
 		case TMapClose:
+			d.closeSep()
 			d.wr.Write(wordMapClose)
 			return d.popPhase()
 		case TArrClose:
-			return true, fmt.Errorf("unexpected arrClose; expected start of key or end of map")
+			return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "map key or end of map"}
 		default:
 			// It's a key.  It'd better be a string.
 			switch tok.Type {
@@ -85,10 +108,13 @@ func (d *Encoder) Step(tok *Token) (done bool, err error) {
 				d.entrySep()
 				d.emitString(tok.Str)
 				d.wr.Write(wordColon)
+				if d.cfg.SpaceAfterColon {
+					d.writeByte(' ')
+				}
 				d.current = phase_mapExpectValue
 				return false, nil
 			default:
-				return true, fmt.Errorf("unexpected token of type %T; expected map key", *tok)
+				return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "map key or end of map"}
 			}
 		}
 	case phase_mapExpectValue:
@@ -102,14 +128,13 @@ func (d *Encoder) Step(tok *Token) (done bool, err error) {
 			d.wr.Write(wordArrOpen)
 			return false, nil
 		case TMapClose:
-			return true, fmt.Errorf("unexpected mapClose; expected start of value")
+			return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "start of value"}
 		case TArrClose:
-			return true, fmt.Errorf("unexpected arrClose; expected start of value")
+			return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "start of value"}
 		default:
 			// It's a value; handle it.
-			d.flushValue(tok)
 			d.current = phase_mapExpectKeyOrEnd
-			return false, nil
+			return false, d.flushValue(tok)
 		}
 	case phase_arrExpectValueOrEnd:
 		switch tok.Type {
@@ -124,15 +149,15 @@ func (d *Encoder) Step(tok *Token) (done bool, err error) {
 			d.wr.Write(wordArrOpen)
 			return false, nil
 		case TMapClose:
-			return true, fmt.Errorf("unexpected mapClose; expected start of value or end of array")
+			return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "start of value or end of array"}
 		case TArrClose:
+			d.closeSep()
 			d.wr.Write(wordArrClose)
 			return d.popPhase()
 		default:
 			// It's a value; handle it.
 			d.entrySep()
-			d.flushValue(tok)
-			return false, nil
+			return false, d.flushValue(tok)
 		}
 	default:
 		panic("Unreachable")
@@ -162,14 +187,36 @@ func (d *Encoder) popPhase() (bool, error) {
 
 // Emit an entry separater (comma), unless we're at the start of an object.
 // Mark that we *do* have some content, regardless, so next time will need a sep.
+// If indenting, also start the new line for the entry.
 func (d *Encoder) entrySep() {
 	if d.some {
 		d.wr.Write(wordComma)
 	}
 	d.some = true
+	d.newline(len(d.stack))
+}
+
+// If indenting, start a new line for the end of a map or array,
+// unless it was empty.
+func (d *Encoder) closeSep() {
+	if d.some {
+		d.newline(len(d.stack) - 1)
+	}
+}
+
+// If indenting, start a new line, indented for the given depth.
+func (d *Encoder) newline(depth int) {
+	if d.cfg.Prefix == "" && d.cfg.Indent == "" {
+		return
+	}
+	d.writeByte('\n')
+	io.WriteString(d.wr, d.cfg.Prefix)
+	for i := 0; i < depth; i++ {
+		io.WriteString(d.wr, d.cfg.Indent)
+	}
 }
 
-func (d *Encoder) flushValue(tok *Token) {
+func (d *Encoder) flushValue(tok *Token) error {
 	switch tok.Type {
 	case TString:
 		d.emitString(tok.Str)
@@ -183,11 +230,75 @@ func (d *Encoder) flushValue(tok *Token) {
 	case TInt:
 		b := strconv.AppendInt(d.scratch[:0], tok.Int, 10)
 		d.wr.Write(b)
+	case TUint:
+		b := strconv.AppendUint(d.scratch[:0], tok.Uint, 10)
+		d.wr.Write(b)
+	case TFloat64:
+		return d.emitFloat(tok.Float64)
+	case TBytes:
+		d.emitBytes(tok.Bytes)
 	case TNull:
 		d.wr.Write(wordNull)
+	case TUndefined:
+		return d.flushUnrepresentable(d.cfg.Undefined, tok, "undefined")
+	case TSimple:
+		return d.flushUnrepresentable(d.cfg.SimpleValues, tok, "simple("+strconv.FormatUint(tok.Uint, 10)+")")
 	default:
 		panic(fmt.Errorf("TODO finish more jsonEncoder primitives support: unhandled token %s", tok))
 	}
+	return nil
+}
+
+// Emit a float, in the shortest form that reads back as the same float.
+// There's always a '.' or exponent, so it doesn't read back as an int.
+func (d *Encoder) emitFloat(f float64) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("json: cannot encode %v", f)
+	}
+	b := strconv.AppendFloat(d.scratch[:0], f, 'g', -1, 64)
+	if bytes.IndexAny(b, ".e") < 0 {
+		b = append(b, '.', '0')
+	}
+	d.wr.Write(b)
+	return nil
+}
+
+// Emit bytes in the configured representation.
+// The DAG-JSON form is always written compactly, as if it were a scalar.
+func (d *Encoder) emitBytes(b []byte) {
+	s := encodeBytes(d.cfg.Bytes, b)
+	if d.cfg.Bytes != BytesMode_DagJSON {
+		d.emitString(s)
+		return
+	}
+	d.wr.Write(wordDagBytesOpen)
+	d.emitString(s)
+	d.wr.Write(wordDagBytesClose)
+}
+
+// Emit a value that has no JSON representation, in the way configured for it.
+// `diag` is the CBOR diagnostic notation for the value.
+func (d *Encoder) flushUnrepresentable(mode UnrepresentableMode, tok *Token, diag string) error {
+	switch mode {
+	case UnrepresentableMode_Error:
+		return fmt.Errorf("json: cannot encode %s", tok)
+	case UnrepresentableMode_Diag:
+		d.emitString(diag)
+	default: // UnrepresentableMode_Null, or unset.
+		d.wr.Write(wordNull)
+	}
+	return nil
+}
+
+// Returns an error if `mode` isn't one of the UnrepresentableMode constants
+// (or unset).  `name` says which option it's for.
+func checkUnrepresentableMode(name string, mode UnrepresentableMode) error {
+	switch mode {
+	case "", UnrepresentableMode_Null, UnrepresentableMode_Error, UnrepresentableMode_Diag:
+		return nil
+	default:
+		return fmt.Errorf("json: invalid %s mode %q", name, mode)
+	}
 }
 
 func (d *Encoder) writeByte(b byte) {
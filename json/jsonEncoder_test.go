@@ -2,10 +2,14 @@ package json
 
 import (
 	"bytes"
+	"fmt"
 	"strings"
 	"testing"
 
+	"github.com/polydawn/refmt/obj/atlas"
 	. "github.com/polydawn/refmt/testutil"
+	. "github.com/polydawn/refmt/tok"
+	"github.com/polydawn/refmt/tok/fixtures"
 )
 
 func TestJsonEncoder(t *testing.T) {
@@ -47,3 +51,172 @@ func TestJsonEncoder(t *testing.T) {
 		t.Logf("test %q --- done", title)
 	}
 }
+
+func TestJsonEncoderUnrepresentable(t *testing.T) {
+	tt := []struct {
+		title     string
+		opts      EncodeOptions
+		sequence  fixtures.Sequence
+		serial    string
+		expectErr string
+	}{
+		{"undefined as null (default)",
+			EncodeOptions{},
+			fixtures.SequenceMap["undefined in map"],
+			`{"k":null}`,
+			""},
+		{"undefined as diag",
+			EncodeOptions{Undefined: UnrepresentableMode_Diag},
+			fixtures.SequenceMap["undefined in map"],
+			`{"k":"undefined"}`,
+			""},
+		{"undefined as error",
+			EncodeOptions{Undefined: UnrepresentableMode_Error},
+			fixtures.SequenceMap["undefined in map"],
+			`{"k":`,
+			"json: cannot encode <U>"},
+		{"simple values as null (default)",
+			EncodeOptions{},
+			fixtures.SequenceMap["simple values in array"],
+			`[null,null,null]`,
+			""},
+		{"simple values as diag",
+			EncodeOptions{SimpleValues: UnrepresentableMode_Diag},
+			fixtures.SequenceMap["simple values in array"],
+			`["simple(16)","simple(32)","simple(255)"]`,
+			""},
+		{"simple values as error",
+			EncodeOptions{SimpleValues: UnrepresentableMode_Error},
+			fixtures.SequenceMap["simple values in array"],
+			`[`,
+			"json: cannot encode <S:16>"},
+		{"map close where a value should be",
+			EncodeOptions{},
+			fixtures.Sequence{Title: "stray map close", Tokens: []Token{{Type: TArrOpen, Length: -1}, {Type: TMapClose}}},
+			`[`,
+			"malformed stream: invalid appearance of map close token; expected start of value or end of array"},
+		{"non-string map key",
+			EncodeOptions{},
+			fixtures.Sequence{Title: "int key", Tokens: []Token{{Type: TMapOpen, Length: -1}, {Type: TInt, Int: 1}}},
+			`{`,
+			"malformed stream: invalid appearance of int token; expected map key or end of map"},
+	}
+	for _, tr := range tt {
+		buf := &bytes.Buffer{}
+		tokenSink := NewEncoderWithOptions(tr.opts, buf)
+		var err error
+		for _, tok := range tr.sequence.Tokens {
+			if _, err = tokenSink.Step(&tok); err != nil {
+				break
+			}
+		}
+		errStr := ""
+		if err != nil {
+			errStr = err.Error()
+		}
+		if errStr != tr.expectErr {
+			t.Errorf("test %q: expected error %q, got %q", tr.title, tr.expectErr, errStr)
+		}
+		Assert(t, tr.title, tr.serial, buf.String())
+	}
+
+	// Unknown modes are rejected up front, not in the middle of encoding.
+	for _, tr := range []struct {
+		opts      EncodeOptions
+		expectErr string
+	}{
+		{EncodeOptions{Undefined: "omit"}, `json: invalid undefined mode "omit"`},
+		{EncodeOptions{SimpleValues: "omit"}, `json: invalid simple values mode "omit"`},
+	} {
+		_, err := MarshalWithOptions(tr.opts, []int{1}, atlas.MustBuild())
+		if err == nil || err.Error() != tr.expectErr {
+			t.Errorf("expected error %q, got %v", tr.expectErr, err)
+		}
+		func() {
+			defer func() {
+				if r := recover(); r == nil || fmt.Sprint(r) != tr.expectErr {
+					t.Errorf("expected panic %q, got %v", tr.expectErr, r)
+				}
+			}()
+			NewEncoderWithOptions(tr.opts, &bytes.Buffer{})
+		}()
+	}
+}
+
+func TestJsonEncoderFormatting(t *testing.T) {
+	tt := []struct {
+		title    string
+		opts     EncodeOptions
+		sequence fixtures.Sequence
+		serial   string
+	}{
+		{"compact (default)",
+			EncodeOptions{},
+			fixtures.SequenceMap["array nested in map as first and non-final entry"],
+			`{"ke":["oh","whee","wow"],"k1":"v1"}`},
+		{"space after colon only",
+			EncodeOptions{SpaceAfterColon: true},
+			fixtures.SequenceMap["array nested in map as first and non-final entry"],
+			`{"ke": ["oh","whee","wow"],"k1": "v1"}`},
+		{"indented",
+			EncodeOptions{Indent: "\t", SpaceAfterColon: true},
+			fixtures.SequenceMap["array nested in map as first and non-final entry"],
+			"{\n\t\"ke\": [\n\t\t\"oh\",\n\t\t\"whee\",\n\t\t\"wow\"\n\t],\n\t\"k1\": \"v1\"\n}"},
+		{"indented with prefix",
+			EncodeOptions{Prefix: "// ", Indent: "  "},
+			fixtures.SequenceMap["maps nested in array"],
+			"[\n//   {\n//     \"k\":\"v\"\n//   },\n//   \"whee\",\n//   {\n//     \"k1\":\"v1\"\n//   }\n// ]"},
+		{"indented empties",
+			EncodeOptions{Indent: "  "},
+			fixtures.SequenceMap["arrays in arrays in arrays"],
+			"[\n  [\n    []\n  ]\n]"},
+		{"indented scalar",
+			EncodeOptions{Indent: "  "},
+			fixtures.SequenceMap["flat string"],
+			`"value"`},
+	}
+	for _, tr := range tt {
+		buf := &bytes.Buffer{}
+		tokenSink := NewEncoderWithOptions(tr.opts, buf)
+		for n, tok := range tr.sequence.Tokens {
+			if _, err := tokenSink.Step(&tok); err != nil {
+				t.Errorf("test %q step %d errored: %s", tr.title, n, err)
+			}
+		}
+		Assert(t, tr.title, tr.serial, buf.String())
+	}
+}
+
+func TestJsonEncoderBytes(t *testing.T) {
+	seq := []Token{
+		{Type: TArrOpen, Length: 2},
+		{Type: TBytes, Bytes: []byte{0xfb, 0xff, 0x01}},
+		{Type: TBytes, Bytes: []byte{}},
+		{Type: TArrClose},
+	}
+	tt := []struct {
+		mode   BytesMode
+		serial string
+	}{
+		{"", `["+/8B",""]`},
+		{BytesMode_Base64, `["+/8B",""]`},
+		{BytesMode_Base64URL, `["-_8B",""]`},
+		{BytesMode_Hex, `["fbff01",""]`},
+		{BytesMode_DagJSON, `[{"/":{"bytes":"+/8B"}},{"/":{"bytes":""}}]`},
+	}
+	for _, tr := range tt {
+		buf := &bytes.Buffer{}
+		tokenSink := NewEncoderWithOptions(EncodeOptions{Bytes: tr.mode}, buf)
+		for n, tok := range seq {
+			if _, err := tokenSink.Step(&tok); err != nil {
+				t.Errorf("mode %q step %d errored: %s", tr.mode, n, err)
+			}
+		}
+		Assert(t, string(tr.mode), tr.serial, buf.String())
+	}
+
+	_, err := MarshalWithOptions(EncodeOptions{Bytes: "base32"}, []byte{0x01}, atlas.MustBuild())
+	if err == nil || err.Error() != `json: invalid bytes mode "base32"` {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -95,10 +95,10 @@ var jsonFixtures = []struct {
 		nil,
 	},
 	{"decoding with trailing comma",
-		fixtures.SequenceMap["duo row map"].SansLengthInfo(),
+		fixtures.Sequence{Title: "duo row map", Tokens: fixtures.SequenceMap["duo row map"].SansLengthInfo().Tokens[:5]}.Append(Token{}),
 		`{"key":"value","k2":"v2",}`,
 		inapplicable,
-		nil,
+		ErrSyntax{25, "unexpected '}' after ','"},
 	},
 	{"",
 		fixtures.SequenceMap["duo row map alt2"].SansLengthInfo(),
@@ -181,7 +181,7 @@ var jsonFixtures = []struct {
 		fixtures.SequenceMap["dangling arr open"].SansLengthInfo().Append(Token{}),
 		`[`,
 		inapplicable,
-		io.EOF, // REVIEW it's probably more explicitly unexpected than that...
+		io.ErrUnexpectedEOF,
 	},
 
 	// Numeric.
@@ -218,7 +218,25 @@ var jsonFixtures = []struct {
 	{"",
 		fixtures.Sequence{"float 1 e+100", []Token{{Type: TFloat64, Float64: 1.0e+300}}},
 		`1e+300`,
-		inapplicable, // TODO should support situationEncoding too
+		nil,
+		nil,
+	},
+	{"",
+		fixtures.Sequence{Title: "float 1.5", Tokens: []Token{{Type: TFloat64, Float64: 1.5}}},
+		`1.5`,
+		nil,
+		nil,
+	},
+	{"",
+		fixtures.Sequence{Title: "float integral", Tokens: []Token{{Type: TFloat64, Float64: 2}}},
+		`2.0`,
+		nil,
+		nil,
+	},
+	{"",
+		fixtures.Sequence{Title: "uint above int64", Tokens: []Token{{Type: TUint, Uint: 1 << 63}}},
+		`9223372036854775808`,
+		nil,
 		nil,
 	},
 }
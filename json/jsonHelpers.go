@@ -25,6 +25,11 @@ import (
 //
 // Most methods also have an "Atlased" variant,
 // which lets you specify advanced type mapping instructions.
+//
+// The variants without an atlas use `defaultAtlas`, so that `Number`
+// values are handled as numbers.
+
+var defaultAtlas = atlas.MustBuild(NumberAtlasEntry)
 
 func Marshal(v interface{}) ([]byte, error) {
 	var buf bytes.Buffer
@@ -42,6 +47,17 @@ func MarshalAtlased(v interface{}, atl atlas.Atlas) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+func MarshalWithOptions(opts EncodeOptions, v interface{}, atl atlas.Atlas) ([]byte, error) {
+	if err := checkEncodeOptions(opts); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := NewMarshallerWithOptions(opts, &buf, atl).Marshal(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 type Marshaller struct {
 	marshaller *obj.Marshaller
 	encoder    *Encoder
@@ -55,13 +71,17 @@ func (x *Marshaller) Marshal(v interface{}) error {
 }
 
 func NewMarshaller(wr io.Writer) *Marshaller {
-	return NewMarshallerAtlased(wr, atlas.MustBuild())
+	return NewMarshallerAtlased(wr, defaultAtlas)
 }
 
 func NewMarshallerAtlased(wr io.Writer, atl atlas.Atlas) *Marshaller {
+	return NewMarshallerWithOptions(EncodeOptions{}, wr, atl)
+}
+
+func NewMarshallerWithOptions(opts EncodeOptions, wr io.Writer, atl atlas.Atlas) *Marshaller {
 	x := &Marshaller{
 		marshaller: obj.NewMarshaller(atl),
-		encoder:    NewEncoder(wr),
+		encoder:    NewEncoderWithOptions(opts, wr),
 	}
 	x.pump = shared.TokenPump{
 		x.marshaller,
@@ -71,11 +91,25 @@ func NewMarshallerAtlased(wr io.Writer, atl atlas.Atlas) *Marshaller {
 }
 
 func Unmarshal(data []byte, v interface{}) error {
-	return NewUnmarshaller(bytes.NewBuffer(data)).Unmarshal(v)
+	return UnmarshalWithOptions(DecodeOptions{}, data, v, defaultAtlas)
 }
 
 func UnmarshalAtlased(data []byte, v interface{}, atl atlas.Atlas) error {
-	return NewUnmarshallerAtlased(bytes.NewBuffer(data), atl).Unmarshal(v)
+	return UnmarshalWithOptions(DecodeOptions{}, data, v, atl)
+}
+
+// UnmarshalWithOptions unmarshals the one value in `data` into `v`.
+// Anything after that value but whitespace is an error.
+// (To read several values from a stream, use an `Unmarshaller`.)
+func UnmarshalWithOptions(opts DecodeOptions, data []byte, v interface{}, atl atlas.Atlas) error {
+	if err := checkBytesMode(opts.Bytes); err != nil {
+		return err
+	}
+	x := NewUnmarshallerWithOptions(opts, bytes.NewBuffer(data), atl)
+	if err := x.Unmarshal(v); err != nil {
+		return err
+	}
+	return x.decoder.CheckTrailing()
 }
 
 type Unmarshaller struct {
@@ -91,13 +125,18 @@ func (x *Unmarshaller) Unmarshal(v interface{}) error {
 }
 
 func NewUnmarshaller(r io.Reader) *Unmarshaller {
-	return NewUnmarshallerAtlased(r, atlas.MustBuild())
+	return NewUnmarshallerAtlased(r, defaultAtlas)
 }
 func NewUnmarshallerAtlased(r io.Reader, atl atlas.Atlas) *Unmarshaller {
+	return NewUnmarshallerWithOptions(DecodeOptions{}, r, atl)
+}
+func NewUnmarshallerWithOptions(opts DecodeOptions, r io.Reader, atl atlas.Atlas) *Unmarshaller {
 	x := &Unmarshaller{
 		unmarshaller: obj.NewUnmarshaller(atl),
-		decoder:      NewDecoder(r),
+		decoder:      NewDecoderWithOptions(opts, r),
 	}
+	x.unmarshaller.SetAllocBudget(opts.MaxAllocBudget)
+	x.unmarshaller.SetBytesFromString(bytesDecoder(opts.Bytes))
 	x.pump = shared.TokenPump{
 		x.decoder,
 		x.unmarshaller,
@@ -0,0 +1,169 @@
+package json
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/polydawn/refmt/obj"
+	"github.com/polydawn/refmt/obj/atlas"
+	"github.com/polydawn/refmt/shared"
+)
+
+// The methods in this file handle newline-delimited JSON
+// (a.k.a. NDJSON, or JSON Lines): a stream of records, each of which is
+// one JSON value, alone on one line.
+//
+// Each line is decoded on its own, so a malformed line doesn't stop the
+// records after it from being read: `LineUnmarshaller.Unmarshal` returns
+// an `ErrLine` for it, and the next call moves on to the next line.
+
+// Error returned by `LineUnmarshaller` when a line couldn't be unmarshalled.
+// Unmarshalling may continue with the next line.
+type ErrLine struct {
+	Line int   // Line number, counting from 1.
+	Err  error // What went wrong; usually an `ErrSyntax`, or an error from the `obj.Unmarshaller`.
+}
+
+func (e ErrLine) Error() string {
+	return fmt.Sprintf("json: line %d: %s", e.Line, e.Err)
+}
+
+type LineMarshaller struct {
+	marshaller *Marshaller
+	buf        bytes.Buffer
+	wr         io.Writer
+}
+
+// Marshal `v` as a record: compact JSON, followed by a newline.
+// The record is written in one call to the writer, and only if it was
+// marshalled without error.
+func (x *LineMarshaller) Marshal(v interface{}) error {
+	x.buf.Reset()
+	if err := x.marshaller.Marshal(v); err != nil {
+		return err
+	}
+	x.buf.WriteByte('\n')
+	_, err := x.wr.Write(x.buf.Bytes())
+	return err
+}
+
+func NewLineMarshaller(wr io.Writer) *LineMarshaller {
+	return NewLineMarshallerAtlased(wr, defaultAtlas)
+}
+
+func NewLineMarshallerAtlased(wr io.Writer, atl atlas.Atlas) *LineMarshaller {
+	return NewLineMarshallerWithOptions(EncodeOptions{}, wr, atl)
+}
+
+// As NewMarshallerWithOptions, except that the formatting options
+// (Prefix, Indent) are ignored: each record must be one line.
+func NewLineMarshallerWithOptions(opts EncodeOptions, wr io.Writer, atl atlas.Atlas) *LineMarshaller {
+	opts.Prefix, opts.Indent = "", ""
+	x := &LineMarshaller{wr: wr}
+	x.marshaller = NewMarshallerWithOptions(opts, &x.buf, atl)
+	return x
+}
+
+type LineUnmarshaller struct {
+	unmarshaller *obj.Unmarshaller
+	decoder      *Decoder
+	pump         shared.TokenPump
+	r            *bufio.Reader
+	cfg          DecodeOptions
+	line         bytes.Buffer // The current line.
+	lineNum      int          // Number of the current line.
+}
+
+// Unmarshal the next record into `v`.
+//
+// Blank lines are skipped.  (So are lines holding only comments,
+// if the decoder is configured to accept them.)
+// If a line can't be unmarshalled, an `ErrLine` is returned, and `v`
+// may have been partially filled; the next call carries on from the next line.
+// At the end of the input, `io.EOF` is returned.
+//
+// If `DecodeOptions.MaxTotalBytes` is set, it limits the length of lines,
+// so that a line too long is rejected without reading it all into memory.
+func (x *LineUnmarshaller) Unmarshal(v interface{}) error {
+	for {
+		n, err := x.readLine()
+		if err != nil {
+			return err
+		}
+		x.lineNum++
+		if err := shared.CheckLimit("MaxTotalBytes", x.cfg.MaxTotalBytes, n); err != nil {
+			return ErrLine{x.lineNum, err}
+		}
+		x.unmarshaller.Bind(v)
+		x.decoder.Reset()
+		err = x.pump.Run()
+		if err == nil {
+			err = x.decoder.CheckTrailing()
+		}
+		switch err {
+		case nil:
+			return nil
+		case io.EOF: // Nothing on this line.
+			continue
+		default:
+			return ErrLine{x.lineNum, err}
+		}
+	}
+}
+
+// Returns the line number of the record last unmarshalled (or attempted),
+// counting from 1.
+func (x *LineUnmarshaller) Line() int {
+	return x.lineNum
+}
+
+// Read the next line into `x.line`, and return its length.
+// If it's over MaxTotalBytes, only the beginning is kept, and the rest discarded.
+// Returns io.EOF only if there's nothing left at all.
+func (x *LineUnmarshaller) readLine() (n int64, err error) {
+	x.line.Reset()
+	for {
+		chunk, err := x.r.ReadSlice('\n')
+		n += int64(len(chunk))
+		if x.cfg.MaxTotalBytes <= 0 || n <= x.cfg.MaxTotalBytes {
+			x.line.Write(chunk)
+		}
+		switch err {
+		case bufio.ErrBufferFull:
+			continue
+		case io.EOF:
+			if n > 0 {
+				return n, nil
+			}
+			return 0, io.EOF
+		default:
+			return n, err
+		}
+	}
+}
+
+func NewLineUnmarshaller(r io.Reader) *LineUnmarshaller {
+	return NewLineUnmarshallerAtlased(r, defaultAtlas)
+}
+
+func NewLineUnmarshallerAtlased(r io.Reader, atl atlas.Atlas) *LineUnmarshaller {
+	return NewLineUnmarshallerWithOptions(DecodeOptions{}, r, atl)
+}
+
+func NewLineUnmarshallerWithOptions(opts DecodeOptions, r io.Reader, atl atlas.Atlas) *LineUnmarshaller {
+	x := &LineUnmarshaller{
+		unmarshaller: obj.NewUnmarshaller(atl),
+		r:            bufio.NewReader(r),
+		cfg:          opts,
+	}
+	x.decoder = newDecoder(opts, shared.NewBytesReader(&x.line))
+	x.unmarshaller.SetAllocBudget(opts.MaxAllocBudget)
+	x.unmarshaller.SetBytesFromString(bytesDecoder(opts.Bytes))
+	x.pump = shared.TokenPump{
+		TokenSource: x.decoder,
+		TokenSink:   x.unmarshaller,
+	}
+	return x
+}
@@ -0,0 +1,92 @@
+package json
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/polydawn/refmt/obj/atlas"
+	"github.com/polydawn/refmt/shared"
+)
+
+type jsonLinesRecord struct {
+	Level string
+	N     int
+}
+
+var jsonLinesAtlas = atlas.MustBuild(
+	atlas.BuildEntry(jsonLinesRecord{}).StructMap().Autogenerate().Complete(),
+	NumberAtlasEntry,
+)
+
+func TestJsonLines(t *testing.T) {
+	Convey("Marshalling writes one compact record per line", t, func() {
+		var buf bytes.Buffer
+		m := NewLineMarshallerWithOptions(EncodeOptions{Indent: "\t", SpaceAfterColon: true}, &buf, jsonLinesAtlas)
+		So(m.Marshal(jsonLinesRecord{"info", 1}), ShouldBeNil)
+		So(m.Marshal(map[string]interface{}{"a": []int{1, 2}}), ShouldBeNil)
+		So(m.Marshal([]interface{}{1, Number("bogus")}), ShouldNotBeNil)
+		So(m.Marshal("last"), ShouldBeNil)
+		So(buf.String(), ShouldEqual, `{"level": "info","n": 1}`+"\n"+`{"a": [1,2]}`+"\n"+`"last"`+"\n")
+	})
+
+	Convey("Unmarshalling reads each line, and carries on past bad ones", t, func() {
+		serial := strings.Join([]string{
+			`{"level":"info","n":1}`,
+			``,
+			`{"level":"warn","n":`,
+			"  \t\r",
+			`{"level":"error","n":"three"}`,
+			`{"level":"info","n":4} {"level":"info","n":5}`,
+			`{"level":"debug","n":6}` + "\r",
+			`{"level":"info","n":7}`,
+		}, "\n")
+		u := NewLineUnmarshallerAtlased(strings.NewReader(serial), jsonLinesAtlas)
+		var rec jsonLinesRecord
+
+		So(u.Unmarshal(&rec), ShouldBeNil)
+		So(rec, ShouldResemble, jsonLinesRecord{"info", 1})
+		So(u.Line(), ShouldEqual, 1)
+
+		So(u.Unmarshal(&rec), ShouldResemble, ErrLine{3, io.ErrUnexpectedEOF})
+
+		err := u.Unmarshal(&jsonLinesRecord{})
+		So(err, ShouldHaveSameTypeAs, ErrLine{})
+		So(err.(ErrLine).Line, ShouldEqual, 5)
+
+		So(u.Unmarshal(&jsonLinesRecord{}), ShouldResemble, ErrLine{6, ErrSyntax{23, `unexpected '{' after top-level value`}})
+
+		So(u.Unmarshal(&rec), ShouldBeNil)
+		So(rec, ShouldResemble, jsonLinesRecord{"debug", 6})
+
+		So(u.Unmarshal(&rec), ShouldBeNil)
+		So(rec, ShouldResemble, jsonLinesRecord{"info", 7})
+		So(u.Line(), ShouldEqual, 8)
+
+		So(u.Unmarshal(&rec), ShouldEqual, io.EOF)
+	})
+
+	Convey("Lines over MaxTotalBytes are rejected, and skipped", t, func() {
+		serial := `"short"` + "\n" + `"` + strings.Repeat("x", 10000) + `"` + "\n" + `"after"` + "\n"
+		u := NewLineUnmarshallerWithOptions(DecodeOptions{MaxTotalBytes: 100}, strings.NewReader(serial), atlas.MustBuild())
+		var s string
+		So(u.Unmarshal(&s), ShouldBeNil)
+		So(s, ShouldEqual, "short")
+		So(u.Unmarshal(&s), ShouldResemble, ErrLine{2, shared.ErrLimitExceeded{Limit: "MaxTotalBytes", Max: 100, Got: 10003}})
+		So(u.Unmarshal(&s), ShouldBeNil)
+		So(s, ShouldEqual, "after")
+		So(u.Unmarshal(&s), ShouldEqual, io.EOF)
+	})
+
+	Convey("Comment-only lines are skipped in JSONC mode", t, func() {
+		u := NewLineUnmarshallerWithOptions(DecodeOptions{Syntax: SyntaxMode_JSONC}, strings.NewReader("// header\n1 // one\n"), atlas.MustBuild())
+		var n int
+		So(u.Unmarshal(&n), ShouldBeNil)
+		So(n, ShouldEqual, 1)
+		So(u.Line(), ShouldEqual, 2)
+		So(u.Unmarshal(&n), ShouldEqual, io.EOF)
+	})
+}
@@ -0,0 +1,80 @@
+package json
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/polydawn/refmt/obj/atlas"
+)
+
+/*
+	Number holds the literal text of a JSON number, so that numbers of any
+	size and precision can be unmarshalled without loss.
+
+	To use it, `NumberAtlasEntry` must be in the atlas (the helpers in this
+	package which don't take an atlas include it already).  Any number
+	unmarshals into a Number, as does a string which is a valid JSON number;
+	with `DecodeOptions.BigNumbers` set to `BigNumberMode_String`, big numbers
+	arrive as such strings, and so are kept exactly.  (They can't be
+	marshalled back, though; see `NumberAtlasEntry`.)
+*/
+type Number string
+
+// String returns the literal text of the number.
+func (n Number) String() string { return string(n) }
+
+// Int64 returns the number as an int64, or an error if it isn't one.
+func (n Number) Int64() (int64, error) { return strconv.ParseInt(string(n), 10, 64) }
+
+// Uint64 returns the number as a uint64, or an error if it isn't one.
+func (n Number) Uint64() (uint64, error) { return strconv.ParseUint(string(n), 10, 64) }
+
+// Float64 returns the number as a float64, rounding if necessary.
+func (n Number) Float64() (float64, error) { return strconv.ParseFloat(string(n), 64) }
+
+/*
+	NumberAtlasEntry maps `Number` to a number.
+
+	When marshalling, a Number is emitted as an int, uint, or float token,
+	whichever carries it exactly.  If none can, marshalling it is an error:
+	a token can't carry a number of arbitrary precision, and emitting
+	it as a string instead would quietly change the type of the value.
+	It's an error, too, to marshal a Number which isn't a valid JSON number.
+*/
+var NumberAtlasEntry = atlas.BuildEntry(Number("")).Transform().
+	TransformMarshal(atlas.MakeMarshalTransformFunc(
+		func(n Number) (interface{}, error) {
+			s := string(n)
+			if !isNumber(s) {
+				return nil, fmt.Errorf("json: invalid number %q", s)
+			}
+			if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return i, nil
+			}
+			if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+				return u, nil
+			}
+			if f, err := strconv.ParseFloat(s, 64); err == nil && floatIsExact(s, f) {
+				return f, nil
+			}
+			return nil, fmt.Errorf("json: cannot marshal number %s without loss", s)
+		})).
+	TransformUnmarshal(atlas.MakeUnmarshalTransformFunc(
+		func(x interface{}) (Number, error) {
+			switch x2 := x.(type) {
+			case int64:
+				return Number(strconv.FormatInt(x2, 10)), nil
+			case uint64:
+				return Number(strconv.FormatUint(x2, 10)), nil
+			case float64:
+				return Number(strconv.FormatFloat(x2, 'g', -1, 64)), nil
+			case string:
+				if !isNumber(x2) {
+					return "", fmt.Errorf("json: cannot unmarshal %q as a number", x2)
+				}
+				return Number(x2), nil
+			default:
+				return "", fmt.Errorf("json: cannot unmarshal %T as a number", x)
+			}
+		})).
+	Complete()
@@ -1,17 +1,124 @@
 package json
 
 type EncodeOptions struct {
-	// future: indentation opts and such
+	// Formatting.  The zero value gives the most compact output:
+	// no whitespace at all.
+	//
+	// If either Prefix or Indent is set, each entry of a map or array starts
+	// on a new line, beginning with Prefix and then one Indent per level
+	// of nesting.  (The first line doesn't get the Prefix; it's wherever the
+	// writer was.)  Empty maps and arrays are still written as `{}` and `[]`.
+	// SpaceAfterColon puts a space between map keys and their values,
+	// which you'll usually want along with indentation.
+	//
+	// The output for any given token stream is always the same.
+	Prefix          string
+	Indent          string
+	SpaceAfterColon bool
+
+	// How to encode bytes, which JSON has no type for.
+	// The default is a string of standard base64.
+	// Any other mode is invalid: `NewEncoderWithOptions` panics on it,
+	// and `MarshalWithOptions` returns an error.
+	Bytes BytesMode
+
+	// How to encode CBOR's "undefined", and its other "simple values",
+	// which have no JSON equivalent.  The default is to emit null.
+	// As with Bytes, an unknown mode makes `NewEncoderWithOptions` panic,
+	// and `MarshalWithOptions` return an error.
+	Undefined    UnrepresentableMode
+	SimpleValues UnrepresentableMode
 }
 
+// A type to enumerate ways to handle values JSON has no way to represent.
+type UnrepresentableMode string
+
+const (
+	UnrepresentableMode_Null  = "null"  // Emit null.  The default.
+	UnrepresentableMode_Error = "error" // Halt encoding with an error.
+	UnrepresentableMode_Diag  = "diag"  // Emit a string of the CBOR diagnostic notation, e.g. "undefined" or "simple(16)".
+)
+
+// A type to enumerate ways to represent bytes in JSON.
+type BytesMode string
+
+const (
+	BytesMode_Base64    = "base64"    // A string of standard base64 (RFC 4648 section 4), with padding.  The default.
+	BytesMode_Base64URL = "base64url" // A string of URL-safe base64 (RFC 4648 section 5), without padding.
+	BytesMode_Hex       = "hex"       // A string of lowercase hex.
+	BytesMode_DagJSON   = "dag-json"  // A map of the form `{"/":{"bytes":"..."}}`, holding standard base64 without padding, as in the DAG-JSON spec.
+)
+
 // marker method -- you may use this type to instruct `refmt.Marshal`
 // what kind of encoder to use.
 func (EncodeOptions) IsEncodeOptions() {}
 
 type DecodeOptions struct {
 	// future: options to validate canonical serial order
+
+	// Which grammar to accept.  The default is strict RFC 8259 JSON.
+	//
+	// The lenient grammars are for documents humans edit by hand, such as
+	// config files.  Whatever the grammar, the decoder yields the same
+	// token stream it would for the equivalent strict JSON, so anything
+	// that can consume JSON tokens can consume these too.
+	Syntax SyntaxMode
+
+	// How to handle numbers which can't be carried without loss.
+	//
+	// Integers are always yielded as ints if they fit in an int64, and
+	// as uints if they fit in a uint64.  Anything else -- bigger integers,
+	// and decimals with more precision or range than a float64 -- is
+	// rounded to a float by default.  With `BigNumberMode_String`, such
+	// numbers are instead yielded as a string of the literal text, which
+	// can be unmarshalled into a `big.Int` (see `obj/atlas/common`),
+	// a `json.Number`, or a string.
+	BigNumbers BigNumberMode
+
+	// How bytes were encoded; see `EncodeOptions.Bytes`.
+	//
+	// With the string forms, the decoder can't tell bytes from any other
+	// string, so it yields string tokens as usual; the unmarshal helpers in
+	// this package then decode those strings when the target is a `[]byte`.
+	// (Padding is optional when decoding either flavor of base64.)
+	// With `BytesMode_DagJSON`, the decoder recognizes the `{"/":{"bytes":"..."}}`
+	// form wherever it appears, and yields a bytes token for it; other strings
+	// are not accepted for `[]byte` targets.
+	// As when encoding, an unknown mode makes `NewDecoderWithOptions` panic,
+	// and `UnmarshalWithOptions` return an error.
+	Bytes BytesMode
+
+	// Resource limits, for use when decoding untrusted input.
+	// Zero means unlimited.  Exceeding any limit halts decoding with a
+	// `shared.ErrLimitExceeded` error.
+
+	MaxDepth           int   // Maximum nesting depth of maps and arrays.
+	MaxStringLength    int   // Maximum length in bytes of any single string (after unescaping).
+	MaxContainerLength int   // Maximum number of entries in any single map or array.
+	MaxTotalBytes      int64 // Maximum number of bytes to consume from the reader (per value; counted from `Reset`).
+
+	// Allocation budget for the `obj.Unmarshaller` when using the
+	// unmarshal helpers in this package.  See `obj.Unmarshaller.SetAllocBudget`.
+	MaxAllocBudget int64
 }
 
+// A type to enumerate the grammars the decoder can accept.
+type SyntaxMode string
+
+const (
+	SyntaxMode_Strict = "strict" // RFC 8259 JSON, and nothing else.  The default.
+	SyntaxMode_JSONC  = "jsonc"  // JSON with `//` and `/* */` comments, and trailing commas in maps and arrays.
+	SyntaxMode_JSON5  = "json5"  // JSON5 (https://spec.json5.org/): JSONC, plus single-quoted strings, identifier map keys, hex numbers, Infinity and NaN, and more.
+)
+
+// A type to enumerate ways to handle numbers too big or precise for a float64.
+type BigNumberMode string
+
+const (
+	BigNumberMode_Float  = "float"  // Round to the nearest float64.  The default.
+	BigNumberMode_String = "string" // Yield a string of the literal text.
+)
+
 // marker method -- you may use this type to instruct `refmt.Marshal`
 // what kind of encoder to use.
 func (DecodeOptions) IsDecodeOptions() {}
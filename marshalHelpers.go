@@ -4,6 +4,9 @@ import (
 	"io"
 
 	"github.com/polydawn/refmt/cbor"
+	"github.com/polydawn/refmt/cid"
+	"github.com/polydawn/refmt/dagcbor"
+	"github.com/polydawn/refmt/dagjson"
 	"github.com/polydawn/refmt/json"
 	"github.com/polydawn/refmt/obj/atlas"
 )
@@ -13,22 +16,30 @@ type EncodeOptions interface {
 }
 
 func Marshal(opts EncodeOptions, v interface{}) ([]byte, error) {
-	switch opts.(type) {
+	switch o := opts.(type) {
 	case json.EncodeOptions:
-		return json.Marshal(v)
+		return json.MarshalWithOptions(o, v, atlas.MustBuild(json.NumberAtlasEntry))
 	case cbor.EncodeOptions:
-		return cbor.Marshal(v)
+		return cbor.MarshalWithOptions(o, v, atlas.MustBuild())
+	case dagcbor.EncodeOptions:
+		return dagcbor.MarshalWithOptions(o, v, atlas.MustBuild(cid.AtlasEntry))
+	case dagjson.EncodeOptions:
+		return dagjson.MarshalWithOptions(o, v, atlas.MustBuild(cid.AtlasEntry))
 	default:
 		panic("incorrect usage: unknown EncodeOptions type")
 	}
 }
 
 func MarshalAtlased(opts EncodeOptions, v interface{}, atl atlas.Atlas) ([]byte, error) {
-	switch opts.(type) {
+	switch o := opts.(type) {
 	case json.EncodeOptions:
-		return json.MarshalAtlased(v, atl)
+		return json.MarshalWithOptions(o, v, atl)
 	case cbor.EncodeOptions:
-		return cbor.MarshalAtlased(v, atl)
+		return cbor.MarshalWithOptions(o, v, atl)
+	case dagcbor.EncodeOptions:
+		return dagcbor.MarshalWithOptions(o, v, atl)
+	case dagjson.EncodeOptions:
+		return dagjson.MarshalWithOptions(o, v, atl)
 	default:
 		panic("incorrect usage: unknown EncodeOptions type")
 	}
@@ -39,22 +50,30 @@ type Marshaller interface {
 }
 
 func NewMarshaller(opts EncodeOptions, wr io.Writer) Marshaller {
-	switch opts.(type) {
+	switch o := opts.(type) {
 	case json.EncodeOptions:
-		return json.NewMarshaller(wr)
+		return json.NewMarshallerWithOptions(o, wr, atlas.MustBuild(json.NumberAtlasEntry))
 	case cbor.EncodeOptions:
-		return cbor.NewMarshaller(wr)
+		return cbor.NewMarshallerWithOptions(o, wr, atlas.MustBuild())
+	case dagcbor.EncodeOptions:
+		return dagcbor.NewMarshallerWithOptions(o, wr, atlas.MustBuild(cid.AtlasEntry))
+	case dagjson.EncodeOptions:
+		return dagjson.NewMarshallerWithOptions(o, wr, atlas.MustBuild(cid.AtlasEntry))
 	default:
 		panic("incorrect usage: unknown EncodeOptions type")
 	}
 }
 
 func NewMarshallerAtlased(opts EncodeOptions, wr io.Writer, atl atlas.Atlas) Marshaller {
-	switch opts.(type) {
+	switch o := opts.(type) {
 	case json.EncodeOptions:
-		return json.NewMarshallerAtlased(wr, atl)
+		return json.NewMarshallerWithOptions(o, wr, atl)
 	case cbor.EncodeOptions:
-		return cbor.NewMarshallerAtlased(wr, atl)
+		return cbor.NewMarshallerWithOptions(o, wr, atl)
+	case dagcbor.EncodeOptions:
+		return dagcbor.NewMarshallerWithOptions(o, wr, atl)
+	case dagjson.EncodeOptions:
+		return dagjson.NewMarshallerWithOptions(o, wr, atl)
 	default:
 		panic("incorrect usage: unknown EncodeOptions type")
 	}
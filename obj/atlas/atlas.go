@@ -36,10 +36,12 @@ func Build(entries ...*AtlasEntry) (Atlas, error) {
 		atl.mappings[rtid] = entry
 
 		if entry.Tagged == true {
-			if prev, exists := atl.tagMappings[entry.Tag]; exists {
-				return Atlas{}, fmt.Errorf("repeated tag %v on type %v (already mapped to type %v)", entry.Tag, entry.Type, prev.Type)
+			for _, tag := range append([]int{entry.Tag}, entry.AltTags...) {
+				if prev, exists := atl.tagMappings[tag]; exists {
+					return Atlas{}, fmt.Errorf("repeated tag %v on type %v (already mapped to type %v)", tag, entry.Type, prev.Type)
+				}
+				atl.tagMappings[tag] = entry
 			}
-			atl.tagMappings[entry.Tag] = entry
 		}
 	}
 	return atl, nil
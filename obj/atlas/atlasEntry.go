@@ -60,6 +60,11 @@ type AtlasEntry struct {
 	// an empty pointer or empty slice, roughly as per if it was
 	// operating on a value produced by `TargetType.New()`.
 	UnmarshalTransformFunc UnmarshalTransformFunc
+	// Alternative to UnmarshalTransformFunc, for types which need to know
+	// what tag the serial form carried in order to interpret it.
+	// At most one of UnmarshalTransformFunc and UnmarshalTaggedTransformFunc
+	// should be set.
+	UnmarshalTaggedTransformFunc UnmarshalTaggedTransformFunc
 	// The type of value we will manufacture an instance of and unmarshal
 	// into, then when done provide to the UnmarshalTransformFunc.
 	//
@@ -77,6 +82,14 @@ type AtlasEntry struct {
 	Tag int
 	// Flag for whether the Tag feature should be used (zero is a valid tag).
 	Tagged bool
+	// Optional: picks the tag to emit when marshalling, for types where the
+	// right tag depends on the value (e.g. CBOR bignums use tag 2 when
+	// positive, and tag 3 when negative).  If nil, `Tag` is always used.
+	// Currently only applicable along with a MarshalTransformFunc.
+	MarshalTagFunc func(liveForm reflect.Value) int
+	// Additional tags which, just like `Tag`, will cause unmarshal to pick
+	// this atlas entry.  (Typically the other tags MarshalTagFunc may return.)
+	AltTags []int
 
 	// A mapping of fields in a struct to serial keys.
 	// Only valid if `this.Type.Kind() == Struct`.
@@ -137,3 +150,21 @@ func (x *BuilderCore) UseTag(tag int) *BuilderCore {
 	x.entry.Tag = tag
 	return x
 }
+
+/*
+	Like `UseTag`, but for types where the tag to emit depends on the value:
+	`tagFn` is called on each value when marshalling to pick its tag.
+	All the tags `tagFn` may return should be listed in `tags`; when
+	unmarshalling, any of them will cause this entry to be picked.
+	The first of `tags` is the entry's primary tag.
+*/
+func (x *BuilderCore) UseTagFunc(tagFn func(liveForm reflect.Value) int, tags ...int) *BuilderCore {
+	if len(tags) == 0 {
+		panic("UseTagFunc requires at least one tag")
+	}
+	x.entry.Tagged = true
+	x.entry.Tag = tags[0]
+	x.entry.AltTags = tags[1:]
+	x.entry.MarshalTagFunc = tagFn
+	return x
+}
@@ -0,0 +1,299 @@
+package commonatlases
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/polydawn/refmt/obj/atlas"
+)
+
+/*
+	BigInt_AsCborBignum maps `big.Int` to a CBOR bignum: a byte string of the
+	big-endian magnitude, marked with tag 2 for non-negative numbers, or tag 3
+	for negative numbers (which encode -1 minus the value), as per
+	RFC 7049 section 2.4.2.
+
+	When unmarshalling, bignums with either tag are accepted, as are plain
+	integers and decimal strings (e.g. as written by `BigInt_AsString`).
+
+	JSON has no bytes, so for JSON use `BigInt_AsString` instead; values
+	round-trip losslessly through either form.
+*/
+var BigInt_AsCborBignum = atlas.BuildEntry(big.Int{}).UseTagFunc(
+	func(rv reflect.Value) int {
+		x := rv.Interface().(big.Int)
+		if x.Sign() < 0 {
+			return 3
+		}
+		return 2
+	}, 2, 3).Transform().
+	TransformMarshal(atlas.MakeMarshalTransformFunc(
+		func(x big.Int) ([]byte, error) {
+			if x.Sign() < 0 {
+				// Negative bignums encode -1-n, i.e. the magnitude less one.
+				var n big.Int
+				n.Neg(&x)
+				n.Sub(&n, bigOne)
+				return n.Bytes(), nil
+			}
+			return x.Bytes(), nil
+		})).
+	TransformUnmarshalTagged(atlas.MakeUnmarshalTaggedTransformFunc(
+		unmarshalBigInt)).
+	Complete()
+
+/*
+	BigInt_AsString maps `big.Int` to its decimal string form.
+	This is the representation to use in JSON.
+
+	When unmarshalling, CBOR bignums (tags 2 and 3) and plain integers are
+	accepted as well, so this entry can read anything written by
+	`BigInt_AsCborBignum`.
+*/
+var BigInt_AsString = atlas.BuildEntry(big.Int{}).Transform().
+	TransformMarshal(atlas.MakeMarshalTransformFunc(
+		func(x big.Int) (string, error) {
+			return x.String(), nil
+		})).
+	TransformUnmarshalTagged(atlas.MakeUnmarshalTaggedTransformFunc(
+		unmarshalBigInt)).
+	Complete()
+
+var bigOne = big.NewInt(1)
+
+func unmarshalBigInt(tag int, x interface{}) (big.Int, error) {
+	var v big.Int
+	switch x2 := x.(type) {
+	case []byte:
+		switch tag {
+		case -1, 2:
+			v.SetBytes(x2)
+		case 3:
+			v.SetBytes(x2)
+			v.Add(&v, bigOne)
+			v.Neg(&v)
+		default:
+			return v, fmt.Errorf("cannot unmarshal bytes with tag %d as a bignum", tag)
+		}
+	case string:
+		if _, ok := v.SetString(x2, 10); !ok {
+			return v, fmt.Errorf("cannot unmarshal %q as a big int", x2)
+		}
+	case int64:
+		v.SetInt64(x2)
+	case uint64:
+		v.SetUint64(x2)
+	case big.Int:
+		v.Set(&x2)
+	default:
+		return v, fmt.Errorf("cannot unmarshal %T as a big int", x)
+	}
+	return v, nil
+}
+
+/*
+	BigFloat_AsCborBigfloat maps `big.Float` to a CBOR bigfloat: an array of
+	[exponent, mantissa] marked with tag 5, meaning mantissa*2^exponent, as per
+	RFC 7049 section 2.4.3.  This is exact for any finite value.
+
+	Mantissas too large for an int64 are written as bignums, so
+	`BigInt_AsCborBignum` must also be in the atlas to marshal those.
+
+	When unmarshalling, decimal fractions (tag 4; [exponent, mantissa]
+	meaning mantissa*10^exponent) are also accepted, as are plain numbers
+	and decimal strings (e.g. as written by `BigFloat_AsString`).
+	Precision is chosen to hold the value exactly whenever it's representable
+	in binary at all, and is at least 64 bits.
+	Decimal exponents beyond +/-5000 are rejected, since they're costly
+	to compute.
+
+	Infinities cannot be represented and will cause an error.
+*/
+var BigFloat_AsCborBigfloat = atlas.BuildEntry(big.Float{}).UseTag(5).Transform().
+	TransformMarshal(atlas.MakeMarshalTransformFunc(
+		func(x big.Float) ([]interface{}, error) {
+			if x.IsInf() {
+				return nil, fmt.Errorf("cannot marshal infinite big float as a bigfloat")
+			}
+			mant, exp := bigFloatToMantExp(&x)
+			if mant.IsInt64() {
+				return []interface{}{int64(exp), mant.Int64()}, nil
+			}
+			return []interface{}{int64(exp), mant}, nil
+		})).
+	TransformUnmarshalTagged(atlas.MakeUnmarshalTaggedTransformFunc(
+		unmarshalBigFloat)).
+	Complete()
+
+/*
+	BigFloat_AsString maps `big.Float` to an exact decimal string.
+	This is the representation to use in JSON.
+
+	(The string may be long: decimal is exact for any binary float, but
+	e.g. 2^-100 takes 100 digits after the point.)
+
+	When unmarshalling, CBOR bigfloats and decimal fractions (tags 5 and 4)
+	and plain numbers are accepted as well, so this entry can read anything
+	written by `BigFloat_AsCborBigfloat`.
+
+	Infinities are written as "+Inf" and "-Inf".
+*/
+var BigFloat_AsString = atlas.BuildEntry(big.Float{}).Transform().
+	TransformMarshal(atlas.MakeMarshalTransformFunc(
+		func(x big.Float) (string, error) {
+			if x.IsInf() {
+				return x.String(), nil
+			}
+			r, _ := x.Rat(nil)
+			// The denominator is a power of two, 2^n; n digits after the point is exact.
+			s := r.FloatString(r.Denom().BitLen() - 1)
+			if r.IsInt() {
+				return s, nil
+			}
+			for s[len(s)-1] == '0' {
+				s = s[:len(s)-1]
+			}
+			return s, nil
+		})).
+	TransformUnmarshalTagged(atlas.MakeUnmarshalTaggedTransformFunc(
+		unmarshalBigFloat)).
+	Complete()
+
+// Returns mantissa and exponent such that x == mant*2^exp,
+// with the mantissa an integer with no trailing zero bits.
+func bigFloatToMantExp(x *big.Float) (*big.Int, int) {
+	var m big.Float
+	exp := x.MantExp(&m) // x == m*2^exp, 0.5 <= |m| < 1
+	prec := int(x.MinPrec())
+	m.SetMantExp(&m, prec) // now an integer.
+	mant, _ := m.Int(nil)
+	return mant, exp - prec
+}
+
+func unmarshalBigFloat(tag int, x interface{}) (big.Float, error) {
+	var v big.Float
+	switch x2 := x.(type) {
+	case []interface{}:
+		if tag != 4 && tag != 5 || len(x2) != 2 {
+			return v, fmt.Errorf("cannot unmarshal array with tag %d as a big float", tag)
+		}
+		var exp int64
+		switch e := x2[0].(type) {
+		case int64:
+			exp = e
+		case uint64:
+			if e > math.MaxInt32 {
+				return v, fmt.Errorf("big float exponent out of range")
+			}
+			exp = int64(e)
+		default:
+			return v, fmt.Errorf("cannot unmarshal %T as a big float exponent", x2[0])
+		}
+		if exp > math.MaxInt32 || exp < math.MinInt32 {
+			return v, fmt.Errorf("big float exponent out of range")
+		}
+		if tag == 4 && abs64(exp) > maxDecimalExponent {
+			return v, fmt.Errorf("decimal fraction exponent %d out of range (limit is %d)", exp, maxDecimalExponent)
+		}
+		mant, err := unmarshalBigInt(-1, x2[1])
+		if err != nil {
+			return v, err
+		}
+		if tag == 5 {
+			v.SetInt(&mant)
+			v.SetMantExp(&v, int(exp))
+			return v, nil
+		}
+		var r big.Rat
+		r.SetInt(&mant)
+		var pow big.Int
+		pow.Exp(big.NewInt(10), big.NewInt(abs64(exp)), nil)
+		if exp < 0 {
+			r.Quo(&r, new(big.Rat).SetInt(&pow))
+		} else {
+			r.Mul(&r, new(big.Rat).SetInt(&pow))
+		}
+		return bigFloatFromRat(&r), nil
+	case string:
+		switch x2 {
+		case "+Inf", "Inf":
+			v.SetInf(false)
+			return v, nil
+		case "-Inf":
+			v.SetInf(true)
+			return v, nil
+		}
+		if err := checkDecimalExponent(x2); err != nil {
+			return v, err
+		}
+		var r big.Rat
+		if _, ok := r.SetString(x2); !ok {
+			return v, fmt.Errorf("cannot unmarshal %q as a big float", x2)
+		}
+		return bigFloatFromRat(&r), nil
+	case int64:
+		v.SetInt64(x2)
+	case uint64:
+		v.SetUint64(x2)
+	case float64:
+		if math.IsNaN(x2) {
+			return v, fmt.Errorf("cannot unmarshal NaN as a big float")
+		}
+		v.SetFloat64(x2)
+	default:
+		return v, fmt.Errorf("cannot unmarshal %T as a big float", x)
+	}
+	return v, nil
+}
+
+// Converts a rational to a float, exactly if the denominator is a power of two.
+func bigFloatFromRat(r *big.Rat) big.Float {
+	var v big.Float
+	prec := uint(r.Num().BitLen())
+	if prec < 64 {
+		prec = 64
+	}
+	v.SetPrec(prec)
+	v.SetRat(r)
+	return v
+}
+
+/*
+	The largest decimal exponent accepted when unmarshalling a big float,
+	from a decimal fraction (tag 4) or a string like "1e100".
+
+	Decoding one means computing ten to the power of the exponent, so
+	a few bytes of hostile input with a huge exponent could otherwise
+	take gigabytes of memory and minutes of work.  (Binary exponents,
+	as in tag 5, are cheap, and only limited by big.Float's own range.)
+*/
+const maxDecimalExponent = 5000
+
+// Check that the exponent of a number in decimal string form, if it has one,
+// is within maxDecimalExponent.
+func checkDecimalExponent(s string) error {
+	i := strings.LastIndexAny(s, "eE")
+	if i < 0 {
+		return nil
+	}
+	exp, err := strconv.ParseInt(s[i+1:], 10, 64)
+	if err != nil {
+		if err.(*strconv.NumError).Err != strconv.ErrRange {
+			return nil // Not a plain exponent; leave it to the full parse to reject.
+		}
+	} else if -maxDecimalExponent <= exp && exp <= maxDecimalExponent {
+		return nil
+	}
+	return fmt.Errorf("exponent of %q out of range (limit is %d)", s, maxDecimalExponent)
+}
+
+func abs64(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
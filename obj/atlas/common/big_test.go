@@ -0,0 +1,126 @@
+package commonatlases
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/polydawn/refmt/cbor"
+	"github.com/polydawn/refmt/json"
+	"github.com/polydawn/refmt/obj/atlas"
+)
+
+func Example_bigInt() {
+	cborAtl := atlas.MustBuild(BigInt_AsCborBignum)
+	jsonAtl := atlas.MustBuild(BigInt_AsString)
+
+	x := new(big.Int).Lsh(big.NewInt(1), 70)
+	msg, _ := cbor.MarshalAtlased(x, cborAtl)
+	fmt.Printf("%x\n", msg)
+	msg, _ = cbor.MarshalAtlased(new(big.Int).Neg(x), cborAtl)
+	fmt.Printf("%x\n", msg)
+
+	// cbor to json and back again, without loss.
+	var x2 *big.Int
+	err := cbor.UnmarshalAtlased(msg, &x2, cborAtl)
+	fmt.Printf("%s %v\n", x2, err)
+	msg, _ = json.MarshalAtlased(x2, jsonAtl)
+	fmt.Printf("%s\n", msg)
+	var x3 big.Int
+	err = json.UnmarshalAtlased(msg, &x3, jsonAtl)
+	fmt.Printf("%s %v\n", &x3, err)
+	msg, _ = cbor.MarshalAtlased(x3, cborAtl)
+	fmt.Printf("%x\n", msg)
+
+	// Output:
+	// c249400000000000000000
+	// c3493fffffffffffffffff
+	// -1180591620717411303424 <nil>
+	// "-1180591620717411303424"
+	// -1180591620717411303424 <nil>
+	// c3493fffffffffffffffff
+}
+
+func Example_bigFloat() {
+	cborAtl := atlas.MustBuild(BigFloat_AsCborBigfloat, BigInt_AsCborBignum)
+	jsonAtl := atlas.MustBuild(BigFloat_AsString)
+
+	x, _, _ := big.ParseFloat("1.5", 10, 100, big.ToNearestEven)
+	msg, _ := cbor.MarshalAtlased(x, cborAtl)
+	fmt.Printf("%x\n", msg)
+
+	// A value needing more than 64 bits of mantissa, and more than float64 exponent.
+	x.SetPrec(100).SetMantExp(new(big.Float).SetInt(new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), 80), big.NewInt(1))), -2000)
+	msg, _ = cbor.MarshalAtlased(x, cborAtl)
+	fmt.Printf("%x\n", msg)
+
+	// cbor to json and back again, without loss.
+	var x2 big.Float
+	err := cbor.UnmarshalAtlased(msg, &x2, cborAtl)
+	fmt.Printf("%v %v\n", x2.Cmp(x) == 0, err)
+	msg, _ = json.MarshalAtlased(x2, jsonAtl)
+	var x3 *big.Float
+	err = json.UnmarshalAtlased(msg, &x3, jsonAtl)
+	fmt.Printf("%v %v\n", x3.Cmp(x) == 0, err)
+
+	// Decimal fractions are accepted too.
+	var x4 big.Float
+	err = cbor.UnmarshalAtlased([]byte{0xc4, 0x82, 0x21, 0x19, 0x6a, 0xb3}, &x4, cborAtl)
+	fmt.Printf("%s %v\n", x4.Text('g', 10), err)
+
+	// Output:
+	// c5822003
+	// c5823907cfc24b0100000000000000000001
+	// true <nil>
+	// true <nil>
+	// 273.15 <nil>
+}
+
+func TestBigFloatHostileExponent(t *testing.T) {
+	cborAtl := atlas.MustBuild(BigFloat_AsCborBigfloat, BigInt_AsCborBignum)
+	jsonAtl := atlas.MustBuild(BigFloat_AsString)
+	for _, tr := range []struct {
+		title  string
+		serial []byte
+	}{
+		// Tag 4, [2147483647, 1]: ten bytes asking for 10^2147483647.
+		{"huge exponent", []byte{0xc4, 0x82, 0x1a, 0x7f, 0xff, 0xff, 0xff, 0x01}},
+		// Tag 4, [-2147483648, 1].
+		{"huge negative exponent", []byte{0xc4, 0x82, 0x3a, 0x7f, 0xff, 0xff, 0xff, 0x01}},
+		// Tag 4, [5001, 1].
+		{"just over the limit", []byte{0xc4, 0x82, 0x19, 0x13, 0x89, 0x01}},
+	} {
+		var x big.Float
+		done := make(chan error, 1)
+		go func() { done <- cbor.UnmarshalAtlased(tr.serial, &x, cborAtl) }()
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Errorf("%s: expected an error", tr.title)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: unmarshalling took too long", tr.title)
+		}
+	}
+
+	// Tag 4, [5000, 1] is fine.
+	var x big.Float
+	if err := cbor.UnmarshalAtlased([]byte{0xc4, 0x82, 0x19, 0x13, 0x88, 0x01}, &x, cborAtl); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if exp := x.MantExp(nil); exp < 16600 {
+		t.Errorf("expected about 10^5000, got %s", x.Text('g', 5))
+	}
+
+	// The same goes for exponents in strings.
+	for _, serial := range []string{`"1e2147483647"`, `"1e-99999999999999999999"`, `"1E5001"`} {
+		var x big.Float
+		if err := json.UnmarshalAtlased([]byte(serial), &x, jsonAtl); err == nil {
+			t.Errorf("%s: expected an error", serial)
+		}
+	}
+	if err := json.UnmarshalAtlased([]byte(`"2.5e-5000"`), &x, jsonAtl); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
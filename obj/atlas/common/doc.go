@@ -8,5 +8,9 @@
 	(`time.Time` is also an example of where *some* custom behavior is
 	pretty much required, because a default struct-mapping is useless on
 	a struct with no exported fields.)
+
+	The `math/big` types are here too, since they're likewise unusable as
+	plain structs; CBOR has standard tags for them, while in JSON they're
+	best handled as decimal strings.
 */
 package commonatlases
@@ -1,6 +1,8 @@
 package commonatlases
 
 import (
+	"fmt"
+	"math"
 	"time"
 
 	"github.com/polydawn/refmt/obj/atlas"
@@ -27,3 +29,67 @@ var Time_AsRFC3339 = atlas.BuildEntry(time.Time{}).Transform().
 			return time.Parse(time.RFC3339, x)
 		})).
 	Complete()
+
+/*
+	Time_AsTaggedRFC3339 maps `time.Time` to an RFC3339 string (with as
+	many fractional seconds as needed to be lossless) marked with CBOR tag 0,
+	which is the standard tag for a date/time string (RFC 7049 section 2.4.1).
+	In JSON, where tags don't exist, this is simply an RFC3339 string.
+
+	When unmarshalling, either tag 0 or tag 1 serial forms are accepted
+	(as are untagged strings and numbers), so this entry can read anything
+	written by `Time_AsTaggedUnix` as well.
+*/
+var Time_AsTaggedRFC3339 = atlas.BuildEntry(time.Time{}).UseTag(0).Transform().
+	TransformMarshal(atlas.MakeMarshalTransformFunc(
+		func(x time.Time) (string, error) {
+			return x.Format(time.RFC3339Nano), nil
+		})).
+	TransformUnmarshal(atlas.MakeUnmarshalTransformFunc(
+		unmarshalTaggedTime)).
+	Complete()
+
+/*
+	Time_AsTaggedUnix maps `time.Time` to seconds since the unix epoch
+	marked with CBOR tag 1, which is the standard tag for an epoch-based
+	date/time (RFC 7049 section 2.4.1).  Times with no fractional seconds
+	are emitted as integers; otherwise, as floats.
+
+	When unmarshalling, either tag 0 or tag 1 serial forms are accepted
+	(as are untagged strings and numbers), so this entry can read anything
+	written by `Time_AsTaggedRFC3339` as well.
+*/
+var Time_AsTaggedUnix = atlas.BuildEntry(time.Time{}).UseTag(1).Transform().
+	TransformMarshal(atlas.MakeMarshalTransformFunc(
+		func(x time.Time) (interface{}, error) {
+			if x.Nanosecond() == 0 {
+				return x.Unix(), nil
+			}
+			// (Not UnixNano, which overflows outside the years 1678 to 2262.)
+			return float64(x.Unix()) + float64(x.Nanosecond())/1e9, nil
+		})).
+	TransformUnmarshal(atlas.MakeUnmarshalTransformFunc(
+		unmarshalTaggedTime)).
+	Complete()
+
+func unmarshalTaggedTime(x interface{}) (time.Time, error) {
+	switch x2 := x.(type) {
+	case string:
+		return time.Parse(time.RFC3339Nano, x2)
+	case int64:
+		return time.Unix(x2, 0).UTC(), nil
+	case uint64:
+		if x2 > math.MaxInt64 {
+			return time.Time{}, fmt.Errorf("time out of range: %d seconds since epoch", x2)
+		}
+		return time.Unix(int64(x2), 0).UTC(), nil
+	case float64:
+		if math.IsNaN(x2) || x2 >= math.MaxInt64 || x2 < math.MinInt64 {
+			return time.Time{}, fmt.Errorf("time out of range: %v seconds since epoch", x2)
+		}
+		sec, frac := math.Modf(x2)
+		return time.Unix(int64(sec), int64(math.Round(frac*1e9))).UTC(), nil
+	default:
+		return time.Time{}, fmt.Errorf("cannot unmarshal %T as a time", x)
+	}
+}
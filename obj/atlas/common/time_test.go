@@ -2,8 +2,10 @@ package commonatlases
 
 import (
 	"fmt"
+	"testing"
 	"time"
 
+	"github.com/polydawn/refmt/cbor"
 	"github.com/polydawn/refmt/json"
 	"github.com/polydawn/refmt/obj/atlas"
 )
@@ -29,3 +31,66 @@ func ExampleTime() {
 	// "2014-12-25T01:00:00Z"
 	// 2014-12-25 01:00:00 +0000 UTC
 }
+
+func ExampleTime_tagged() {
+	atl0 := atlas.MustBuild(Time_AsTaggedRFC3339)
+	atl1 := atlas.MustBuild(Time_AsTaggedUnix)
+
+	msg, _ := cbor.MarshalAtlased(time.Date(2014, 12, 25, 1, 0, 0, 0, time.UTC), atl0)
+	fmt.Printf("%x\n", msg)
+	msg, _ = cbor.MarshalAtlased(time.Date(2014, 12, 25, 1, 0, 0, 0, time.UTC), atl1)
+	fmt.Printf("%x\n", msg)
+	msg, _ = cbor.MarshalAtlased(time.Date(2014, 12, 25, 1, 0, 0, 500000000, time.UTC), atl1)
+	fmt.Printf("%x\n", msg)
+
+	// Either entry can read either form.
+	var t1 time.Time
+	err := cbor.UnmarshalAtlased(msg, &t1, atl0)
+	fmt.Printf("%s %v\n", t1, err)
+	var v interface{}
+	err = cbor.UnmarshalAtlased(msg, &v, atl1)
+	fmt.Printf("%s %v\n", v, err)
+
+	// In JSON, the tag 0 form is just a string.
+	msg, _ = json.MarshalAtlased(time.Date(2014, 12, 25, 1, 0, 0, 0, time.UTC), atl0)
+	fmt.Printf("%s\n", msg)
+
+	// Output:
+	// c074323031342d31322d32355430313a30303a30305a
+	// c11a549b6190
+	// c1fb41d526d864200000
+	// 2014-12-25 01:00:00.5 +0000 UTC <nil>
+	// 2014-12-25 01:00:00.5 +0000 UTC <nil>
+	// "2014-12-25T01:00:00Z"
+}
+
+func TestTimeTaggedUnixFarDates(t *testing.T) {
+	atl := atlas.MustBuild(Time_AsTaggedUnix)
+	for _, t0 := range []time.Time{
+		time.Date(1000, 1, 1, 0, 0, 0, 500000000, time.UTC),
+		time.Date(1677, 6, 1, 12, 0, 0, 250000000, time.UTC),
+		time.Date(2263, 1, 1, 0, 0, 0, 750000000, time.UTC),
+		time.Date(9999, 12, 31, 23, 59, 59, 500000000, time.UTC),
+	} {
+		msg, err := cbor.MarshalAtlased(t0, atl)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", t0, err)
+			continue
+		}
+		var t1 time.Time
+		if err := cbor.UnmarshalAtlased(msg, &t1, atl); err != nil {
+			t.Errorf("%s: unexpected error %v", t0, err)
+			continue
+		}
+		if !t1.Equal(t0) {
+			t.Errorf("expected %s, got %s", t0, t1)
+		}
+	}
+
+	// Floats too big for any time are rejected.
+	var t1 time.Time
+	msg, _ := cbor.Marshal(1e300)
+	if err := cbor.UnmarshalAtlased(append([]byte{0xc1}, msg...), &t1, atl); err == nil {
+		t.Errorf("expected an error, got %s", t1)
+	}
+}
@@ -8,8 +8,11 @@ import (
 type KeySortMode string
 
 const (
-	KeySortMode_Default = "default" // e.g. lexical string sort for strings, etc
-	KeySortMode_RFC7049 = "rfc7049" // "Canonical" as proposed by rfc7049 § 3.9 (shorter byte sequences sort to top)
+	KeySortMode_Default     = "default"     // e.g. lexical string sort for strings, etc; for structs, the order fields were added in
+	KeySortMode_Declaration = "declaration" // structs only: order of field declaration in the go type
+	KeySortMode_Lexical     = "lexical"     // lexical string sort of the serial keys
+	KeySortMode_RFC7049     = "rfc7049"     // "Canonical" as proposed by rfc7049 § 3.9 (shorter byte sequences sort to top)
+	KeySortMode_RFC8949     = "rfc8949"     // "Deterministic" as in rfc8949 § 4.2.1 (bytewise order of the encoded keys)
 )
 
 type MapMorphism struct {
@@ -33,7 +36,7 @@ func (x *BuilderMapMorphism) Complete() *AtlasEntry {
 
 func (x *BuilderMapMorphism) SetKeySortMode(km KeySortMode) *BuilderMapMorphism {
 	switch km {
-	case KeySortMode_Default, KeySortMode_RFC7049:
+	case KeySortMode_Default, KeySortMode_Lexical, KeySortMode_RFC7049, KeySortMode_RFC8949:
 		x.entry.MapMorphism.KeySortMode = km
 	default:
 		panic(fmt.Errorf("invalid key sort mode %q", km))
@@ -7,6 +7,12 @@ type StructMap struct {
 	// Each entry specifies the name by which each field should be referenced
 	// when serialized, and defines a way to get an address to the field.
 	Fields []StructMapEntry
+
+	// Determines the order fields are emitted in when marshalling.
+	// The builder sorts `Fields` accordingly when `Complete` is called,
+	// so the marshaller can simply walk them in order.
+	// (Unmarshalling accepts fields in any order regardless.)
+	KeySortMode KeySortMode
 }
 
 type StructMapEntry struct {
@@ -14,7 +14,7 @@ func AutogenerateStructMapEntry(rt reflect.Type) *AtlasEntry {
 func AutogenerateStructMapEntryUsingTags(rt reflect.Type, tagName string) *AtlasEntry {
 	entry := &AtlasEntry{
 		Type:      rt,
-		StructMap: &StructMap{Fields: exploreFields(rt, tagName)},
+		StructMap: &StructMap{Fields: exploreFields(rt, tagName), KeySortMode: KeySortMode_Default},
 	}
 	return entry
 }
@@ -234,6 +234,27 @@ func (x StructMapEntry_byFieldRoute) Less(i, j int) bool {
 	return len(x[i].ReflectRoute) < len(x[j].ReflectRoute)
 }
 
+// StructMapEntry_bySerialName sorts field by SerialName.
+type StructMapEntry_bySerialName []StructMapEntry
+
+func (x StructMapEntry_bySerialName) Len() int           { return len(x) }
+func (x StructMapEntry_bySerialName) Swap(i, j int)      { x[i], x[j] = x[j], x[i] }
+func (x StructMapEntry_bySerialName) Less(i, j int) bool { return x[i].SerialName < x[j].SerialName }
+
+// StructMapEntry_RFC7049 sorts field by SerialName,
+// shorter names first, then lexically (as per rfc7049 § 3.9).
+type StructMapEntry_RFC7049 []StructMapEntry
+
+func (x StructMapEntry_RFC7049) Len() int      { return len(x) }
+func (x StructMapEntry_RFC7049) Swap(i, j int) { x[i], x[j] = x[j], x[i] }
+func (x StructMapEntry_RFC7049) Less(i, j int) bool {
+	li, lj := len(x[i].SerialName), len(x[j].SerialName)
+	if li == lj {
+		return x[i].SerialName < x[j].SerialName
+	}
+	return li < lj
+}
+
 // tagOptions is the string following a comma in a struct field's
 // tag, or the empty string. It does not include the leading comma.
 type tagOptions string
@@ -1,12 +1,14 @@
 package atlas
 
 import (
+	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 )
 
 func (x *BuilderCore) StructMap() *BuilderStructMap {
-	x.entry.StructMap = &StructMap{}
+	x.entry.StructMap = &StructMap{KeySortMode: KeySortMode_Default}
 	return &BuilderStructMap{x.entry}
 }
 
@@ -15,9 +17,52 @@ type BuilderStructMap struct {
 }
 
 func (x *BuilderStructMap) Complete() *AtlasEntry {
+	sortStructMapFields(x.entry.StructMap.Fields, x.entry.StructMap.KeySortMode)
 	return x.entry
 }
 
+/*
+	Set the order in which fields will be emitted when marshalling.
+
+	`KeySortMode_Default` emits fields in the order they were added to the
+	builder (for `Autogenerate`, that's declaration order).
+	`KeySortMode_Declaration` emits fields in declaration order even if
+	they were added in some other order.
+	`KeySortMode_Lexical` sorts by serial name; `KeySortMode_RFC7049` and
+	`KeySortMode_RFC8949` produce the canonical orders of those specs,
+	and are the modes to use if you want stable hashes of CBOR output.
+
+	The fields are sorted when `Complete` is called.
+	If the mode is not recognized, a panic will be raised.
+*/
+func (x *BuilderStructMap) SetKeySortMode(km KeySortMode) *BuilderStructMap {
+	switch km {
+	case KeySortMode_Default, KeySortMode_Declaration, KeySortMode_Lexical, KeySortMode_RFC7049, KeySortMode_RFC8949:
+		x.entry.StructMap.KeySortMode = km
+	default:
+		panic(fmt.Errorf("invalid key sort mode %q", km))
+	}
+	return x
+}
+
+func sortStructMapFields(fields []StructMapEntry, km KeySortMode) {
+	switch km {
+	case KeySortMode_Default:
+		// Leave them as given.
+	case KeySortMode_Declaration:
+		sort.Stable(StructMapEntry_byFieldRoute(fields))
+	case KeySortMode_Lexical:
+		sort.Stable(StructMapEntry_bySerialName(fields))
+	case KeySortMode_RFC7049, KeySortMode_RFC8949:
+		// Serial names are always text strings, and for those, bytewise
+		// order of the encoded form is the length-first order, since the
+		// length is in the header.  So the two specs agree here.
+		sort.Stable(StructMapEntry_RFC7049(fields))
+	default:
+		panic(fmt.Errorf("invalid key sort mode %q", km))
+	}
+}
+
 /*
 	Add a field to the mapping based on its name.
 
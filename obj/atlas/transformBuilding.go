@@ -27,3 +27,9 @@ func (x *BuilderTransform) TransformUnmarshal(trFunc UnmarshalTransformFunc, toT
 	x.entry.UnmarshalTransformTargetType = toType
 	return x
 }
+
+func (x *BuilderTransform) TransformUnmarshalTagged(trFunc UnmarshalTaggedTransformFunc, toType reflect.Type) *BuilderTransform {
+	x.entry.UnmarshalTaggedTransformFunc = trFunc
+	x.entry.UnmarshalTransformTargetType = toType
+	return x
+}
@@ -1,9 +1,12 @@
 package atlas
 
-import "reflect"
+import (
+	"reflect"
+)
 
 type MarshalTransformFunc func(liveForm reflect.Value) (serialForm reflect.Value, err error)
 type UnmarshalTransformFunc func(serialForm reflect.Value) (liveForm reflect.Value, err error)
+type UnmarshalTaggedTransformFunc func(tag int, serialForm reflect.Value) (liveForm reflect.Value, err error)
 
 var err_rt = reflect.TypeOf((*error)(nil)).Elem()
 
@@ -66,3 +69,37 @@ func MakeUnmarshalTransformFunc(fn interface{}) (UnmarshalTransformFunc, reflect
 		return results[0], results[1].Interface().(error)
 	}, in_rt
 }
+
+/*
+	Takes a wildcard object which must be `func (tag int, serialable T1) (live T2, error)`
+	and returns a UnmarshalTaggedTransformFunc and the typeinfo of T1.
+
+	The tag is the one carried by the serial form, or -1 if it was untagged.
+*/
+func MakeUnmarshalTaggedTransformFunc(fn interface{}) (UnmarshalTaggedTransformFunc, reflect.Type) {
+	fn_rv := reflect.ValueOf(fn)
+	if fn_rv.Kind() != reflect.Func {
+		panic("no")
+	}
+	fn_rt := fn_rv.Type()
+	if fn_rt.NumIn() != 2 {
+		panic("no")
+	}
+	if fn_rt.In(0) != reflect.TypeOf(0) {
+		panic("no")
+	}
+	if fn_rt.NumOut() != 2 {
+		panic("no")
+	}
+	if !fn_rt.Out(1).AssignableTo(err_rt) {
+		panic("no")
+	}
+	in_rt := fn_rt.In(1)
+	return func(tag int, serialForm reflect.Value) (liveForm reflect.Value, err error) {
+		results := fn_rv.Call([]reflect.Value{reflect.ValueOf(tag), serialForm})
+		if results[1].IsNil() {
+			return results[0], nil
+		}
+		return results[0], results[1].Interface().(error)
+	}, in_rt
+}
@@ -21,4 +21,7 @@ var (
 	rtid_uintptr = ValueOf(TypeOf(uintptr(0))).Pointer()
 	rtid_float32 = ValueOf(TypeOf(float32(0))).Pointer()
 	rtid_float64 = ValueOf(TypeOf(float64(0))).Pointer()
+
+	rtid_undefined   = ValueOf(TypeOf(Undefined{})).Pointer()
+	rtid_simpleValue = ValueOf(TypeOf(SimpleValue(0))).Pointer()
 )
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 
+	"github.com/polydawn/refmt/shared"
 	. "github.com/polydawn/refmt/tok"
 )
 
@@ -39,14 +40,10 @@ func (e ErrUnmarshalTypeCantFit) Error() string {
 // ErrMalformedTokenStream is the error returned when unmarshalling recieves a
 // completely invalid transition, such as when a map value is expected, but the
 // map suddenly closes, or an array close is recieved with no matching array open.
-type ErrMalformedTokenStream struct {
-	Got      TokenType // Token in the stream that triggered the error.
-	Expected string    // Freeform string describing valid token types.  Often a summary like "array close or start of value", or "map close or key".
-}
-
-func (e ErrMalformedTokenStream) Error() string {
-	return fmt.Sprintf("malformed stream: invalid appearance of %s token; expected %s", e.Got, e.Expected)
-}
+//
+// It's the same type as `shared.ErrMalformedTokenStream`, which is also
+// returned by `shared.Validator`.
+type ErrMalformedTokenStream = shared.ErrMalformedTokenStream
 
 // ErrNoSuchField is the error returned when unmarshalling into a struct and
 // the token stream for the map contains a key which is not defined for the struct.
@@ -16,6 +16,8 @@ import (
 	after this, the `Step` function is ready to be pumped.
 	Subsequent calls to `Bind` do a full reset, leaving `Step` ready to call
 	again and making all of the machinery reusable without re-allocating.
+
+	To trace the tokens, wrap it in `shared.NewRecordingSource`, and print the recording.
 */
 func NewMarshaller(atl atlas.Atlas) *Marshaller {
 	d := &Marshaller{
@@ -38,7 +40,12 @@ func (d *Marshaller) Bind(v interface{}) error {
 	}
 	rt := rv.Type()
 	d.step = d.marshalSlab.requisitionMachine(rt)
-	return d.step.Reset(&d.marshalSlab, rv, rt)
+	if err := d.step.Reset(&d.marshalSlab, rv, rt); err != nil {
+		// Keep the error around, so that pumping `Step` reports it too.
+		d.step = &errThunkMarshalMachine{err}
+		return err
+	}
+	return nil
 }
 
 type Marshaller struct {
@@ -54,9 +61,8 @@ type MarshalMachine interface {
 
 func (d *Marshaller) Step(tok *Token) (bool, error) {
 	tok.Tagged = false
-	//	fmt.Printf("> next step is %#v\n", d.step)
+	tok.OuterTags = tok.OuterTags[:0]
 	done, err := d.step.Step(d, &d.marshalSlab, tok)
-	//	fmt.Printf(">> yield is %#v\n", TokenToString(*tok))
 	// If the step errored: out, entirely.
 	if err != nil {
 		return true, err
@@ -70,7 +76,6 @@ func (d *Marshaller) Step(tok *Token) (bool, error) {
 	if nSteps == -1 {
 		return true, nil // that's all folks
 	}
-	//	fmt.Printf(">> popping up from %#v\n", d.stack)
 	d.step = d.stack[nSteps]
 	d.stack = d.stack[0:nSteps]
 	return false, nil
@@ -93,7 +98,6 @@ func (d *Marshaller) Step(tok *Token) (bool, error) {
 	that object will be traversed and the stream ready for you to continue.
 */
 func (d *Marshaller) Recurse(tok *Token, rv reflect.Value, rt reflect.Type, nextMach MarshalMachine) (err error) {
-	//	fmt.Printf(">>> pushing into recursion with %#v\n", nextMach)
 	// Push the current machine onto the stack (we'll resume it when the new one is done),
 	d.stack = append(d.stack, d.step)
 	// Initialize the machine for this new target value.
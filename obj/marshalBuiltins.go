@@ -72,3 +72,21 @@ func (mach *marshalMachinePrimitive) Step(_ *Marshaller, _ *marshalSlab, tok *To
 		panic("unhandled")
 	}
 }
+
+type marshalMachineSimple struct {
+	rv reflect.Value
+}
+
+func (mach *marshalMachineSimple) Reset(_ *marshalSlab, rv reflect.Value, _ reflect.Type) error {
+	mach.rv = rv
+	return nil
+}
+func (mach *marshalMachineSimple) Step(_ *Marshaller, _ *marshalSlab, tok *Token) (done bool, err error) {
+	if mach.rv.Kind() == reflect.Struct {
+		tok.Type = TUndefined
+		return true, nil
+	}
+	tok.Type = TSimple
+	tok.Uint = mach.rv.Uint()
+	return true, nil
+}
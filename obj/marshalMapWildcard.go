@@ -48,9 +48,11 @@ func (mach *marshalMachineMapWildcard) Reset(slab *marshalSlab, rv reflect.Value
 		mach.keys[i].s = v.String()
 	}
 	switch mach.cfg.MapMorphism.KeySortMode {
-	case atlas.KeySortMode_Default:
+	case atlas.KeySortMode_Default, atlas.KeySortMode_Lexical:
 		sort.Sort(wildcardMapStringyKey_byString(mach.keys))
-	case atlas.KeySortMode_RFC7049:
+	case atlas.KeySortMode_RFC7049, atlas.KeySortMode_RFC8949:
+		// For string keys, rfc8949's bytewise order of the encoded keys
+		// comes out the same as rfc7049's length-first order.
 		sort.Sort(wildcardMapStringyKey_RFC7049(mach.keys))
 	default:
 		panic(fmt.Errorf("unknown map key sort mode %q", mach.cfg.MapMorphism.KeySortMode))
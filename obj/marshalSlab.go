@@ -21,6 +21,7 @@ type marshalSlab struct {
 type marshalSlabRow struct {
 	ptrDerefDelegateMarshalMachine
 	marshalMachinePrimitive
+	marshalMachineSimple
 	marshalMachineWildcard
 	marshalMachineMapWildcard
 	marshalMachineSliceWildcard
@@ -95,6 +96,8 @@ func _yieldMarshalMachinePtr(row *marshalSlabRow, atl atlas.Atlas, rt reflect.Ty
 		rtid_bytes:
 		row.marshalMachinePrimitive.kind = rt.Kind()
 		return &row.marshalMachinePrimitive
+	case rtid_undefined, rtid_simpleValue:
+		return &row.marshalMachineSimple
 	}
 
 	// Consult atlas second.
@@ -113,6 +116,7 @@ func _yieldMarshalMachinePtr(row *marshalSlabRow, atl atlas.Atlas, rt reflect.Ty
 			if entry.Tagged {
 				row.marshalMachineTransform.tagged = true
 				row.marshalMachineTransform.tag = entry.Tag
+				row.marshalMachineTransform.tagFunc = entry.MarshalTagFunc
 			}
 			return &row.marshalMachineTransform
 		case entry.StructMap != nil:
@@ -24,14 +24,14 @@ func (mach *marshalMachineStructAtlas) Reset(_ *marshalSlab, rv reflect.Value, _
 }
 
 func (mach *marshalMachineStructAtlas) Step(driver *Marshaller, slab *marshalSlab, tok *Token) (done bool, err error) {
-	//fmt.Printf("--step on %#v: i=%d/%d v=%v\n", mach.rv, mach.index, len(mach.cfg.Fields), mach.value)
 	nEntries := len(mach.cfg.StructMap.Fields)
 	if mach.index < 0 {
 		tok.Type = TMapOpen
 		tok.Length = nEntries
 		if mach.cfg.Tagged {
 			tok.Tagged = true
-			tok.Tag = mach.cfg.Tag
+			tok.Tag = uint64(mach.cfg.Tag)
+			tok.OuterTags = tok.OuterTags[:0]
 		}
 		mach.index++
 		return false, nil
@@ -12,7 +12,8 @@ type marshalMachineTransform struct {
 	delegate MarshalMachine
 	tagged   bool // Used to apply tag to first step (without forcing delegate to know).
 	tag      int
-	first    bool // This resets; 'tagged' persists (because it's type info).
+	tagFunc  func(reflect.Value) int // If set, picks 'tag' anew for each value.
+	first    bool                    // This resets; 'tagged' persists (because it's type info).
 }
 
 func (mach *marshalMachineTransform) Reset(slab *marshalSlab, rv reflect.Value, _ reflect.Type) error {
@@ -20,6 +21,9 @@ func (mach *marshalMachineTransform) Reset(slab *marshalSlab, rv reflect.Value,
 	if err != nil {
 		return err
 	}
+	if mach.tagFunc != nil {
+		mach.tag = mach.tagFunc(rv)
+	}
 	mach.first = true
 	return mach.delegate.Reset(slab, tr_rv, tr_rv.Type())
 }
@@ -27,8 +31,14 @@ func (mach *marshalMachineTransform) Reset(slab *marshalSlab, rv reflect.Value,
 func (mach *marshalMachineTransform) Step(driver *Marshaller, slab *marshalSlab, tok *Token) (done bool, err error) {
 	done, err = mach.delegate.Step(driver, slab, tok)
 	if mach.first && mach.tagged {
-		tok.Tagged = true
-		tok.Tag = mach.tag
+		if tok.Tagged {
+			// The delegate tagged it too; ours wraps around theirs.
+			tok.OuterTags = append([]uint64{uint64(mach.tag)}, tok.OuterTags...)
+		} else {
+			tok.Tagged = true
+			tok.Tag = uint64(mach.tag)
+			tok.OuterTags = tok.OuterTags[:0]
+		}
 		mach.first = false
 	}
 	return
@@ -45,6 +45,13 @@ type tObjStr2 struct {
 	Y string
 }
 
+type tObjStr4 struct {
+	D  string
+	B  string
+	Bc string
+	N1 string `refmt:"1"`
+}
+
 type tObjK struct {
 	K []tObjK2
 }
@@ -384,6 +391,39 @@ var objFixtures = []struct {
 				}},
 		},
 	},
+	{title: "struct with four string fields, with atlas entry (lexical key ordering), marshals ordered correctly",
+		sequence: fixtures.SequenceMap["quad map default order"],
+		atlas: atlas.MustBuild(
+			atlas.BuildEntry(tObjStr4{}).StructMap().
+				AddField("D", atlas.StructMapEntry{SerialName: "d"}).
+				AddField("B", atlas.StructMapEntry{SerialName: "b"}).
+				AddField("Bc", atlas.StructMapEntry{SerialName: "bc"}).
+				AddField("N1", atlas.StructMapEntry{SerialName: "1"}).
+				SetKeySortMode(atlas.KeySortMode_Lexical).
+				Complete(),
+		),
+		marshalResults: []marshalResults{
+			{title: "from object",
+				valueFn: func() interface{} { return tObjStr4{"4", "2", "3", "1"} }},
+		},
+		unmarshalResults: []unmarshalResults{
+			{title: "into *tObjStr4",
+				slotFn:  func() interface{} { return &tObjStr4{} },
+				valueFn: func() interface{} { return tObjStr4{"4", "2", "3", "1"} }},
+		},
+	},
+	{title: "struct with four string fields, with atlas entry (rfc7049 key ordering), marshals ordered correctly",
+		sequence: fixtures.SequenceMap["quad map rfc7049 order"],
+		atlas: atlas.MustBuild(
+			atlas.BuildEntry(tObjStr4{}).StructMap().Autogenerate().
+				SetKeySortMode(atlas.KeySortMode_RFC7049).
+				Complete(),
+		),
+		marshalResults: []marshalResults{
+			{title: "from object",
+				valueFn: func() interface{} { return tObjStr4{"3", "2", "4", "1"} }},
+		},
+	},
 	{title: "empty primitive arrays",
 		sequence: fixtures.SequenceMap["empty array"],
 		marshalResults: []marshalResults{
@@ -508,7 +548,7 @@ var objFixtures = []struct {
 				expectErr: ErrInvalidUnmarshalTarget{reflect.TypeOf([]string{})}},
 			{title: "into *[0]str",
 				slotFn:    func() interface{} { var v [0]string; return &v },
-				expectErr: ErrMalformedTokenStream{TString, "end of array (out of space)"}},
+				expectErr: ErrMalformedTokenStream{Got: TString, Expected: "end of array (out of space)"}},
 			{title: "into [2]str",
 				slotFn:    func() interface{} { var v []string; return v },
 				expectErr: ErrInvalidUnmarshalTarget{reflect.TypeOf([]string{})}},
@@ -0,0 +1,21 @@
+package obj
+
+/*
+	Undefined is the Go representation of CBOR's "undefined" value.
+
+	Unmarshalling an undefined token into a wildcard (`interface{}`) yields
+	an `Undefined{}`, and marshalling one yields an undefined token again,
+	so it survives a round trip (where a nil would turn into null).
+*/
+type Undefined struct{}
+
+/*
+	SimpleValue is the Go representation of CBOR's "simple values" --
+	other than false, true, null, and undefined, which have representations
+	of their own.
+
+	Unmarshalling a simple value token into a wildcard (`interface{}`) yields
+	a `SimpleValue`, and marshalling one yields a simple value token again.
+	Valid values are 0 through 19 and 32 through 255; the encoder will reject others.
+*/
+type SimpleValue uint8
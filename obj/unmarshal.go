@@ -1,9 +1,11 @@
 package obj
 
 import (
+	"fmt"
 	"reflect"
 
 	"github.com/polydawn/refmt/obj/atlas"
+	"github.com/polydawn/refmt/shared"
 	. "github.com/polydawn/refmt/tok"
 )
 
@@ -16,6 +18,8 @@ import (
 	after this, the `Step` function is ready to be pumped.
 	Subsequent calls to `Bind` do a full reset, leaving `Step` ready to call
 	again and making all of the machinery reusable without re-allocating.
+
+	To trace the tokens, wrap it in a `shared.NewTee` with a `tok.Buffer`, and print the recording.
 */
 func NewUnmarshaller(atl atlas.Atlas) *Unmarshaller {
 	d := &Unmarshaller{
@@ -28,9 +32,79 @@ func NewUnmarshaller(atl atlas.Atlas) *Unmarshaller {
 	return d
 }
 
+/*
+	Limits the memory the Unmarshaller may allocate while filling in a value;
+	useful when the token stream comes from untrusted input.
+
+	The budget is approximate: every token costs one unit, and string and
+	bytes tokens additionally cost their length.  When the budget is exhausted,
+	`Step` returns a `shared.ErrLimitExceeded` error.
+	Zero (the default) means unlimited.
+	The budget is replenished on every call to `Bind`.
+*/
+func (d *Unmarshaller) SetAllocBudget(budget int64) {
+	d.budget = budget
+}
+
+// A type to enumerate how slices are combined in merge mode.
+type SliceMergeMode string
+
+const (
+	SliceMergeMode_Replace = "replace" // Incoming slices replace existing ones entirely.
+	SliceMergeMode_Append  = "append"  // Incoming entries are appended after existing ones.
+	SliceMergeMode_ByIndex = "index"   // Incoming entries are merged into existing ones at the same index; the slice grows if necessary, and is never truncated.
+)
+
+/*
+	Configures the Unmarshaller to merge incoming data into the existing
+	value it's bound to, rather than replacing it.  This is useful for
+	layering configuration from several documents, for example.
+
+	In merge mode:
+
+	  - struct fields which aren't mentioned in the token stream are left alone
+	     (this is also true outside of merge mode);
+	  - maps are merged key by key: existing entries not mentioned are left alone,
+	     and values for existing keys are themselves merged into;
+	  - slices are combined according to the `slices` mode;
+	  - arrays are merged into by index (unless `slices` is "replace",
+	     in which case they're zeroed first, as usual);
+	  - values in `interface{}` slots are merged into if they hold a map or
+	     slice of the same shape as the incoming data (as produced by
+	     unmarshalling to `interface{}` in the first place);
+	  - everything else (primitives, transformed types, and explicit nulls)
+	     simply replaces the existing value.
+
+	Merge mode persists across calls to `Bind`.
+*/
+func (d *Unmarshaller) SetMergeMode(enable bool, slices SliceMergeMode) {
+	switch slices {
+	case SliceMergeMode_Replace, SliceMergeMode_Append, SliceMergeMode_ByIndex:
+	default:
+		panic(fmt.Errorf("invalid slice merge mode %q", slices))
+	}
+	d.unmarshalSlab.merge = enable
+	d.unmarshalSlab.sliceMerge = slices
+}
+
+/*
+	Configures the Unmarshaller to accept string tokens when filling in a
+	byte slice, converting them with the given function.  If the function
+	returns an error, the string is rejected as not fitting the field.
+
+	This is for serial formats which have no native bytes type, and so must
+	carry bytes as strings (e.g. base64 in JSON); their unmarshal helpers
+	set this up according to their decode options.
+	Setting nil (the default) accepts only bytes tokens for byte slices.
+*/
+func (d *Unmarshaller) SetBytesFromString(fn func(string) ([]byte, error)) {
+	d.unmarshalSlab.bytesFromString = fn
+}
+
 func (d *Unmarshaller) Bind(v interface{}) error {
 	d.stack = d.stack[0:0]
 	d.unmarshalSlab.rows = d.unmarshalSlab.rows[0:0]
+	d.spent = 0
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		err := ErrInvalidUnmarshalTarget{reflect.TypeOf(v)}
@@ -47,6 +121,8 @@ type Unmarshaller struct {
 	unmarshalSlab unmarshalSlab
 	stack         []UnmarshalMachine
 	step          UnmarshalMachine
+	budget        int64 // Allocation budget; zero for unlimited.
+	spent         int64 // Allocation spent since last bind.
 }
 
 type UnmarshalMachine interface {
@@ -57,6 +133,24 @@ type UnmarshalMachine interface {
 type unmarshalMachineStep func(*Unmarshaller, *unmarshalSlab, *Token) (done bool, err error)
 
 func (d *Unmarshaller) Step(tok *Token) (bool, error) {
+	if d.budget > 0 {
+		d.spent++
+		switch tok.Type {
+		case TString:
+			d.spent += int64(len(tok.Str))
+		case TBytes:
+			d.spent += int64(len(tok.Bytes))
+		}
+		if err := shared.CheckLimit("AllocBudget", d.budget, d.spent); err != nil {
+			return true, err
+		}
+	}
+	return d.stepCurrent(tok)
+}
+
+// Steps the current machine, popping the stack when it's done.
+// (Separate from `Step` so that `Recurse` doesn't charge the budget twice for one token.)
+func (d *Unmarshaller) stepCurrent(tok *Token) (bool, error) {
 	done, err := d.step.Step(d, &d.unmarshalSlab, tok)
 	// If the step errored: out, entirely.
 	if err != nil {
@@ -93,7 +187,6 @@ func (d *Unmarshaller) Step(tok *Token) (bool, error) {
 	that object will be traversed and the stream ready for you to continue.
 */
 func (d *Unmarshaller) Recurse(tok *Token, rv reflect.Value, rt reflect.Type, nextMach UnmarshalMachine) (err error) {
-	//	fmt.Printf(">>> pushing into recursion with %#v\n", nextMach)
 	// Push the current machine onto the stack (we'll resume it when the new one is done),
 	d.stack = append(d.stack, d.step)
 	// Initialize the machine for this new target value.
@@ -103,6 +196,6 @@ func (d *Unmarshaller) Recurse(tok *Token, rv reflect.Value, rt reflect.Type, ne
 	}
 	d.step = nextMach
 	// Immediately make a step (we're still the delegate in charge of someone else's step).
-	_, err = d.Step(tok)
+	_, err = d.stepCurrent(tok)
 	return
 }
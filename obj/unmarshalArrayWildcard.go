@@ -13,6 +13,7 @@ type unmarshalMachineArrayWildcard struct {
 	step      unmarshalMachineStep
 	index     int
 	maxLen    int
+	merge     bool // If true, merge into existing entries rather than zeroing them first.
 }
 
 func (mach *unmarshalMachineArrayWildcard) Reset(slab *unmarshalSlab, rv reflect.Value, rt reflect.Type) error {
@@ -22,6 +23,7 @@ func (mach *unmarshalMachineArrayWildcard) Reset(slab *unmarshalSlab, rv reflect
 	mach.step = mach.step_Initial
 	mach.index = 0
 	mach.maxLen = rt.Len()
+	mach.merge = slab.merge && slab.sliceMerge != SliceMergeMode_Replace
 	return nil
 }
 
@@ -34,22 +36,24 @@ func (mach *unmarshalMachineArrayWildcard) step_Initial(_ *Unmarshaller, slab *u
 	//  (Or, blow up if its a special state that's silly).
 	switch tok.Type {
 	case TMapOpen:
-		return true, ErrMalformedTokenStream{tok.Type, "start of array"}
+		return true, ErrMalformedTokenStream{Got: tok.Type, Expected: "start of array"}
 	case TArrOpen:
 		// Great.  Consumed.
 		mach.step = mach.step_AcceptValue
 		// Initialize the array.  Its length is encoded in its type.
-		mach.target_rv.Set(reflect.Zero(mach.target_rv.Type()))
+		if !mach.merge {
+			mach.target_rv.Set(reflect.Zero(mach.target_rv.Type()))
+		}
 		return false, nil
 	case TMapClose:
-		return true, ErrMalformedTokenStream{tok.Type, "start of array"}
+		return true, ErrMalformedTokenStream{Got: tok.Type, Expected: "start of array"}
 	case TArrClose:
-		return true, ErrMalformedTokenStream{tok.Type, "start of array"}
+		return true, ErrMalformedTokenStream{Got: tok.Type, Expected: "start of array"}
 	case TNull:
 		mach.target_rv.Set(reflect.Zero(mach.target_rv.Type()))
 		return true, nil
 	default:
-		return true, ErrMalformedTokenStream{tok.Type, "start of array"}
+		return true, ErrMalformedTokenStream{Got: tok.Type, Expected: "start of array"}
 	}
 }
 
@@ -60,7 +64,7 @@ func (mach *unmarshalMachineArrayWildcard) step_AcceptValue(driver *Unmarshaller
 	switch tok.Type {
 	case TMapClose:
 		// no special checks for ends of wildcard slice; no such thing as incomplete.
-		return true, ErrMalformedTokenStream{tok.Type, "start of value or end of array"}
+		return true, ErrMalformedTokenStream{Got: tok.Type, Expected: "start of value or end of array"}
 	case TArrClose:
 		// Finishing step: push our current slice ref all the way to original target.
 		return true, nil
@@ -68,7 +72,7 @@ func (mach *unmarshalMachineArrayWildcard) step_AcceptValue(driver *Unmarshaller
 
 	// Return an error if we're about to exceed our length limit.
 	if mach.index >= mach.maxLen {
-		return true, ErrMalformedTokenStream{tok.Type, "end of array (out of space)"}
+		return true, ErrMalformedTokenStream{Got: tok.Type, Expected: "end of array (out of space)"}
 	}
 
 	// Recurse on a handle to the next index.
@@ -2,6 +2,7 @@ package obj
 
 import (
 	"fmt"
+	"math"
 	"reflect"
 
 	. "github.com/polydawn/refmt/tok"
@@ -59,7 +60,7 @@ func (mach *unmarshalMachinePrimitive) Reset(_ *unmarshalSlab, rv reflect.Value,
 	mach.rv = rv
 	return nil
 }
-func (mach *unmarshalMachinePrimitive) Step(_ *Unmarshaller, _ *unmarshalSlab, tok *Token) (done bool, err error) {
+func (mach *unmarshalMachinePrimitive) Step(_ *Unmarshaller, slab *unmarshalSlab, tok *Token) (done bool, err error) {
 	switch mach.kind {
 	case reflect.Bool:
 		switch tok.Type {
@@ -80,10 +81,16 @@ func (mach *unmarshalMachinePrimitive) Step(_ *Unmarshaller, _ *unmarshalSlab, t
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		switch tok.Type {
 		case TInt:
+			if mach.rv.OverflowInt(tok.Int) {
+				return true, ErrUnmarshalTypeCantFit{*tok, mach.rv}
+			}
 			mach.rv.SetInt(tok.Int)
 			return true, nil
 		case TUint:
-			mach.rv.SetInt(int64(tok.Uint)) // todo: overflow check
+			if tok.Uint > math.MaxInt64 || mach.rv.OverflowInt(int64(tok.Uint)) {
+				return true, ErrUnmarshalTypeCantFit{*tok, mach.rv}
+			}
+			mach.rv.SetInt(int64(tok.Uint))
 			return true, nil
 		default:
 			return true, ErrUnmarshalTypeCantFit{*tok, mach.rv}
@@ -91,12 +98,15 @@ func (mach *unmarshalMachinePrimitive) Step(_ *Unmarshaller, _ *unmarshalSlab, t
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
 		switch tok.Type {
 		case TInt:
-			if tok.Int >= 0 {
+			if tok.Int >= 0 && !mach.rv.OverflowUint(uint64(tok.Int)) {
 				mach.rv.SetUint(uint64(tok.Int))
 				return true, nil
 			}
 			return true, ErrUnmarshalTypeCantFit{*tok, mach.rv}
 		case TUint:
+			if mach.rv.OverflowUint(tok.Uint) {
+				return true, ErrUnmarshalTypeCantFit{*tok, mach.rv}
+			}
 			mach.rv.SetUint(tok.Uint)
 			return true, nil
 		default:
@@ -121,6 +131,16 @@ func (mach *unmarshalMachinePrimitive) Step(_ *Unmarshaller, _ *unmarshalSlab, t
 		case TBytes:
 			mach.rv.SetBytes(tok.Bytes)
 			return true, nil
+		case TString:
+			if slab.bytesFromString == nil {
+				return true, ErrUnmarshalTypeCantFit{*tok, mach.rv}
+			}
+			bs, err := slab.bytesFromString(tok.Str)
+			if err != nil {
+				return true, ErrUnmarshalTypeCantFit{*tok, mach.rv}
+			}
+			mach.rv.SetBytes(bs)
+			return true, nil
 		default:
 			return true, ErrUnmarshalTypeCantFit{*tok, mach.rv}
 		}
@@ -140,6 +160,10 @@ func (mach *unmarshalMachinePrimitive) Step(_ *Unmarshaller, _ *unmarshalSlab, t
 			mach.rv.Set(reflect.ValueOf(tok.Float64))
 		case TNull:
 			mach.rv.Set(reflect.ValueOf(nil))
+		case TUndefined:
+			mach.rv.Set(reflect.ValueOf(Undefined{}))
+		case TSimple:
+			mach.rv.Set(reflect.ValueOf(SimpleValue(tok.Uint)))
 		default: // any of the other token types should not have been routed here to begin with.
 			panic(fmt.Errorf("unhandled: %v", mach.kind))
 		}
@@ -148,3 +172,23 @@ func (mach *unmarshalMachinePrimitive) Step(_ *Unmarshaller, _ *unmarshalSlab, t
 		panic(fmt.Errorf("unhandled: %v", mach.kind))
 	}
 }
+
+type unmarshalMachineSimple struct {
+	rv reflect.Value
+}
+
+func (mach *unmarshalMachineSimple) Reset(_ *unmarshalSlab, rv reflect.Value, _ reflect.Type) error {
+	mach.rv = rv
+	return nil
+}
+func (mach *unmarshalMachineSimple) Step(_ *Unmarshaller, _ *unmarshalSlab, tok *Token) (done bool, err error) {
+	switch {
+	case tok.Type == TUndefined && mach.rv.Kind() == reflect.Struct:
+		return true, nil
+	case tok.Type == TSimple && mach.rv.Kind() == reflect.Uint8:
+		mach.rv.SetUint(tok.Uint)
+		return true, nil
+	default:
+		return true, ErrUnmarshalTypeCantFit{*tok, mach.rv}
+	}
+}
@@ -14,7 +14,9 @@ type unmarshalMachineMapStringWildcard struct {
 	key_rv    reflect.Value    // Addressable handle to a slot for keys to unmarshal into.
 	tmp_rv    reflect.Value    // Addressable handle to a slot for values to unmarshal into.
 	step      unmarshalMachineStep
-	haveValue bool // Piece of attendant state to help know we've been through at least one k=v pair so we can post-v store it.
+	haveValue bool                // Piece of attendant state to help know we've been through at least one k=v pair so we can post-v store it.
+	merge     bool                // If true, merge into existing entries rather than rejecting them as repeats.
+	seen      map[string]struct{} // Keys seen so far, when merging (since we can't rely on the map itself to detect repeats).
 }
 
 func (mach *unmarshalMachineMapStringWildcard) Reset(slab *unmarshalSlab, rv reflect.Value, rt reflect.Type) error {
@@ -25,6 +27,8 @@ func (mach *unmarshalMachineMapStringWildcard) Reset(slab *unmarshalSlab, rv ref
 	mach.tmp_rv = reflect.New(mach.value_rt).Elem()
 	mach.step = mach.step_Initial
 	mach.haveValue = false
+	mach.merge = slab.merge
+	mach.seen = nil
 	return nil
 }
 
@@ -46,6 +50,9 @@ func (mach *unmarshalMachineMapStringWildcard) step_Initial(_ *Unmarshaller, _ *
 		if mach.target_rv.IsNil() {
 			mach.target_rv.Set(reflect.MakeMap(mach.target_rv.Type()))
 		}
+		if mach.merge {
+			mach.seen = make(map[string]struct{})
+		}
 		return false, nil
 	case TMapClose:
 		return true, fmt.Errorf("unexpected mapClose; expected start of map")
@@ -89,6 +96,20 @@ func (mach *unmarshalMachineMapStringWildcard) step_AcceptKey(_ *Unmarshaller, _
 }
 
 func (mach *unmarshalMachineMapStringWildcard) mustAcceptKey(key_rv reflect.Value) error {
+	if mach.merge {
+		// Existing entries are fine (we'll merge into them), but repeats within this map are still an error.
+		if _, exists := mach.seen[key_rv.String()]; exists {
+			return fmt.Errorf("repeated key %q", key_rv)
+		}
+		mach.seen[key_rv.String()] = struct{}{}
+		// Start the value slot off with the existing value, so it's merged into.
+		if existing_rv := mach.target_rv.MapIndex(key_rv); existing_rv.IsValid() {
+			mach.tmp_rv.Set(existing_rv)
+		} else {
+			mach.tmp_rv.Set(reflect.Zero(mach.value_rt))
+		}
+		return nil
+	}
 	if exists := mach.target_rv.MapIndex(key_rv).IsValid(); exists {
 		return fmt.Errorf("repeated key %q", key_rv)
 	}
@@ -0,0 +1,125 @@
+package obj
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/polydawn/refmt/obj/atlas"
+	. "github.com/polydawn/refmt/tok"
+)
+
+func TestUnmarshalMerge(t *testing.T) {
+	type tMergeInner struct {
+		A string
+		B string
+	}
+	type tMerge struct {
+		Name  string
+		Inner tMergeInner
+		Tags  []string
+		Attrs map[string]tMergeInner
+	}
+	atl := atlas.MustBuild(
+		atlas.BuildEntry(tMerge{}).StructMap().Autogenerate().Complete(),
+		atlas.BuildEntry(tMergeInner{}).StructMap().Autogenerate().Complete(),
+	)
+	// Runs the tokens through an unmarshaller in merge mode, targetting slot.
+	merge := func(mode SliceMergeMode, slot interface{}, toks ...Token) error {
+		u := NewUnmarshaller(atl)
+		u.SetMergeMode(true, mode)
+		if err := u.Bind(slot); err != nil {
+			return err
+		}
+		for _, tok := range toks {
+			if _, err := u.Step(&tok); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	existing := func() tMerge {
+		return tMerge{
+			Name:  "base",
+			Inner: tMergeInner{A: "a", B: "b"},
+			Tags:  []string{"t1", "t2"},
+			Attrs: map[string]tMergeInner{"k1": {A: "1a", B: "1b"}, "k2": {A: "2a"}},
+		}
+	}
+	layer := []Token{
+		{Type: TMapOpen, Length: 3},
+		TokStr("inner"), {Type: TMapOpen, Length: 1}, TokStr("b"), TokStr("B"), {Type: TMapClose},
+		TokStr("tags"), {Type: TArrOpen, Length: 1}, TokStr("t3"), {Type: TArrClose},
+		TokStr("attrs"), {Type: TMapOpen, Length: 2},
+		/**/ TokStr("k1"), {Type: TMapOpen, Length: 1}, TokStr("a"), TokStr("1A"), {Type: TMapClose},
+		/**/ TokStr("k3"), {Type: TMapOpen, Length: 1}, TokStr("b"), TokStr("3B"), {Type: TMapClose},
+		{Type: TMapClose},
+		{Type: TMapClose},
+	}
+
+	Convey("Unmarshal merge mode:", t, func() {
+		Convey("structs and maps merge deeply, with slices replaced", func() {
+			slot := existing()
+			So(merge(SliceMergeMode_Replace, &slot, layer...), ShouldBeNil)
+			So(slot, ShouldResemble, tMerge{
+				Name:  "base",
+				Inner: tMergeInner{A: "a", B: "B"},
+				Tags:  []string{"t3"},
+				Attrs: map[string]tMergeInner{"k1": {A: "1A", B: "1b"}, "k2": {A: "2a"}, "k3": {B: "3B"}},
+			})
+		})
+		Convey("slices may append", func() {
+			slot := existing()
+			So(merge(SliceMergeMode_Append, &slot, layer...), ShouldBeNil)
+			So(slot.Tags, ShouldResemble, []string{"t1", "t2", "t3"})
+		})
+		Convey("slices may merge by index", func() {
+			slot := existing()
+			So(merge(SliceMergeMode_ByIndex, &slot, layer...), ShouldBeNil)
+			So(slot.Tags, ShouldResemble, []string{"t3", "t2"})
+		})
+		Convey("slices of structs merge by index deeply", func() {
+			slot := []tMergeInner{{A: "a0", B: "b0"}}
+			So(merge(SliceMergeMode_ByIndex, &slot,
+				Token{Type: TArrOpen, Length: 2},
+				Token{Type: TMapOpen, Length: 1}, TokStr("a"), TokStr("A0"), Token{Type: TMapClose},
+				Token{Type: TMapOpen, Length: 1}, TokStr("b"), TokStr("B1"), Token{Type: TMapClose},
+				Token{Type: TArrClose},
+			), ShouldBeNil)
+			So(slot, ShouldResemble, []tMergeInner{{A: "A0", B: "b0"}, {B: "B1"}})
+		})
+		Convey("arrays merge by index", func() {
+			slot := [3]string{"x", "y", "z"}
+			So(merge(SliceMergeMode_Append, &slot,
+				Token{Type: TArrOpen, Length: 1}, TokStr("X"), Token{Type: TArrClose},
+			), ShouldBeNil)
+			So(slot, ShouldResemble, [3]string{"X", "y", "z"})
+		})
+		Convey("wildcards merge deeply", func() {
+			var slot interface{} = map[string]interface{}{
+				"a": "1",
+				"b": map[string]interface{}{"c": "2", "d": "3"},
+				"e": []interface{}{"4"},
+			}
+			So(merge(SliceMergeMode_Append, &slot,
+				Token{Type: TMapOpen, Length: 2},
+				TokStr("b"), Token{Type: TMapOpen, Length: 1}, TokStr("d"), TokStr("D"), Token{Type: TMapClose},
+				TokStr("e"), Token{Type: TArrOpen, Length: 1}, TokStr("5"), Token{Type: TArrClose},
+				Token{Type: TMapClose},
+			), ShouldBeNil)
+			So(slot, ShouldResemble, map[string]interface{}{
+				"a": "1",
+				"b": map[string]interface{}{"c": "2", "d": "D"},
+				"e": []interface{}{"4", "5"},
+			})
+		})
+		Convey("repeated keys within one map are still rejected", func() {
+			slot := map[string]string{"a": "1"}
+			err := merge(SliceMergeMode_Replace, &slot,
+				Token{Type: TMapOpen, Length: 2}, TokStr("a"), TokStr("2"), TokStr("a"), TokStr("3"), Token{Type: TMapClose},
+			)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, `repeated key "a"`)
+		})
+	})
+}
@@ -16,11 +16,17 @@ import (
 type unmarshalSlab struct {
 	atlas atlas.Atlas
 	rows  []unmarshalSlabRow
+
+	merge      bool           // If true, machines should merge into existing values rather than replacing them.
+	sliceMerge SliceMergeMode // How to merge slices, if merging.
+
+	bytesFromString func(string) ([]byte, error) // If set, string tokens are accepted for byte slices, decoded by this.
 }
 
 type unmarshalSlabRow struct {
 	ptrDerefDelegateUnmarshalMachine
 	unmarshalMachinePrimitive
+	unmarshalMachineSimple
 	unmarshalMachineWildcard
 	unmarshalMachineMapStringWildcard
 	unmarshalMachineSliceWildcard
@@ -84,18 +90,21 @@ func _yieldUnmarshalMachinePtr(row *unmarshalSlabRow, atl atlas.Atlas, rt reflec
 		rtid_bytes:
 		row.unmarshalMachinePrimitive.kind = rt.Kind()
 		return &row.unmarshalMachinePrimitive
+	case rtid_undefined, rtid_simpleValue:
+		return &row.unmarshalMachineSimple
 	}
 
 	// Consult atlas second.
 	if entry, ok := atl.Get(rtid); ok {
 		// Switch across which of the union of configurations is applicable.
 		switch {
-		case entry.UnmarshalTransformFunc != nil:
+		case entry.UnmarshalTransformFunc != nil, entry.UnmarshalTaggedTransformFunc != nil:
 			// Return a machine that calls the func(s), then later a real machine.
 			// The entry.UnmarshalTransformTargetType is used to do a recursive lookup.
 			// We can't just call the func here because we're still working off typeinfo
 			// and don't have a real value to transform until later.
 			row.unmarshalMachineTransform.trFunc = entry.UnmarshalTransformFunc
+			row.unmarshalMachineTransform.trTagFunc = entry.UnmarshalTaggedTransformFunc
 			row.unmarshalMachineTransform.recv_rt = entry.UnmarshalTransformTargetType
 			// Pick delegate without growing stack.  (This currently means recursive transform won't fly.)
 			row.unmarshalMachineTransform.delegate = _yieldUnmarshalMachinePtr(row, atl, entry.UnmarshalTransformTargetType)
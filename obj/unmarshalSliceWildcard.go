@@ -12,6 +12,7 @@ type unmarshalMachineSliceWildcard struct {
 	valueMach UnmarshalMachine
 	step      unmarshalMachineStep
 	index     int
+	mode      SliceMergeMode // How to treat existing content; replace unless merging.
 }
 
 func (mach *unmarshalMachineSliceWildcard) Reset(slab *unmarshalSlab, rv reflect.Value, rt reflect.Type) error {
@@ -20,6 +21,10 @@ func (mach *unmarshalMachineSliceWildcard) Reset(slab *unmarshalSlab, rv reflect
 	mach.valueMach = slab.requisitionMachine(mach.value_rt)
 	mach.step = mach.step_Initial
 	mach.index = 0
+	mach.mode = SliceMergeMode_Replace
+	if slab.merge {
+		mach.mode = slab.sliceMerge
+	}
 	return nil
 }
 
@@ -32,22 +37,29 @@ func (mach *unmarshalMachineSliceWildcard) step_Initial(_ *Unmarshaller, slab *u
 	//  (Or, blow up if its a special state that's silly).
 	switch tok.Type {
 	case TMapOpen:
-		return true, ErrMalformedTokenStream{tok.Type, "start of array"}
+		return true, ErrMalformedTokenStream{Got: tok.Type, Expected: "start of array"}
 	case TArrOpen:
 		// Great.  Consumed.
 		mach.step = mach.step_AcceptValue
-		// Initialize the slice.
-		mach.target_rv.Set(reflect.MakeSlice(mach.target_rv.Type(), 0, 0))
+		// Initialize the slice (or, if merging, decide where to start in the existing one).
+		switch mach.mode {
+		case SliceMergeMode_Replace:
+			mach.target_rv.Set(reflect.MakeSlice(mach.target_rv.Type(), 0, 0))
+		case SliceMergeMode_Append:
+			mach.index = mach.target_rv.Len()
+		case SliceMergeMode_ByIndex:
+			// Start from the top; existing entries are recursed into.
+		}
 		return false, nil
 	case TMapClose:
-		return true, ErrMalformedTokenStream{tok.Type, "start of array"}
+		return true, ErrMalformedTokenStream{Got: tok.Type, Expected: "start of array"}
 	case TArrClose:
-		return true, ErrMalformedTokenStream{tok.Type, "start of array"}
+		return true, ErrMalformedTokenStream{Got: tok.Type, Expected: "start of array"}
 	case TNull:
 		mach.target_rv.Set(reflect.Zero(mach.target_rv.Type()))
 		return true, nil
 	default:
-		return true, ErrMalformedTokenStream{tok.Type, "start of array"}
+		return true, ErrMalformedTokenStream{Got: tok.Type, Expected: "start of array"}
 	}
 }
 
@@ -58,7 +70,7 @@ func (mach *unmarshalMachineSliceWildcard) step_AcceptValue(driver *Unmarshaller
 	switch tok.Type {
 	case TMapClose:
 		// no special checks for ends of wildcard slice; no such thing as incomplete.
-		return true, ErrMalformedTokenStream{tok.Type, "start of value or end of array"}
+		return true, ErrMalformedTokenStream{Got: tok.Type, Expected: "start of value or end of array"}
 	case TArrClose:
 		// Finishing step: push our current slice ref all the way to original target.
 		// REVIEW does this even require an action anymore? // *(mach.target) = mach.slice
@@ -67,7 +79,9 @@ func (mach *unmarshalMachineSliceWildcard) step_AcceptValue(driver *Unmarshaller
 
 	// Grow the slice if necessary.
 	// FIXME this is ridiculously inefficient, can do much better, this is placeholder quality
-	mach.target_rv.Set(reflect.Append(mach.target_rv, reflect.Zero(mach.value_rt)))
+	if mach.index >= mach.target_rv.Len() {
+		mach.target_rv.Set(reflect.Append(mach.target_rv, reflect.Zero(mach.value_rt)))
+	}
 
 	// Recurse on a handle to the next index.
 	rv := mach.target_rv.Index(mach.index)
@@ -8,21 +8,41 @@ import (
 )
 
 type unmarshalMachineTransform struct {
-	trFunc   atlas.UnmarshalTransformFunc
-	recv_rt  reflect.Type
-	delegate UnmarshalMachine // machine for handling the recv type, stepped to completion before transform applied.
+	trFunc    atlas.UnmarshalTransformFunc
+	trTagFunc atlas.UnmarshalTaggedTransformFunc // used instead of trFunc if set.
+	recv_rt   reflect.Type
+	delegate  UnmarshalMachine // machine for handling the recv type, stepped to completion before transform applied.
 
 	target_rv reflect.Value // given on Reset, retained until last step, and set into after using trFunc
 	recv_rv   reflect.Value // if set, handle to slot where slice is stored; content must be placed into target at end.
+	first     bool          // true until the first step; the tag on the first token (if any) is ours, and not passed to the delegate.
+	tag       int           // the tag from the first token, or -1.
 }
 
 func (mach *unmarshalMachineTransform) Reset(slab *unmarshalSlab, rv reflect.Value, _ reflect.Type) error {
 	mach.target_rv = rv
 	mach.recv_rv = reflect.New(mach.recv_rt).Elem() // REVIEW: this behavior with ptr vs not for in_rt.  the star-star case is prob not what want.
+	mach.first = true
 	return mach.delegate.Reset(slab, mach.recv_rv, mach.recv_rt)
 }
 
 func (mach *unmarshalMachineTransform) Step(driver *Unmarshaller, slab *unmarshalSlab, tok *Token) (done bool, err error) {
+	// A tag on the first token describes the transformed type, which we've
+	// already been selected to handle; strip it before delegating, so that
+	// e.g. a wildcard delegate doesn't try to demux on it all over again.
+	if mach.first {
+		mach.first = false
+		mach.tag = -1
+		if tok.Tagged {
+			if tag, ok := semanticTag(tok); ok {
+				mach.tag = tag
+			}
+			untagged := *tok
+			untagged.Tagged = false
+			untagged.OuterTags = nil
+			tok = &untagged
+		}
+	}
 	done, err = mach.delegate.Step(driver, slab, tok)
 	if err != nil {
 		return
@@ -31,7 +51,12 @@ func (mach *unmarshalMachineTransform) Step(driver *Unmarshaller, slab *unmarsha
 		return
 	}
 	// on the last step, use transform, and finally set in real target.
-	tr_rv, err := mach.trFunc(mach.recv_rv)
+	var tr_rv reflect.Value
+	if mach.trTagFunc != nil {
+		tr_rv, err = mach.trTagFunc(mach.tag, mach.recv_rv)
+	} else {
+		tr_rv, err = mach.trFunc(mach.recv_rv)
+	}
 	// do attempt the set even if error.  user may appreciate partial progress.
 	mach.target_rv.Set(tr_rv)
 	return true, err
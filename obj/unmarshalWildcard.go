@@ -12,6 +12,7 @@ type unmarshalMachineWildcard struct {
 	target_rt reflect.Type
 	delegate  UnmarshalMachine // actual machine, once we've demuxed with the first token.
 	holder_rv reflect.Value    // if set, handle to slot where slice is stored; content must be placed into target at end.
+	rowMark   int              // if >= 0, the slab's height before the delegate took any rows; restored when it's done.
 }
 
 func (mach *unmarshalMachineWildcard) Reset(_ *unmarshalSlab, rv reflect.Value, rt reflect.Type) error {
@@ -19,6 +20,7 @@ func (mach *unmarshalMachineWildcard) Reset(_ *unmarshalSlab, rv reflect.Value,
 	mach.target_rt = rt
 	mach.delegate = nil
 	mach.holder_rv = reflect.Value{}
+	mach.rowMark = -1
 	return nil
 }
 
@@ -33,6 +35,11 @@ func (mach *unmarshalMachineWildcard) Step(driver *Unmarshaller, slab *unmarshal
 	if !done {
 		return
 	}
+	if mach.rowMark >= 0 {
+		// Give back any rows the delegate (or its own delegates) took.
+		slab.rows = slab.rows[0:mach.rowMark]
+		mach.rowMark = -1
+	}
 	if mach.holder_rv.IsValid() {
 		mach.target_rv.Set(mach.holder_rv)
 	}
@@ -40,16 +47,22 @@ func (mach *unmarshalMachineWildcard) Step(driver *Unmarshaller, slab *unmarshal
 }
 
 func (mach *unmarshalMachineWildcard) prepareDemux(driver *Unmarshaller, slab *unmarshalSlab, tok *Token) (done bool, err error) {
+	// Any rows the delegate takes from the slab (for itself, or for the
+	//  values of a map or slice) are given back when it's done.
+	mach.rowMark = len(slab.rows)
 	// If a "tag" is set in the token, we try to follow that as a hint for
 	//  any specifically customized behaviors for how this should be unmarshalled.
-	if tok.Tagged == true {
-		atlasEntry, exists := slab.atlas.GetEntryByTag(tok.Tag)
+	//  (If there are several, the innermost one is what describes the value.)
+	if tag, ok := semanticTag(tok); ok {
+		atlasEntry, exists := slab.atlas.GetEntryByTag(tag)
 		if !exists {
-			return true, fmt.Errorf("missing an unmarshaller for tag %v", tok.Tag)
+			return true, fmt.Errorf("missing an unmarshaller for tag %v", tag)
 		}
 		value_rt := atlasEntry.Type
 		mach.holder_rv = reflect.New(value_rt).Elem()
-		mach.delegate = _yieldUnmarshalMachinePtr(slab.tip(), slab.atlas, value_rt)
+		// Use a fresh row: the entry may be a transform which delegates to a wildcard again,
+		//  and we mustn't have that one overwrite ourselves.
+		mach.delegate = slab.requisitionMachine(value_rt)
 		if err := mach.delegate.Reset(slab, mach.holder_rv, value_rt); err != nil {
 			return true, err
 		}
@@ -60,6 +73,17 @@ func (mach *unmarshalMachineWildcard) prepareDemux(driver *Unmarshaller, slab *u
 	//  but we may also need to initialize a container type and then hand off.
 	switch tok.Type {
 	case TMapOpen:
+		// If merging, and there's already a map of the kind we'd make, use that.
+		if slab.merge && !mach.target_rv.IsNil() {
+			if child, ok := mach.target_rv.Interface().(map[string]interface{}); ok && child != nil {
+				child_rv := reflect.ValueOf(child)
+				mach.delegate = &slab.tip().unmarshalMachineMapStringWildcard
+				if err := mach.delegate.Reset(slab, child_rv, child_rv.Type()); err != nil {
+					return true, err
+				}
+				return false, nil
+			}
+		}
 		child := make(map[string]interface{})
 		child_rv := reflect.ValueOf(child)
 		mach.target_rv.Set(child_rv)
@@ -81,6 +105,12 @@ func (mach *unmarshalMachineWildcard) prepareDemux(driver *Unmarshaller, slab *u
 		// - https://play.golang.org/p/jV9VFDht6F -- finally getting somewhere good
 
 		holder := make([]interface{}, 0)
+		// If merging, and there's already a slice of the kind we'd make, start with that.
+		if slab.merge && !mach.target_rv.IsNil() {
+			if existing, ok := mach.target_rv.Interface().([]interface{}); ok {
+				holder = existing
+			}
+		}
 		mach.holder_rv = reflect.ValueOf(&holder).Elem()
 		mach.delegate = &slab.tip().unmarshalMachineSliceWildcard
 		if err := mach.delegate.Reset(slab, mach.holder_rv, mach.holder_rv.Type()); err != nil {
@@ -89,10 +119,10 @@ func (mach *unmarshalMachineWildcard) prepareDemux(driver *Unmarshaller, slab *u
 		return false, nil
 
 	case TMapClose:
-		return true, ErrMalformedTokenStream{tok.Type, "start of value"}
+		return true, ErrMalformedTokenStream{Got: tok.Type, Expected: "start of value"}
 
 	case TArrClose:
-		return true, ErrMalformedTokenStream{tok.Type, "start of value"}
+		return true, ErrMalformedTokenStream{Got: tok.Type, Expected: "start of value"}
 
 	case TNull:
 		mach.target_rv.Set(reflect.Zero(mach.target_rt))
@@ -110,3 +140,27 @@ func (mach *unmarshalMachineWildcard) prepareDemux(driver *Unmarshaller, slab *u
 		return delegateMach.Step(driver, slab, tok)
 	}
 }
+
+// The self-describe tag (RFC 8949 section 3.4.6) merely marks data as CBOR;
+// it says nothing about the value it's wrapped around.
+const tagSelfDescribe = 55799
+
+// Returns the tag which describes what a token's value is: the innermost,
+// skipping any self-describe tags.  Returns false if there's no such tag.
+// (Tags too large for an int are returned as -1, which no atlas entry has.)
+func semanticTag(tok *Token) (int, bool) {
+	if !tok.Tagged {
+		return 0, false
+	}
+	tag := tok.Tag
+	for i := len(tok.OuterTags); tag == tagSelfDescribe; i-- {
+		if i == 0 {
+			return 0, false
+		}
+		tag = tok.OuterTags[i-1]
+	}
+	if tag > uint64(^uint(0)>>1) {
+		return -1, true
+	}
+	return int(tag), true
+}
@@ -0,0 +1,51 @@
+package obj
+
+import (
+	"testing"
+
+	"github.com/polydawn/refmt/obj/atlas"
+	"github.com/polydawn/refmt/shared"
+	. "github.com/polydawn/refmt/tok"
+)
+
+func TestUnmarshalWildcardTagsReleaseRows(t *testing.T) {
+	atl := atlas.MustBuild(
+		atlas.BuildEntry(tObjStr{}).UseTag(50).Transform().
+			TransformUnmarshal(atlas.MakeUnmarshalTransformFunc(
+				func(x string) (tObjStr, error) {
+					return tObjStr{x}, nil
+				})).
+			Complete(),
+	)
+	// An array of n strings, then n maps of one string each; all tagged, or none.
+	build := func(n int, tagged bool) *Buffer {
+		str := Token{Type: TString, Str: "v", Tagged: tagged, Tag: 50}
+		buf := &Buffer{Tokens: []Token{{Type: TArrOpen, Length: 2 * n}}}
+		for i := 0; i < n; i++ {
+			buf.Tokens = append(buf.Tokens, str)
+		}
+		for i := 0; i < n; i++ {
+			buf.Tokens = append(buf.Tokens, Token{Type: TMapOpen, Length: 1}, TokStr("k"), str, Token{Type: TMapClose})
+		}
+		buf.Tokens = append(buf.Tokens, Token{Type: TArrClose})
+		return buf
+	}
+	rowsUsed := func(buf *Buffer) int {
+		u := NewUnmarshaller(atl)
+		var v interface{}
+		if err := u.Bind(&v); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if err := (shared.TokenPump{TokenSource: buf.Source(), TokenSink: u}).Run(); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		return len(u.unmarshalSlab.rows)
+	}
+	plain, tagged := rowsUsed(build(50, false)), rowsUsed(build(50, true))
+	if tagged > plain {
+		t.Errorf("tagged values should use no more slab rows than plain ones; got %d, vs %d", tagged, plain)
+	}
+	if more := rowsUsed(build(100, true)); more != tagged {
+		t.Errorf("slab rows should not grow with the number of values; got %d for 50, %d for 100", tagged, more)
+	}
+}
@@ -15,6 +15,9 @@ var (
 	wordTrue       = []byte("true")
 	wordFalse      = []byte("false")
 	wordNull       = []byte("null")
+	wordUndefined  = []byte("undefined")
+	wordSimplePt1  = bcat(decoType, []byte("simple"), decoBrack, []byte("("), decoTypeParam)
+	wordSimplePt2  = bcat(decoBrack, []byte(")"), decoOff)
 	wordArrOpenPt1 = bcat(decoType, []byte("Array"), decoBrack, []byte("<len:"), decoTypeParam)
 	wordArrOpenPt2 = bcat(decoBrack, []byte("> ["), decoOff)
 	wordArrClose   = bcat(decoBrack, []byte("]"), decoOff)
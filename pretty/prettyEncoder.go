@@ -6,6 +6,7 @@ import (
 	"io"
 	"strconv"
 
+	"github.com/polydawn/refmt/shared"
 	. "github.com/polydawn/refmt/tok"
 )
 
@@ -61,9 +62,9 @@ func (d *Encoder) Step(tok *Token) (done bool, err error) {
 			d.emitArrOpen(tok)
 			return false, nil
 		case TMapClose:
-			return true, fmt.Errorf("unexpected mapClose; expected start of value")
+			return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "start of value"}
 		case TArrClose:
-			return true, fmt.Errorf("unexpected arrClose; expected start of value")
+			return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "start of value"}
 		default:
 			d.emitValue(tok)
 			d.wr.Write(wordBreak)
@@ -72,14 +73,14 @@ func (d *Encoder) Step(tok *Token) (done bool, err error) {
 	case phase_mapExpectKeyOrEnd:
 		switch tok.Type {
 		case TMapOpen:
-			return true, fmt.Errorf("unexpected mapOpen; expected start of key or end of map")
+			return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "map key or end of map"}
 		case TArrOpen:
-			return true, fmt.Errorf("unexpected arrOpen; expected start of key or end of map")
+			return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "map key or end of map"}
 		case TMapClose:
 			d.emitMapClose(tok)
 			return d.popPhase()
 		case TArrClose:
-			return true, fmt.Errorf("unexpected arrClose; expected start of key or end of map")
+			return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "map key or end of map"}
 		default:
 			switch tok.Type {
 			case TString, TInt, TUint:
@@ -89,7 +90,7 @@ func (d *Encoder) Step(tok *Token) (done bool, err error) {
 				d.current = phase_mapExpectValue
 				return false, nil
 			default:
-				return true, fmt.Errorf("unexpected token of type %T; expected map key", *tok)
+				return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "map key or end of map"}
 			}
 		}
 	case phase_mapExpectValue:
@@ -103,9 +104,9 @@ func (d *Encoder) Step(tok *Token) (done bool, err error) {
 			d.emitArrOpen(tok)
 			return false, nil
 		case TMapClose:
-			return true, fmt.Errorf("unexpected mapClose; expected start of value")
+			return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "start of value"}
 		case TArrClose:
-			return true, fmt.Errorf("unexpected arrClose; expected start of value")
+			return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "start of value"}
 		default:
 			d.current = phase_mapExpectKeyOrEnd
 			d.emitValue(tok)
@@ -123,7 +124,7 @@ func (d *Encoder) Step(tok *Token) (done bool, err error) {
 			d.emitArrOpen(tok)
 			return false, nil
 		case TMapClose:
-			return true, fmt.Errorf("unexpected mapClose; expected start of value or end of array")
+			return true, shared.ErrMalformedTokenStream{Got: tok.Type, Expected: "start of value or end of array"}
 		case TArrClose:
 			d.emitArrClose(tok)
 			return d.popPhase()
@@ -157,12 +158,24 @@ func (d *Encoder) popPhase() (bool, error) {
 	return false, nil
 }
 
-func (d *Encoder) emitMapOpen(tok *Token) {
-	if tok.Tagged {
-		d.wr.Write(wordTag)
-		d.wr.Write([]byte(strconv.Itoa(tok.Tag)))
-		d.wr.Write(wordTagClose)
+func (d *Encoder) emitTags(tok *Token) {
+	if !tok.Tagged {
+		return
+	}
+	for _, tag := range tok.OuterTags {
+		d.emitTag(tag)
 	}
+	d.emitTag(tok.Tag)
+}
+
+func (d *Encoder) emitTag(tag uint64) {
+	d.wr.Write(wordTag)
+	d.wr.Write(strconv.AppendUint(d.scratch[:0], tag, 10))
+	d.wr.Write(wordTagClose)
+}
+
+func (d *Encoder) emitMapOpen(tok *Token) {
+	d.emitTags(tok)
 	d.wr.Write(wordMapOpenPt1)
 	if tok.Length < 0 {
 		d.wr.Write(wordUnknownLen)
@@ -180,11 +193,7 @@ func (d *Encoder) emitMapClose(tok *Token) {
 }
 
 func (d *Encoder) emitArrOpen(tok *Token) {
-	if tok.Tagged {
-		d.wr.Write(wordTag)
-		d.wr.Write([]byte(strconv.Itoa(tok.Tag)))
-		d.wr.Write(wordTagClose)
-	}
+	d.emitTags(tok)
 	d.wr.Write(wordArrOpenPt1)
 	if tok.Length < 0 {
 		d.wr.Write(wordUnknownLen)
@@ -202,14 +211,16 @@ func (d *Encoder) emitArrClose(tok *Token) {
 }
 
 func (d *Encoder) emitValue(tok *Token) {
-	if tok.Tagged {
-		d.wr.Write(wordTag)
-		d.wr.Write([]byte(strconv.Itoa(tok.Tag)))
-		d.wr.Write(wordTagClose)
-	}
+	d.emitTags(tok)
 	switch tok.Type {
 	case TNull:
 		d.wr.Write(wordNull)
+	case TUndefined:
+		d.wr.Write(wordUndefined)
+	case TSimple:
+		d.wr.Write(wordSimplePt1)
+		d.wr.Write(strconv.AppendUint(d.scratch[:0], tok.Uint, 10))
+		d.wr.Write(wordSimplePt2)
 	case TString:
 		d.emitString(tok.Str)
 	case TBytes:
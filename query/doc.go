@@ -0,0 +1,11 @@
+/*
+	Package query picks parts out of token streams, without unmarshalling
+	the whole document.
+
+	Paths into a document are given as JSON Pointers (RFC 6901); see `Pointer`.
+	An `Extractor` wraps any TokenSource -- a decoder for any codec, or an
+	`obj.Marshaller` -- and yields only the tokens of the value at a pointer,
+	so it can be fed into any TokenSink: an encoder, or an `obj.Unmarshaller`
+	to get just that part of the document as a Go value.
+*/
+package query
@@ -0,0 +1,146 @@
+package query
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/polydawn/refmt/shared"
+	. "github.com/polydawn/refmt/tok"
+)
+
+// Error returned by an Extractor when there's no value at its pointer.
+type ErrNotFound struct {
+	Pointer string // The pointer we were looking for.
+	Reason  string // Where the search stopped, and why.
+}
+
+func (e ErrNotFound) Error() string {
+	return fmt.Sprintf("query: nothing at %q: %s", e.Pointer, e.Reason)
+}
+
+/*
+	Extractor is a TokenSource that yields only the value at a Pointer
+	within the document read from another TokenSource.
+
+	On the first step, it reads (and discards) tokens from the source until
+	it finds the start of the value, and yields it; it's done when that value
+	is.  Whatever comes after the value in the source is never read.
+	Everything skipped is stepped over token by token, so skipping
+	allocates nothing beyond what the source itself does.
+
+	If there's no value at the pointer, stepping returns `ErrNotFound`.
+*/
+type Extractor struct {
+	src     shared.TokenSource
+	pointer Pointer
+
+	found   bool // Set once we've found the value, and are passing it through.
+	depth   int  // Depth within the found value.
+	srcDone bool // Set once the source has said it's done.
+}
+
+func NewExtractor(src shared.TokenSource, p Pointer) *Extractor {
+	return &Extractor{src: src, pointer: p}
+}
+
+func (x *Extractor) Step(tokenSlot *Token) (done bool, err error) {
+	if x.found {
+		err = x.read(tokenSlot)
+	} else {
+		err = x.seek(tokenSlot)
+		x.found = true
+	}
+	if err != nil {
+		return true, err
+	}
+	switch tokenSlot.Type {
+	case TMapOpen, TArrOpen:
+		x.depth++
+	case TMapClose, TArrClose:
+		x.depth--
+	}
+	return x.depth == 0, nil
+}
+
+// Read one token from the source.
+func (x *Extractor) read(tokenSlot *Token) (err error) {
+	if x.srcDone {
+		return io.ErrUnexpectedEOF
+	}
+	x.srcDone, err = x.src.Step(tokenSlot)
+	return err
+}
+
+// Read through the source until tokenSlot holds the first token of the value we want.
+func (x *Extractor) seek(tokenSlot *Token) error {
+	if err := x.read(tokenSlot); err != nil {
+		return err
+	}
+	for i, step := range x.pointer {
+		switch tokenSlot.Type {
+		case TMapOpen:
+			for {
+				if err := x.read(tokenSlot); err != nil {
+					return err
+				}
+				if tokenSlot.Type == TMapClose {
+					return x.errNotFound(fmt.Sprintf("no key %q in map at %q", step, x.pointer[:i]))
+				}
+				match := KeyStep(tokenSlot) == step
+				if err := x.read(tokenSlot); err != nil {
+					return err
+				}
+				if match {
+					break
+				}
+				if err := x.skip(tokenSlot); err != nil {
+					return err
+				}
+			}
+		case TArrOpen:
+			idx := arrayIndex(step)
+			if idx < 0 {
+				return x.errNotFound(fmt.Sprintf("%q is not an index, for array at %q", step, x.pointer[:i]))
+			}
+			for n := 0; ; n++ {
+				if err := x.read(tokenSlot); err != nil {
+					return err
+				}
+				if tokenSlot.Type == TArrClose {
+					return x.errNotFound(fmt.Sprintf("index %d out of range for array at %q", idx, x.pointer[:i]))
+				}
+				if n == idx {
+					break
+				}
+				if err := x.skip(tokenSlot); err != nil {
+					return err
+				}
+			}
+		default:
+			return x.errNotFound(fmt.Sprintf("found %s at %q, not a map or array", tokenSlot.Type, x.pointer[:i]))
+		}
+	}
+	return nil
+}
+
+// Skip the rest of a value, given its first token.
+func (x *Extractor) skip(tokenSlot *Token) error {
+	for depth := 0; ; {
+		switch tokenSlot.Type {
+		case TMapOpen, TArrOpen:
+			depth++
+		case TMapClose, TArrClose:
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+		if err := x.read(tokenSlot); err != nil {
+			return err
+		}
+	}
+}
+
+func (x *Extractor) errNotFound(reason string) error {
+	return ErrNotFound{x.pointer.String(), reason}
+}
@@ -0,0 +1,113 @@
+package query
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/polydawn/refmt/tok"
+)
+
+/*
+	Pointer is a parsed JSON Pointer (RFC 6901): the sequence of map keys
+	and array indexes leading from the root of a document to a value within it,
+	with the `~0` and `~1` escapes already undone.
+
+	The empty Pointer refers to the whole document.
+
+	A step matches a map key if the key is a string equal to it, or an
+	integer (as in CBOR) whose decimal form is equal to it.  A step matches
+	an array index if it's the index in decimal, with no leading zeros.
+	(The pointer `-`, which RFC 6901 reserves for the position after the
+	last entry of an array, never matches anything.)
+*/
+type Pointer []string
+
+// Error returned when parsing a string that isn't a valid JSON Pointer.
+type ErrInvalidPointer struct {
+	Pointer string
+	Reason  string
+}
+
+func (e ErrInvalidPointer) Error() string {
+	return "invalid json pointer " + strconv.Quote(e.Pointer) + ": " + e.Reason
+}
+
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// ParsePointer parses the string form of a JSON Pointer, e.g. `/a/b~1c/0`.
+func ParsePointer(s string) (Pointer, error) {
+	if s == "" {
+		return Pointer{}, nil
+	}
+	if s[0] != '/' {
+		return nil, ErrInvalidPointer{s, "must be empty or start with '/'"}
+	}
+	p := strings.Split(s[1:], "/")
+	for i, step := range p {
+		for j := 0; j < len(step); j++ {
+			if step[j] != '~' {
+				continue
+			}
+			if j+1 == len(step) || (step[j+1] != '0' && step[j+1] != '1') {
+				return nil, ErrInvalidPointer{s, "'~' must be followed by '0' or '1'"}
+			}
+			j++
+		}
+		p[i] = pointerUnescaper.Replace(step)
+	}
+	return p, nil
+}
+
+// MustParsePointer is ParsePointer, but panics on error.
+// It's meant for pointers written in source code.
+func MustParsePointer(s string) Pointer {
+	p, err := ParsePointer(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String returns the string form of the pointer, with `~` and `/` escaped.
+func (p Pointer) String() string {
+	var sb strings.Builder
+	for _, step := range p {
+		sb.WriteByte('/')
+		sb.WriteString(pointerEscaper.Replace(step))
+	}
+	return sb.String()
+}
+
+// KeyStep returns the step of a Pointer which a map key token matches:
+// a string key as it is, or an integer key in decimal.
+// (Other tokens can't be map keys; for them it returns "".)
+func KeyStep(key *tok.Token) string {
+	switch key.Type {
+	case tok.TString:
+		return key.Str
+	case tok.TInt:
+		return strconv.FormatInt(key.Int, 10)
+	case tok.TUint:
+		return strconv.FormatUint(key.Uint, 10)
+	default:
+		return ""
+	}
+}
+
+// Returns the array index a step of a pointer refers to, or -1 if it isn't one.
+func arrayIndex(step string) int {
+	if step == "" || (step[0] == '0' && len(step) > 1) {
+		return -1
+	}
+	for _, c := range step {
+		if c < '0' || c > '9' {
+			return -1
+		}
+	}
+	i, err := strconv.Atoi(step)
+	if err != nil {
+		return -1
+	}
+	return i
+}
@@ -0,0 +1,130 @@
+package query
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/polydawn/refmt/cbor"
+	"github.com/polydawn/refmt/json"
+	"github.com/polydawn/refmt/obj"
+	"github.com/polydawn/refmt/obj/atlas"
+	"github.com/polydawn/refmt/shared"
+	"github.com/polydawn/refmt/tok"
+)
+
+func TestParsePointer(t *testing.T) {
+	Convey("Pointers parse and print", t, func() {
+		for _, tr := range []struct {
+			serial string
+			expect Pointer
+		}{
+			{``, Pointer{}},
+			{`/`, Pointer{""}},
+			{`/a/0`, Pointer{"a", "0"}},
+			{`/a~1b/m~0n/~01`, Pointer{"a/b", "m~n", "~1"}},
+			{`//x/`, Pointer{"", "x", ""}},
+		} {
+			p, err := ParsePointer(tr.serial)
+			So(err, ShouldBeNil)
+			So(p, ShouldResemble, tr.expect)
+			So(p.String(), ShouldEqual, tr.serial)
+		}
+	})
+	Convey("Invalid pointers are rejected", t, func() {
+		_, err := ParsePointer(`a/b`)
+		So(err, ShouldResemble, ErrInvalidPointer{`a/b`, "must be empty or start with '/'"})
+		_, err = ParsePointer(`/a~2`)
+		So(err, ShouldResemble, ErrInvalidPointer{`/a~2`, "'~' must be followed by '0' or '1'"})
+		_, err = ParsePointer(`/a~`)
+		So(err, ShouldResemble, ErrInvalidPointer{`/a~`, "'~' must be followed by '0' or '1'"})
+	})
+	Convey("Map keys become steps", t, func() {
+		So(KeyStep(&tok.Token{Type: tok.TString, Str: "a/b"}), ShouldEqual, "a/b")
+		So(KeyStep(&tok.Token{Type: tok.TInt, Int: -4}), ShouldEqual, "-4")
+		So(KeyStep(&tok.Token{Type: tok.TUint, Uint: 18446744073709551615}), ShouldEqual, "18446744073709551615")
+		So(KeyStep(&tok.Token{Type: tok.TBool, Bool: true}), ShouldEqual, "")
+	})
+}
+
+const queryFixture = `{
+	"skip": {"deep": [1, [2, {"x": 3}], {}]},
+	"items": [
+		{"name": "zero", "tags": ["a"]},
+		{"name": "one", "tags": ["b", "c"]}
+	],
+	"a/b": {"m~n": "escaped"},
+	"": "empty key"
+}`
+
+// Extract the value at `pointer` in the fixture, re-encoded as json.
+func extractJson(pointer string) (string, error) {
+	var buf bytes.Buffer
+	err := shared.TokenPump{
+		TokenSource: NewExtractor(json.NewDecoder(strings.NewReader(queryFixture)), MustParsePointer(pointer)),
+		TokenSink:   json.NewEncoder(&buf),
+	}.Run()
+	return buf.String(), err
+}
+
+func TestExtractor(t *testing.T) {
+	Convey("Extracting values", t, func() {
+		for _, tr := range []struct {
+			pointer string
+			expect  string
+		}{
+			{``, `{"skip":{"deep":[1,[2,{"x":3}],{}]},"items":[{"name":"zero","tags":["a"]},{"name":"one","tags":["b","c"]}],"a/b":{"m~n":"escaped"},"":"empty key"}`},
+			{`/items`, `[{"name":"zero","tags":["a"]},{"name":"one","tags":["b","c"]}]`},
+			{`/items/1`, `{"name":"one","tags":["b","c"]}`},
+			{`/items/1/tags/1`, `"c"`},
+			{`/skip/deep/1/1/x`, `3`},
+			{`/skip/deep/2`, `{}`},
+			{`/a~1b/m~0n`, `"escaped"`},
+			{`/`, `"empty key"`},
+		} {
+			got, err := extractJson(tr.pointer)
+			So(err, ShouldBeNil)
+			So(got, ShouldEqual, tr.expect)
+		}
+	})
+	Convey("Missing values", t, func() {
+		for _, tr := range []struct {
+			pointer string
+			reason  string
+		}{
+			{`/nope`, `no key "nope" in map at ""`},
+			{`/items/2`, `index 2 out of range for array at "/items"`},
+			{`/items/01`, `"01" is not an index, for array at "/items"`},
+			{`/items/-`, `"-" is not an index, for array at "/items"`},
+			{`/items/0/name/x`, `found string at "/items/0/name", not a map or array`},
+		} {
+			_, err := extractJson(tr.pointer)
+			So(err, ShouldResemble, ErrNotFound{tr.pointer, tr.reason})
+		}
+	})
+	Convey("Extracting into an Unmarshaller", t, func() {
+		var tags []string
+		u := obj.NewUnmarshaller(atlas.MustBuild())
+		So(u.Bind(&tags), ShouldBeNil)
+		err := shared.TokenPump{
+			TokenSource: NewExtractor(json.NewDecoder(strings.NewReader(queryFixture)), MustParsePointer(`/items/1/tags`)),
+			TokenSink:   u,
+		}.Run()
+		So(err, ShouldBeNil)
+		So(tags, ShouldResemble, []string{"b", "c"})
+	})
+	Convey("Extracting from cbor, with integer map keys", t, func() {
+		serial := []byte{0xa2, 0x01, 0x63, 'o', 'n', 'e', 0x02, 0x82, 0x61, 'x', 0x61, 'y'} // {1: "one", 2: ["x", "y"]}
+		var s string
+		u := obj.NewUnmarshaller(atlas.MustBuild())
+		So(u.Bind(&s), ShouldBeNil)
+		err := shared.TokenPump{
+			TokenSource: NewExtractor(cbor.NewDecoder(bytes.NewReader(serial)), MustParsePointer(`/2/1`)),
+			TokenSink:   u,
+		}.Run()
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "y")
+	})
+}
@@ -8,7 +8,9 @@ import (
 	"github.com/polydawn/refmt"
 	"github.com/polydawn/refmt/cbor"
 	"github.com/polydawn/refmt/json"
+	"github.com/polydawn/refmt/obj"
 	"github.com/polydawn/refmt/obj/atlas"
+	"github.com/polydawn/refmt/shared"
 )
 
 func TestRoundTrip(t *testing.T) {
@@ -79,6 +81,26 @@ func TestRoundTrip(t *testing.T) {
 					Complete()),
 		)
 	})
+	t.Run("cbor undefined and simple values", func(t *testing.T) {
+		roundTrip(t,
+			[]interface{}{obj.Undefined{}, obj.SimpleValue(16), nil, obj.SimpleValue(255)},
+			cbor.EncodeOptions{}, cbor.DecodeOptions{},
+			atlas.MustBuild(),
+		)
+	})
+	t.Run("cbor shortest floats", func(t *testing.T) {
+		type Telemetry struct {
+			A float32
+			B float32
+			C float64
+		}
+		roundTrip(t,
+			Telemetry{1.5, 0.1, 0.1},
+			cbor.EncodeOptions{FloatMode: cbor.FloatMode_Shortest}, cbor.DecodeOptions{},
+			atlas.MustBuild(
+				atlas.BuildEntry(Telemetry{}).StructMap().Autogenerate().Complete()),
+		)
+	})
 }
 
 func testRoundTripAllEncodings(
@@ -131,3 +153,54 @@ func roundTrip(
 	}
 	t.Logf("%#v == %q", value, str1)
 }
+
+func TestSelfDescribedCbor(t *testing.T) {
+	type Taggery string
+	atl := atlas.MustBuild(
+		atlas.BuildEntry(Taggery("")).UseTag(54).Transform().
+			TransformMarshal(atlas.MakeMarshalTransformFunc(
+				func(x Taggery) (string, error) {
+					return string(x), nil
+				})).
+			TransformUnmarshal(atlas.MakeUnmarshalTransformFunc(
+				func(x string) (Taggery, error) {
+					return Taggery(x), nil
+				})).
+			Complete())
+	// tag(55799, tag(54, "v")): the self-describe tag should be looked past.
+	serial := []byte{0xd9, 0xd9, 0xf7, 0xd8, 54, 0x61, 'v'}
+	var slot interface{}
+	if err := refmt.UnmarshalAtlased(cbor.DecodeOptions{}, serial, &slot, atl); err != nil {
+		t.Fatalf("failed decoding: %s", err)
+	}
+	if slot != Taggery("v") {
+		t.Errorf("expected %#v, got %#v", Taggery("v"), slot)
+	}
+}
+
+func TestCborJsonBytesRoundTrip(t *testing.T) {
+	// {"a": h'00ff', "b": [h'', "x"]}
+	serial := []byte{0xa2, 0x61, 'a', 0x42, 0x00, 0xff, 0x61, 'b', 0x82, 0x40, 0x61, 'x'}
+	var jsonBuf bytes.Buffer
+	err := shared.TokenPump{
+		TokenSource: cbor.NewDecoder(bytes.NewReader(serial)),
+		TokenSink:   json.NewEncoderWithOptions(json.EncodeOptions{Bytes: json.BytesMode_DagJSON}, &jsonBuf),
+	}.Run()
+	if err != nil {
+		t.Fatalf("failed transcoding to json: %s", err)
+	}
+	if s := jsonBuf.String(); s != `{"a":{"/":{"bytes":"AP8"}},"b":[{"/":{"bytes":""}},"x"]}` {
+		t.Errorf("unexpected json: %s", s)
+	}
+	var cborBuf bytes.Buffer
+	err = shared.TokenPump{
+		TokenSource: json.NewDecoderWithOptions(json.DecodeOptions{Bytes: json.BytesMode_DagJSON}, &jsonBuf),
+		TokenSink:   cbor.NewEncoderWithOptions(cbor.EncodeOptions{Deterministic: true}, &cborBuf), // JSON has no lengths; this puts them back.
+	}.Run()
+	if err != nil {
+		t.Fatalf("failed transcoding back to cbor: %s", err)
+	}
+	if !bytes.Equal(cborBuf.Bytes(), serial) {
+		t.Errorf("round trip not lossless: %x != %x", cborBuf.Bytes(), serial)
+	}
+}
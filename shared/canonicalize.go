@@ -0,0 +1,321 @@
+package shared
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"unsafe"
+
+	. "github.com/polydawn/refmt/tok"
+)
+
+// A type to enumerate map key orders.
+// These are the same as the map orders of `atlas.KeySortMode`,
+// and the atlas package's constants can be used here too.
+type KeySortMode string
+
+const (
+	KeySortMode_Default = "default" // Same as lexical.
+	KeySortMode_Lexical = "lexical" // Integer keys first, in numeric order; then string keys, in bytewise order.
+	KeySortMode_RFC7049 = "rfc7049" // "Canonical" as proposed by rfc7049 § 3.9 (shorter encoded keys sort to top).
+	KeySortMode_RFC8949 = "rfc8949" // "Deterministic" as in rfc8949 § 4.2.1 (bytewise order of the encoded keys).
+)
+
+type CanonicalizeOptions struct {
+	// How to order map keys.
+	//
+	// `KeySortMode_Default` (or empty) and `KeySortMode_Lexical` put
+	// integer keys first, in numeric order, then string keys, in bytewise order.
+	// `KeySortMode_RFC7049` and `KeySortMode_RFC8949` order keys as the
+	// canonical forms of those specs do, by the cbor encoding of each key.
+	KeySortMode KeySortMode
+
+	// Maximum memory, in bytes, to spend buffering any one value.
+	// The count is approximate: each token costs the size of a Token,
+	// plus the length of any string or bytes in it.
+	// Zero means unlimited.  Exceeding the limit halts with a
+	// `ErrLimitExceeded` error.
+	MaxBufferBytes int64
+}
+
+/*
+	Canonicalizer is a TokenSink which puts a token stream into a canonical
+	form before passing it on to another TokenSink: map entries are sorted
+	by key, every map and array has a definite Length, and any Width is
+	cleared, so the sink uses its preferred encodings.
+
+	For example, json decoders report every map and array with a Length
+	of -1, and keys in the order they appear; a Canonicalizer in front of
+	a cbor encoder makes it emit definite-length items, with sorted keys.
+
+	Each top-level map or array is buffered until it's complete, then all
+	passed on at once.  (Top-level scalars are passed on right away.)
+	The stream is checked with a Validator as it arrives; maps with
+	duplicate keys are also an error (`ErrDuplicateKey`), since they have
+	no canonical order.
+*/
+type Canonicalizer struct {
+	cfg   CanonicalizeOptions
+	sink  TokenSink
+	v     Validator
+	buf   []Token // Tokens of the top-level map or array so far.
+	ends  []int   // For each map or array open in `buf`: the index of its close.
+	stack []int   // Index in `buf` of each map or array still open.
+	count []int   // For each map or array still open: the number of keys and values (or entries) so far.
+	spent int64   // Approximate bytes used by `buf` and `ends`.
+}
+
+// ErrDuplicateKey is the error returned by a Canonicalizer for a map
+// which has the same key more than once.
+type ErrDuplicateKey struct {
+	Key Token // The repeated key.
+}
+
+func (e ErrDuplicateKey) Error() string {
+	return fmt.Sprintf("canonicalize: duplicate map key %s", e.Key)
+}
+
+type canonicalKey struct {
+	idx int    // Index of the key in `buf`.
+	enc []byte // The key's cbor encoding.
+}
+
+// Approximate memory cost of each buffered token (a Token, and its entry in `ends`).
+const tokenCost = int64(unsafe.Sizeof(Token{}) + unsafe.Sizeof(int(0)))
+
+func NewCanonicalizer(cfg CanonicalizeOptions, sink TokenSink) *Canonicalizer {
+	switch cfg.KeySortMode {
+	case "":
+		cfg.KeySortMode = KeySortMode_Default
+	case KeySortMode_Default, KeySortMode_Lexical, KeySortMode_RFC7049, KeySortMode_RFC8949:
+	default:
+		panic(fmt.Errorf("invalid key sort mode %q", cfg.KeySortMode))
+	}
+	return &Canonicalizer{cfg: cfg, sink: sink}
+}
+
+// Reset discards anything buffered, to start on a new value.
+// (The sink itself must be reset separately.)
+func (c *Canonicalizer) Reset() {
+	c.v.Reset()
+	c.buf = c.buf[0:0]
+	c.ends = c.ends[0:0]
+	c.stack = c.stack[0:0]
+	c.count = c.count[0:0]
+	c.spent = 0
+}
+
+func (c *Canonicalizer) Step(tok *Token) (done bool, err error) {
+	if done, err = c.v.Step(tok); err != nil {
+		return true, err
+	}
+	// Scalars at the top level go straight out.
+	if len(c.buf) == 0 && tok.Type != TMapOpen && tok.Type != TArrOpen {
+		if tok.Width != 0 {
+			t := *tok
+			t.Width = 0
+			return c.sink.Step(&t)
+		}
+		return c.sink.Step(tok)
+	}
+	// Anything else is buffered until the top-level value is complete.
+	c.spent += tokenCost + int64(len(tok.Str)+len(tok.Bytes)+8*len(tok.OuterTags))
+	if err := CheckLimit("MaxBufferBytes", c.cfg.MaxBufferBytes, c.spent); err != nil {
+		return true, err
+	}
+	i := len(c.buf)
+	c.buf = append(c.buf, *tok)
+	t := &c.buf[i]
+	t.Width = 0
+	if t.Bytes != nil {
+		t.Bytes = append([]byte(nil), t.Bytes...)
+	}
+	if !t.Tagged {
+		t.OuterTags = nil // Meaningless without a tag; don't keep a reused slot's leftovers.
+	} else if t.OuterTags != nil {
+		t.OuterTags = append([]uint64(nil), t.OuterTags...)
+	}
+	c.ends = append(c.ends, -1)
+	switch t.Type {
+	case TMapClose, TArrClose:
+		n := len(c.stack) - 1
+		open := c.stack[n]
+		c.ends[open] = i
+		c.buf[open].Length = c.count[n]
+		if t.Type == TMapClose {
+			c.buf[open].Length /= 2
+		}
+		c.stack, c.count = c.stack[:n], c.count[:n]
+	default:
+		if n := len(c.stack); n > 0 {
+			c.count[n-1]++
+		}
+		if t.Type == TMapOpen || t.Type == TArrOpen {
+			c.stack = append(c.stack, i)
+			c.count = append(c.count, 0)
+		}
+	}
+	if !done {
+		return false, nil
+	}
+	err = c.emitValue(0)
+	c.buf = c.buf[0:0]
+	c.ends = c.ends[0:0]
+	c.spent = 0
+	return true, err
+}
+
+// Emit the value starting at `c.buf[i]`, sorting map entries.
+func (c *Canonicalizer) emitValue(i int) error {
+	switch c.buf[i].Type {
+	case TArrOpen:
+		if err := c.emit(i); err != nil {
+			return err
+		}
+		for j := i + 1; j < c.ends[i]; j = c.next(j) {
+			if err := c.emitValue(j); err != nil {
+				return err
+			}
+		}
+		return c.emit(c.ends[i])
+	case TMapOpen:
+		if err := c.emit(i); err != nil {
+			return err
+		}
+		keys := make([]canonicalKey, 0, c.buf[i].Length)
+		for j := i + 1; j < c.ends[i]; j = c.next(j + 1) {
+			keys = append(keys, canonicalKey{j, encodeKey(&c.buf[j])})
+		}
+		switch c.cfg.KeySortMode {
+		case KeySortMode_Default, KeySortMode_Lexical:
+			sort.Sort(canonicalKeys_byValue{keys, c.buf})
+		case KeySortMode_RFC7049:
+			sort.Sort(canonicalKeys_RFC7049(keys))
+		case KeySortMode_RFC8949:
+			sort.Sort(canonicalKeys_RFC8949(keys))
+		}
+		for n, k := range keys {
+			if n > 0 && bytes.Equal(keys[n-1].enc, k.enc) {
+				return ErrDuplicateKey{c.buf[k.idx]}
+			}
+			if err := c.emit(k.idx); err != nil {
+				return err
+			}
+			if err := c.emitValue(k.idx + 1); err != nil {
+				return err
+			}
+		}
+		return c.emit(c.ends[i])
+	default:
+		return c.emit(i)
+	}
+}
+
+// Return the index just past the end of the value starting at `c.buf[i]`.
+func (c *Canonicalizer) next(i int) int {
+	if c.ends[i] >= 0 {
+		return c.ends[i] + 1
+	}
+	return i + 1
+}
+
+// Pass on the token `c.buf[i]`.  The sink should be done with the
+// last token of the buffer, and not before.
+func (c *Canonicalizer) emit(i int) error {
+	done, err := c.sink.Step(&c.buf[i])
+	if err != nil {
+		return err
+	}
+	switch last := i == len(c.buf)-1; {
+	case done && !last:
+		return fmt.Errorf("canonicalize: sink done before the end of the value")
+	case last && !done:
+		return fmt.Errorf("canonicalize: value finished, but sink expects more")
+	}
+	return nil
+}
+
+// The cbor encoding of a map key (which the Validator ensures is an int, uint, or string).
+// Tags are disregarded.
+func encodeKey(tok *Token) []byte {
+	switch tok.Type {
+	case TInt:
+		if tok.Int < 0 {
+			return encodeHead(1, uint64(-1-tok.Int), 9)
+		}
+		return encodeHead(0, uint64(tok.Int), 9)
+	case TUint:
+		return encodeHead(0, tok.Uint, 9)
+	default:
+		return append(encodeHead(3, uint64(len(tok.Str)), 9+len(tok.Str)), tok.Str...)
+	}
+}
+
+// The cbor head for major type `major` and argument `arg`, in its shortest form.
+func encodeHead(major byte, arg uint64, capacity int) []byte {
+	b := make([]byte, 0, capacity)
+	major <<= 5
+	switch {
+	case arg < 24:
+		return append(b, major|byte(arg))
+	case arg <= 0xff:
+		return append(b, major|24, byte(arg))
+	case arg <= 0xffff:
+		return append(b, major|25, byte(arg>>8), byte(arg))
+	case arg <= 0xffffffff:
+		return append(b, major|26, byte(arg>>24), byte(arg>>16), byte(arg>>8), byte(arg))
+	default:
+		return append(b, major|27, byte(arg>>56), byte(arg>>48), byte(arg>>40), byte(arg>>32), byte(arg>>24), byte(arg>>16), byte(arg>>8), byte(arg))
+	}
+}
+
+// Sorts integer keys first, by value, then string keys, bytewise.
+type canonicalKeys_byValue struct {
+	keys []canonicalKey
+	buf  []Token
+}
+
+func (x canonicalKeys_byValue) Len() int      { return len(x.keys) }
+func (x canonicalKeys_byValue) Swap(i, j int) { x.keys[i], x.keys[j] = x.keys[j], x.keys[i] }
+func (x canonicalKeys_byValue) Less(i, j int) bool {
+	a, b := &x.buf[x.keys[i].idx], &x.buf[x.keys[j].idx]
+	if aStr, bStr := a.Type == TString, b.Type == TString; aStr || bStr {
+		return !aStr || aStr && bStr && a.Str < b.Str
+	}
+	aNeg, bNeg := a.Type == TInt && a.Int < 0, b.Type == TInt && b.Int < 0
+	switch {
+	case aNeg && bNeg:
+		return a.Int < b.Int
+	case aNeg != bNeg:
+		return aNeg
+	}
+	return asUint(a) < asUint(b)
+}
+
+// The value of a non-negative int or uint token.
+func asUint(tok *Token) uint64 {
+	if tok.Type == TInt {
+		return uint64(tok.Int)
+	}
+	return tok.Uint
+}
+
+// Sorts by the length of the encoded key, then bytewise.
+type canonicalKeys_RFC7049 []canonicalKey
+
+func (x canonicalKeys_RFC7049) Len() int      { return len(x) }
+func (x canonicalKeys_RFC7049) Swap(i, j int) { x[i], x[j] = x[j], x[i] }
+func (x canonicalKeys_RFC7049) Less(i, j int) bool {
+	li, lj := len(x[i].enc), len(x[j].enc)
+	if li == lj {
+		return bytes.Compare(x[i].enc, x[j].enc) < 0
+	}
+	return li < lj
+}
+
+// Sorts bytewise by the encoded key.
+type canonicalKeys_RFC8949 []canonicalKey
+
+func (x canonicalKeys_RFC8949) Len() int           { return len(x) }
+func (x canonicalKeys_RFC8949) Swap(i, j int)      { x[i], x[j] = x[j], x[i] }
+func (x canonicalKeys_RFC8949) Less(i, j int) bool { return bytes.Compare(x[i].enc, x[j].enc) < 0 }
@@ -0,0 +1,112 @@
+package shared
+
+import (
+	"testing"
+
+	. "github.com/polydawn/refmt/tok"
+)
+
+func canonicalize(cfg CanonicalizeOptions, toks []Token) (string, error) {
+	var out Buffer
+	err := TokenPump{
+		TokenSource: &tokenList{toks},
+		TokenSink:   NewCanonicalizer(cfg, &out),
+	}.Run()
+	return out.String(), err
+}
+
+func TestCanonicalizer(t *testing.T) {
+	// {"bb": [1, {}], "a": true, "c": {"z": null, "y": null}}, as a json decoder would give it.
+	nested := []Token{
+		{Type: TMapOpen, Length: -1},
+		{Type: TString, Str: "bb"}, {Type: TArrOpen, Length: -1}, {Type: TInt, Int: 1}, {Type: TMapOpen, Length: -1}, {Type: TMapClose}, {Type: TArrClose},
+		{Type: TString, Str: "a"}, {Type: TBool, Bool: true},
+		{Type: TString, Str: "c"}, {Type: TMapOpen, Length: -1}, {Type: TString, Str: "z"}, {Type: TNull}, {Type: TString, Str: "y"}, {Type: TNull}, {Type: TMapClose},
+		{Type: TMapClose},
+	}
+	// {10: 0, -1: 0, "a": 0, 1000: 0, 1: 0}, as a cbor decoder might give it.
+	intKeys := []Token{
+		{Type: TMapOpen, Length: 5},
+		{Type: TUint, Uint: 10}, {Type: TNull},
+		{Type: TInt, Int: -1}, {Type: TNull},
+		{Type: TString, Str: "a"}, {Type: TNull},
+		{Type: TUint, Uint: 1000}, {Type: TNull},
+		{Type: TInt, Int: 1}, {Type: TNull},
+		{Type: TMapClose},
+	}
+	tt := []struct {
+		title  string
+		mode   KeySortMode
+		toks   []Token
+		expect string
+	}{
+		{"default", "", nested,
+			`<{:3><s:"a"><b:true><s:"bb"><[:2><i:1><{:0><}><]><s:"c"><{:2><s:"y"><0><s:"z"><0><}><}>`},
+		{"rfc7049", KeySortMode_RFC7049, nested,
+			`<{:3><s:"a"><b:true><s:"c"><{:2><s:"y"><0><s:"z"><0><}><s:"bb"><[:2><i:1><{:0><}><]><}>`},
+		{"int keys, default", KeySortMode_Default, intKeys,
+			`<{:5><i:-1><0><i:1><0><u:10><0><u:1000><0><s:"a"><0><}>`},
+		{"int keys, rfc7049", KeySortMode_RFC7049, intKeys,
+			`<{:5><i:1><0><u:10><0><i:-1><0><s:"a"><0><u:1000><0><}>`},
+		{"int keys, rfc8949", KeySortMode_RFC8949, intKeys,
+			`<{:5><i:1><0><u:10><0><u:1000><0><i:-1><0><s:"a"><0><}>`},
+		{"scalar", "", []Token{{Type: TString, Str: "x"}},
+			`<s:"x">`},
+	}
+	for _, tr := range tt {
+		got, err := canonicalize(CanonicalizeOptions{KeySortMode: tr.mode}, tr.toks)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tr.title, err)
+		}
+		if got != tr.expect {
+			t.Errorf("%s: expected %s, got %s", tr.title, tr.expect, got)
+		}
+	}
+	// Widths are cleared, so the sink picks the preferred encodings.
+	for _, toks := range [][]Token{
+		{{Type: TFloat64, Float64: 1.5, Width: 8}},
+		{{Type: TArrOpen, Length: 1, Width: 2}, {Type: TUint, Uint: 1, Width: 4}, {Type: TArrClose}},
+	} {
+		var out Buffer
+		err := TokenPump{
+			TokenSource: &tokenList{toks},
+			TokenSink:   NewCanonicalizer(CanonicalizeOptions{}, &out),
+		}.Run()
+		if err != nil {
+			t.Errorf("widths: unexpected error %v", err)
+		}
+		for _, tok := range out.Tokens {
+			if tok.Width != 0 {
+				t.Errorf("widths: %s still has width %d", tok, tok.Width)
+			}
+		}
+	}
+}
+
+func TestCanonicalizerErrors(t *testing.T) {
+	dup := []Token{{Type: TMapOpen, Length: -1}, {Type: TInt, Int: 1}, {Type: TNull}, {Type: TUint, Uint: 1}, {Type: TNull}, {Type: TMapClose}}
+	_, err := canonicalize(CanonicalizeOptions{}, dup)
+	if _, ok := err.(ErrDuplicateKey); !ok || err.Error() != "canonicalize: duplicate map key <u:1>" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	_, err = canonicalize(CanonicalizeOptions{}, []Token{{Type: TArrOpen, Length: -1}, {Type: TMapClose}})
+	if err != (ErrMalformedTokenStream{Got: TMapClose, Expected: "start of value"}) {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	long := []Token{{Type: TArrOpen, Length: -1}, {Type: TString, Str: "0123456789"}, {Type: TArrClose}}
+	max := 3*tokenCost + 10
+	if _, err := canonicalize(CanonicalizeOptions{MaxBufferBytes: max}, long); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	var out Buffer
+	c := NewCanonicalizer(CanonicalizeOptions{MaxBufferBytes: max - 1}, &out)
+	err = TokenPump{TokenSource: &tokenList{long}, TokenSink: c}.Run()
+	if err != (ErrLimitExceeded{Limit: "MaxBufferBytes", Max: max - 1, Got: max}) {
+		t.Errorf("unexpected error %v", err)
+	}
+	if len(out.Tokens) != 0 {
+		t.Errorf("expected nothing to be passed on, got %s", out.String())
+	}
+}
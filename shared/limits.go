@@ -0,0 +1,30 @@
+package shared
+
+import (
+	"fmt"
+)
+
+// ErrLimitExceeded is the error returned when a decoder or unmarshaller
+// stops processing because the input would exceed one of its configured
+// resource limits (e.g. nesting depth, or the length of a string).
+//
+// Hitting a limit says nothing about whether the input was well-formed;
+// it just means we declined to spend any more resources finding out.
+type ErrLimitExceeded struct {
+	Limit string // Name of the limit that was hit, e.g. "MaxDepth".
+	Max   int64  // The configured value of the limit.
+	Got   int64  // The size the input asked for, or reached, when it was stopped.
+}
+
+func (e ErrLimitExceeded) Error() string {
+	return fmt.Sprintf("limit exceeded: %s is %d, input requires %d", e.Limit, e.Max, e.Got)
+}
+
+// CheckLimit returns ErrLimitExceeded if `max` is positive and `got` is over it.
+// A `max` of zero (or less) means unlimited.
+func CheckLimit(limit string, max int64, got int64) error {
+	if max > 0 && got > max {
+		return ErrLimitExceeded{limit, max, got}
+	}
+	return nil
+}
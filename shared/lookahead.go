@@ -0,0 +1,83 @@
+package shared
+
+import (
+	. "github.com/polydawn/refmt/tok"
+)
+
+/*
+	Lookahead is a helper for token sources which read ahead to see if
+	the tokens coming up have a particular shape, and yield something
+	else in place of them if they do -- for example, DAG-JSON decoders,
+	which turn maps of the form `{"/": ...}` into single tokens.
+
+	Step yields tokens from the underlying step func.  Whenever a
+	sequence might start, call Match: if the tokens which follow fit the
+	shape, they're consumed; if they don't, Step yields them one at a time,
+	just as if we hadn't looked, before going back to the step func.
+*/
+type Lookahead struct {
+	step    func(*Token) (done bool, err error)
+	pending []pendingToken // Tokens read ahead which didn't fit, and not yet returned.
+	matched []Token        // Tokens which fit the last successful Match.
+}
+
+// A token read ahead, with the results of the step that produced it.
+type pendingToken struct {
+	tok  Token
+	done bool
+	err  error
+}
+
+func NewLookahead(step func(*Token) (done bool, err error)) *Lookahead {
+	return &Lookahead{step: step}
+}
+
+func (la *Lookahead) Reset() {
+	la.pending = la.pending[0:0]
+}
+
+// Pending reports whether Step has tokens read ahead to yield
+// before it next calls the step func.
+func (la *Lookahead) Pending() bool {
+	return len(la.pending) > 0
+}
+
+func (la *Lookahead) Step(tokenSlot *Token) (done bool, err error) {
+	if len(la.pending) == 0 {
+		return la.step(tokenSlot)
+	}
+	p := la.pending[0]
+	copy(la.pending, la.pending[1:])
+	la.pending = la.pending[0 : len(la.pending)-1]
+	*tokenSlot = p.tok
+	return p.done, p.err
+}
+
+/*
+	Match reads one token from the step func for each entry in `shape`,
+	for as long as they fit.
+
+	If they all fit, it returns them (in a slice which is only good until
+	the next Match), along with whether the last one completed the value.
+	If any didn't, it returns nil, and the tokens read so far -- including
+	the one that didn't fit, or its error -- are left for Step to yield.
+
+	Match should only be called when there are no tokens pending.
+*/
+func (la *Lookahead) Match(shape []func(*Token) bool) (matched []Token, done bool) {
+	for _, fits := range shape {
+		var tok Token
+		done, err := la.step(&tok)
+		la.pending = append(la.pending, pendingToken{tok, done, err})
+		if err != nil || !fits(&tok) {
+			return nil, false
+		}
+	}
+	la.matched = la.matched[0:0]
+	for _, p := range la.pending {
+		la.matched = append(la.matched, p.tok)
+	}
+	done = la.pending[len(la.pending)-1].done
+	la.pending = la.pending[0:0]
+	return la.matched, done
+}
@@ -0,0 +1,57 @@
+package shared
+
+import (
+	"testing"
+
+	. "github.com/polydawn/refmt/tok"
+)
+
+func TestLookahead(t *testing.T) {
+	// A map with one key "x" and a string value is read as just the string.
+	shape := []func(*Token) bool{
+		func(tok *Token) bool { return tok.Type == TString && tok.Str == "x" },
+		func(tok *Token) bool { return tok.Type == TString },
+		func(tok *Token) bool { return tok.Type == TMapClose },
+	}
+	read := func(toks ...Token) string {
+		src := Buffer{Tokens: toks}
+		la := NewLookahead(src.Source().Step)
+		var out Buffer
+		for {
+			var tok Token
+			fresh := !la.Pending()
+			done, err := la.Step(&tok)
+			if err != nil {
+				return err.Error()
+			}
+			if fresh && tok.Type == TMapOpen {
+				if matched, mdone := la.Match(shape); matched != nil {
+					tok = matched[1]
+					done = mdone
+				}
+			}
+			out.Tokens = append(out.Tokens, tok)
+			if done {
+				return out.String()
+			}
+		}
+	}
+
+	for _, tr := range []struct {
+		toks   []Token
+		expect string
+	}{
+		{[]Token{{Type: TMapOpen, Length: 1}, {Type: TString, Str: "x"}, {Type: TString, Str: "y"}, {Type: TMapClose}},
+			`<s:"y">`},
+		{[]Token{{Type: TMapOpen, Length: 1}, {Type: TString, Str: "z"}, {Type: TString, Str: "y"}, {Type: TMapClose}},
+			`<{:1><s:"z"><s:"y"><}>`},
+		{[]Token{{Type: TMapOpen, Length: 1}, {Type: TString, Str: "x"}, {Type: TMapOpen, Length: 1}, {Type: TString, Str: "x"}, {Type: TString, Str: "y"}, {Type: TMapClose}, {Type: TMapClose}},
+			`<{:1><s:"x"><{:1><s:"x"><s:"y"><}><}>`}, // Maps read ahead aren't themselves matched.
+		{[]Token{{Type: TArrOpen, Length: 2}, {Type: TMapOpen, Length: 1}, {Type: TString, Str: "x"}, {Type: TString, Str: "y"}, {Type: TMapClose}, {Type: TNull}, {Type: TArrClose}},
+			`<[:2><s:"y"><0><]>`},
+	} {
+		if got := read(tr.toks...); got != tr.expect {
+			t.Errorf("reading %v: expected %s, got %s", tr.toks, tr.expect, got)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 /*
 	The `shared` package defines helper types and functions used
-	internally by all the other refmt packages; it is not user-facing.
+	internally by all the other refmt packages.  Most of it is not
+	user-facing; the exceptions are the tools for plumbing token streams
+	together (`TokenPump`), checking them (`Validator`), and putting them
+	in canonical form (`Canonicalizer`).
 */
 package shared
 
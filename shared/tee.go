@@ -0,0 +1,62 @@
+package shared
+
+import (
+	"fmt"
+
+	. "github.com/polydawn/refmt/tok"
+)
+
+/*
+	Tee is a TokenSink which passes each token on to several sinks in turn.
+
+	It's done when all of its sinks are; they're expected to agree on
+	where a value ends, and it's an error if they don't.  The first error
+	from any sink stops the tee, and the remaining sinks aren't given the token.
+
+	For example, marshalling into a tee of a json and a cbor encoder
+	produces both serial forms from one walk of an object; and a tee
+	including a `tok.Buffer` records everything the other sinks are given.
+*/
+type Tee struct {
+	sinks []TokenSink
+}
+
+func NewTee(sinks ...TokenSink) *Tee {
+	return &Tee{sinks: sinks}
+}
+
+func (t *Tee) Step(tok *Token) (done bool, err error) {
+	for i, sink := range t.sinks {
+		sinkDone, err := sink.Step(tok)
+		if err != nil {
+			return true, err
+		}
+		if i > 0 && sinkDone != done {
+			return true, fmt.Errorf("tee: sinks disagree on whether the value is complete after %s", tok)
+		}
+		done = sinkDone
+	}
+	return done, nil
+}
+
+// RecordingSource is a TokenSource which passes on the tokens from another
+// TokenSource, and records them into a `tok.Buffer`.
+type RecordingSource struct {
+	src TokenSource
+	buf *Buffer
+}
+
+// NewRecordingSource wraps a TokenSource so that every token it yields
+// is also recorded into `buf`.  Errors from the source aren't recorded.
+func NewRecordingSource(src TokenSource, buf *Buffer) *RecordingSource {
+	return &RecordingSource{src: src, buf: buf}
+}
+
+func (s *RecordingSource) Step(tokenSlot *Token) (done bool, err error) {
+	done, err = s.src.Step(tokenSlot)
+	if err != nil {
+		return done, err
+	}
+	s.buf.Step(tokenSlot)
+	return done, nil
+}
@@ -0,0 +1,36 @@
+package shared
+
+import (
+	"testing"
+
+	. "github.com/polydawn/refmt/tok"
+)
+
+func TestTeeAndRecording(t *testing.T) {
+	seq := []Token{{Type: TArrOpen, Length: 2}, {Type: TString, Str: "x"}, {Type: TNull}, {Type: TArrClose}}
+
+	var srcRec, sinkRec, other Buffer
+	err := TokenPump{
+		TokenSource: NewRecordingSource(&tokenList{append([]Token{}, seq...)}, &srcRec),
+		TokenSink:   NewTee(&sinkRec, &other),
+	}.Run()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	for _, buf := range []*Buffer{&srcRec, &sinkRec, &other} {
+		if buf.String() != `<[:2><s:"x"><0><]>` {
+			t.Errorf("unexpected recording %s", buf)
+		}
+	}
+
+	// Replaying, into a sink that's done too soon.
+	nested := Buffer{Tokens: []Token{{Type: TArrOpen, Length: 1}, {Type: TArrOpen, Length: 0}, {Type: TArrClose}, {Type: TArrClose}}}
+	err = TokenPump{TokenSource: nested.Source(), TokenSink: NewTee(&Validator{}, &typeRecorder{})}.Run()
+	if err == nil || err.Error() != `tee: sinks disagree on whether the value is complete after <]>` {
+		t.Errorf("unexpected error %v", err)
+	}
+	err = TokenPump{TokenSource: srcRec.Source(), TokenSink: NewTee(&Validator{})}.Run()
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
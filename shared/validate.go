@@ -0,0 +1,195 @@
+package shared
+
+import (
+	"fmt"
+
+	. "github.com/polydawn/refmt/tok"
+)
+
+// ErrMalformedTokenStream is the error returned when a token stream isn't
+// well-formed: a token appears where it can't, such as a map closing when
+// a value is expected, or an array close with no matching array open.
+//
+// It's returned by `Validator`, and by the `obj.Unmarshaller` and
+// each codec's encoder when they're given streams they can't make sense of.
+type ErrMalformedTokenStream struct {
+	Got      TokenType // Token in the stream that triggered the error.
+	Expected string    // Freeform string describing valid token types.  Often a summary like "array close or start of value", or "map close or key".
+}
+
+func (e ErrMalformedTokenStream) Error() string {
+	return fmt.Sprintf("malformed stream: invalid appearance of %s token; expected %s", e.Got, e.Expected)
+}
+
+/*
+	Validator is a TokenSink which checks that a token stream is well-formed
+	(and otherwise ignores it), returning `ErrMalformedTokenStream` at the
+	first problem.  It's done at the end of the first complete value.
+
+	It checks that:
+
+		- maps and arrays are balanced;
+		- map keys are strings or integers, and each is followed by a value;
+		- maps and arrays declaring a Length (anything but -1) have that many entries;
+		- tags only appear on keys and values (not the closing of maps and arrays).
+
+	(OuterTags are ignored when Tagged isn't set, as `Token` documents;
+	a reused token slot may well have some left over.)
+
+	It doesn't check anything about the values themselves (e.g. for duplicate
+	map keys), nor whether a particular codec can represent them.
+
+	To check a stream as it's passed on, see `NewValidatingSource`
+	and `NewValidatingSink`.
+*/
+type Validator struct {
+	stack []validatorFrame // One for each open map or array.
+	done  bool             // Set once a complete value has been seen.
+}
+
+type validatorFrame struct {
+	isMap     bool
+	expectKey bool // If a map: whether the next token is a key (or the end).
+	length    int  // The declared length, or -1.
+	count     int  // Entries so far.
+}
+
+// Reset the Validator to check a new value.
+func (v *Validator) Reset() {
+	v.stack = v.stack[0:0]
+	v.done = false
+}
+
+func (v *Validator) Step(tok *Token) (done bool, err error) {
+	if v.done {
+		return true, ErrMalformedTokenStream{tok.Type, "nothing (value already complete)"}
+	}
+	if (tok.Type == TMapClose || tok.Type == TArrClose) && tok.Tagged {
+		return true, ErrMalformedTokenStream{tok.Type, "no tag on the end of a map or array"}
+	}
+	if n := len(v.stack); n > 0 {
+		f := &v.stack[n-1]
+		switch {
+		case f.isMap && f.expectKey:
+			switch tok.Type {
+			case TMapClose:
+				return v.close(f, "map", "map key or end of map")
+			case TString, TInt, TUint:
+				if f.length >= 0 && f.count == f.length {
+					return true, ErrMalformedTokenStream{tok.Type, fmt.Sprintf("end of map (declared length %d)", f.length)}
+				}
+				f.count++
+				f.expectKey = false
+				return false, nil
+			default:
+				return true, ErrMalformedTokenStream{tok.Type, "map key or end of map"}
+			}
+		case f.isMap:
+			f.expectKey = true
+		default:
+			if tok.Type == TArrClose {
+				return v.close(f, "array", "start of value or end of array")
+			}
+			if f.length >= 0 && f.count == f.length {
+				return true, ErrMalformedTokenStream{tok.Type, fmt.Sprintf("end of array (declared length %d)", f.length)}
+			}
+			f.count++
+		}
+	}
+	// A value is expected.
+	switch tok.Type {
+	case TMapOpen, TArrOpen:
+		length := tok.Length
+		if length < 0 {
+			length = -1
+		}
+		v.stack = append(v.stack, validatorFrame{isMap: tok.Type == TMapOpen, expectKey: true, length: length})
+		return false, nil
+	case TNull, TUndefined, TSimple, TString, TBytes, TBool, TInt, TUint, TFloat64:
+		v.done = len(v.stack) == 0
+		return v.done, nil
+	default:
+		return true, ErrMalformedTokenStream{tok.Type, "start of value"}
+	}
+}
+
+// Check the close of the map or array `f` (described as `kind`),
+// and pop it off the stack.
+func (v *Validator) close(f *validatorFrame, kind string, expected string) (done bool, err error) {
+	if f.length >= 0 && f.count < f.length {
+		typ := TMapClose
+		if !f.isMap {
+			typ = TArrClose
+		}
+		return true, ErrMalformedTokenStream{typ, fmt.Sprintf("%d more entries (%s declared length %d)", f.length-f.count, kind, f.length)}
+	}
+	v.stack = v.stack[0 : len(v.stack)-1]
+	v.done = len(v.stack) == 0
+	return v.done, nil
+}
+
+// ValidatingSource is a TokenSource which passes on the tokens from another
+// TokenSource, checking them with a Validator.
+type ValidatingSource struct {
+	src TokenSource
+	v   Validator
+}
+
+// NewValidatingSource wraps a TokenSource, so that it returns an
+// `ErrMalformedTokenStream` instead of any token that isn't well-formed.
+// It also checks that the source is done exactly when its value is complete.
+func NewValidatingSource(src TokenSource) *ValidatingSource {
+	return &ValidatingSource{src: src}
+}
+
+// Reset the Validator, to check the source's next value.
+// (The source itself must be reset separately.)
+func (s *ValidatingSource) Reset() {
+	s.v.Reset()
+}
+
+func (s *ValidatingSource) Step(tokenSlot *Token) (done bool, err error) {
+	srcDone, err := s.src.Step(tokenSlot)
+	if err != nil {
+		return true, err
+	}
+	done, err = s.v.Step(tokenSlot)
+	if err != nil {
+		return true, err
+	}
+	switch {
+	case srcDone && !done:
+		return true, ErrMalformedTokenStream{tokenSlot.Type, "more tokens (source ended mid-value)"}
+	case done && !srcDone:
+		return true, ErrMalformedTokenStream{tokenSlot.Type, "source to end with value"}
+	}
+	return done, nil
+}
+
+// ValidatingSink is a TokenSink which checks tokens with a Validator,
+// before passing them on to another TokenSink.
+type ValidatingSink struct {
+	sink TokenSink
+	v    Validator
+}
+
+// NewValidatingSink wraps a TokenSink, so that it returns an
+// `ErrMalformedTokenStream` for any token that isn't well-formed,
+// instead of passing it on.  It's useful to guard encoders from hand-built
+// token streams.
+func NewValidatingSink(sink TokenSink) *ValidatingSink {
+	return &ValidatingSink{sink: sink}
+}
+
+// Reset the Validator, to check a new value.
+// (The sink itself must be reset separately.)
+func (s *ValidatingSink) Reset() {
+	s.v.Reset()
+}
+
+func (s *ValidatingSink) Step(tok *Token) (done bool, err error) {
+	if _, err := s.v.Step(tok); err != nil {
+		return true, err
+	}
+	return s.sink.Step(tok)
+}
@@ -0,0 +1,149 @@
+package shared
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/polydawn/refmt/tok"
+	"github.com/polydawn/refmt/tok/fixtures"
+)
+
+// A TokenSource that yields a fixed list of tokens, and is done after the last.
+type tokenList struct {
+	toks []Token
+}
+
+func (l *tokenList) Step(tok *Token) (done bool, err error) {
+	*tok, l.toks = l.toks[0], l.toks[1:]
+	return len(l.toks) == 0, nil
+}
+
+// Validate a sequence (which may be incomplete), returning the first error, if any.
+func validate(toks []Token) error {
+	var v Validator
+	for i := range toks {
+		done, err := v.Step(&toks[i])
+		if err != nil {
+			return err
+		}
+		if done && i != len(toks)-1 {
+			return ErrMalformedTokenStream{toks[i].Type, "test: done too soon"}
+		}
+	}
+	return nil
+}
+
+func TestValidatorAcceptsFixtures(t *testing.T) {
+	for _, seq := range fixtures.Sequences {
+		if len(seq.Tokens) == 0 {
+			continue
+		}
+		if err := validate(seq.Tokens); err != nil {
+			t.Errorf("fixture %q: unexpected error %v", seq.Title, err)
+		}
+		if err := validate(seq.SansLengthInfo().Tokens); err != nil {
+			t.Errorf("fixture %q without lengths: unexpected error %v", seq.Title, err)
+		}
+	}
+}
+
+func TestValidatorIgnoresOuterTagsWithoutTagged(t *testing.T) {
+	toks := []Token{{Type: TArrOpen, Length: 2}, {Type: TInt, Tagged: true, Tag: 2, OuterTags: []uint64{1}}, {Type: TInt, OuterTags: []uint64{1}}, {Type: TArrClose}}
+	if err := validate(toks); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestValidatorRejects(t *testing.T) {
+	tt := []struct {
+		title     string
+		toks      []Token
+		expectErr error
+	}{
+		{"close without open",
+			[]Token{{Type: TMapClose}},
+			ErrMalformedTokenStream{TMapClose, "start of value"}},
+		{"mismatched close",
+			[]Token{{Type: TArrOpen, Length: -1}, {Type: TMapClose}},
+			ErrMalformedTokenStream{TMapClose, "start of value"}},
+		{"map closed while expecting value",
+			[]Token{{Type: TMapOpen, Length: -1}, {Type: TString, Str: "k"}, {Type: TMapClose}},
+			ErrMalformedTokenStream{TMapClose, "start of value"}},
+		{"bool key",
+			[]Token{{Type: TMapOpen, Length: -1}, {Type: TBool}},
+			ErrMalformedTokenStream{TBool, "map key or end of map"}},
+		{"map key",
+			[]Token{{Type: TMapOpen, Length: -1}, {Type: TMapOpen, Length: -1}},
+			ErrMalformedTokenStream{TMapOpen, "map key or end of map"}},
+		{"invalid token type",
+			[]Token{{Type: 'z'}},
+			ErrMalformedTokenStream{'z', "start of value"}},
+		{"map shorter than declared",
+			[]Token{{Type: TMapOpen, Length: 2}, {Type: TString, Str: "k"}, {Type: TNull}, {Type: TMapClose}},
+			ErrMalformedTokenStream{TMapClose, "1 more entries (map declared length 2)"}},
+		{"map longer than declared",
+			[]Token{{Type: TMapOpen, Length: 1}, {Type: TString, Str: "k"}, {Type: TNull}, {Type: TString, Str: "j"}},
+			ErrMalformedTokenStream{TString, "end of map (declared length 1)"}},
+		{"array longer than declared",
+			[]Token{{Type: TArrOpen, Length: 0}, {Type: TNull}},
+			ErrMalformedTokenStream{TNull, "end of array (declared length 0)"}},
+		{"array shorter than declared",
+			[]Token{{Type: TArrOpen, Length: 3}, {Type: TNull}, {Type: TArrClose}},
+			ErrMalformedTokenStream{TArrClose, "2 more entries (array declared length 3)"}},
+		{"tagged close",
+			[]Token{{Type: TArrOpen, Length: -1}, {Type: TArrClose, Tagged: true, Tag: 1}},
+			ErrMalformedTokenStream{TArrClose, "no tag on the end of a map or array"}},
+		{"more after done",
+			[]Token{{Type: TNull}, {Type: TNull}},
+			ErrMalformedTokenStream{TNull, "nothing (value already complete)"}},
+	}
+	for _, tr := range tt {
+		var v Validator
+		var err error
+		for i := range tr.toks {
+			if _, err = v.Step(&tr.toks[i]); err != nil {
+				break
+			}
+		}
+		if err != tr.expectErr {
+			t.Errorf("%s: expected error %v, got %v", tr.title, tr.expectErr, err)
+		}
+	}
+}
+
+// Records the type of each token it's given.
+type typeRecorder struct {
+	bytes.Buffer
+}
+
+func (r *typeRecorder) Step(tok *Token) (done bool, err error) {
+	r.WriteByte(byte(tok.Type))
+	return tok.Type == TArrClose, nil
+}
+
+func TestValidatingWrappers(t *testing.T) {
+	good := []Token{{Type: TArrOpen, Length: 1}, {Type: TNull}, {Type: TArrClose}}
+	bad := []Token{{Type: TArrOpen, Length: 1}, {Type: TMapClose}, {Type: TArrClose}}
+
+	var rec typeRecorder
+	if err := (TokenPump{TokenSource: NewValidatingSource(&tokenList{good}), TokenSink: NewValidatingSink(&rec)}).Run(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if rec.String() != "[0]" {
+		t.Errorf("expected all tokens to be passed on, got %q", rec.String())
+	}
+
+	rec.Reset()
+	err := TokenPump{TokenSource: &tokenList{bad}, TokenSink: NewValidatingSink(&rec)}.Run()
+	if err != (ErrMalformedTokenStream{TMapClose, "start of value"}) {
+		t.Errorf("unexpected error %v", err)
+	}
+	if rec.String() != "[" {
+		t.Errorf("expected only the tokens before the bad one to be passed on, got %q", rec.String())
+	}
+
+	err = TokenPump{TokenSource: NewValidatingSource(&tokenList{good[:2]}), TokenSink: &rec}.Run()
+	if err != (ErrMalformedTokenStream{TNull, "more tokens (source ended mid-value)"}) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package tok
+
+import (
+	"bytes"
+	"fmt"
+)
+
+/*
+	Buffer is an in-memory recording of a token stream.
+
+	A Buffer is itself a TokenSink: each token stepped into it is copied
+	and appended to `Tokens`, and it's done at the end of each complete value.
+	(`shared.NewRecordingSource` and `shared.NewTee` record the tokens
+	passing through another source or sink into a Buffer.)
+
+	The recording can be replayed, any number of times, with `Source`.
+	`String` renders it for debugging, e.g. `<{:1><s:"a"><i:1><}>`.
+
+	The zero value is an empty Buffer, ready to use.
+*/
+type Buffer struct {
+	Tokens []Token
+	depth  int // Depth of the tokens recorded so far.
+}
+
+// Reset discards the recording, leaving the Buffer ready to record again.
+func (b *Buffer) Reset() {
+	b.Tokens = b.Tokens[:0]
+	b.depth = 0
+}
+
+func (b *Buffer) Step(tok *Token) (done bool, err error) {
+	b.Tokens = append(b.Tokens, copyToken(tok))
+	b.depth = depthAfter(b.depth, tok.Type)
+	return b.depth == 0, nil
+}
+
+// Copy a token, including the slices it refers to, which are often
+// reused by whoever is stepping tokens.
+func copyToken(tok *Token) Token {
+	t := *tok
+	if t.Bytes != nil {
+		t.Bytes = append([]byte{}, t.Bytes...)
+	}
+	if t.OuterTags != nil {
+		t.OuterTags = append([]uint64{}, t.OuterTags...)
+	}
+	return t
+}
+
+func depthAfter(depth int, tt TokenType) int {
+	switch tt {
+	case TMapOpen, TArrOpen:
+		return depth + 1
+	case TMapClose, TArrClose:
+		return depth - 1
+	}
+	return depth
+}
+
+// Source returns a TokenSource which replays the recording from the start.
+// Each Source is independent, so a recording can be replayed many times.
+//
+// The recording shouldn't be changed while it's being replayed.
+func (b *Buffer) Source() *BufferSource {
+	return &BufferSource{buf: b}
+}
+
+func (b *Buffer) String() string {
+	var buf bytes.Buffer
+	for _, t := range b.Tokens {
+		buf.WriteString(t.String())
+	}
+	return buf.String()
+}
+
+/*
+	BufferSource is a TokenSource which replays the tokens in a Buffer.
+
+	Like the Buffer that recorded them, it's done at the end of each
+	complete value; if the recording holds several values, stepping it
+	further replays the next one.  Stepping it past the end of the
+	recording is an error.
+
+	The tokens it yields are copies; changing them doesn't
+	change the recording.
+*/
+type BufferSource struct {
+	buf   *Buffer
+	next  int // Index of the next token to yield.
+	depth int // Depth of the tokens yielded so far.
+}
+
+// Reset rewinds to the start of the recording.
+func (s *BufferSource) Reset() {
+	s.next = 0
+	s.depth = 0
+}
+
+func (s *BufferSource) Step(tokenSlot *Token) (done bool, err error) {
+	if s.next >= len(s.buf.Tokens) {
+		return true, fmt.Errorf("tok: replayed all %d tokens of the recording, but more were requested", len(s.buf.Tokens))
+	}
+	*tokenSlot = copyToken(&s.buf.Tokens[s.next])
+	s.next++
+	s.depth = depthAfter(s.depth, tokenSlot.Type)
+	return s.depth == 0, nil
+}
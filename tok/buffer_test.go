@@ -0,0 +1,72 @@
+package tok
+
+import (
+	"testing"
+)
+
+func TestBufferRecordAndReplay(t *testing.T) {
+	seq := []Token{
+		{Type: TMapOpen, Length: 2},
+		{Type: TString, Str: "a"},
+		{Type: TBytes, Bytes: []byte{1, 2}},
+		{Type: TString, Str: "b"},
+		{Type: TArrOpen, Length: -1},
+		{Type: TInt, Int: 3, Tagged: true, Tag: 7, OuterTags: []uint64{6}},
+		{Type: TArrClose},
+		{Type: TMapClose},
+	}
+	var buf Buffer
+	var slot Token
+	for i := range seq {
+		// Reuse the slot (and its slices), like a decoder would.
+		slot.Bytes = append(slot.Bytes[:0], seq[i].Bytes...)
+		slot.OuterTags = append(slot.OuterTags[:0], seq[i].OuterTags...)
+		slot.Type, slot.Length, slot.Str, slot.Int = seq[i].Type, seq[i].Length, seq[i].Str, seq[i].Int
+		slot.Tagged, slot.Tag = seq[i].Tagged, seq[i].Tag
+		done, err := buf.Step(&slot)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if done != (i == len(seq)-1) {
+			t.Errorf("token %d: expected done=%v", i, !done)
+		}
+		// Changing the slot afterwards doesn't change the recording.
+		if len(slot.Bytes) > 0 {
+			slot.Bytes[0] = 9
+		}
+		if len(slot.OuterTags) > 0 {
+			slot.OuterTags[0] = 9
+		}
+	}
+
+	if expect := `<{:2><s:"a"><x:[1 2]><s:"b"><[>_6:_7:<i:3><]><}>`; buf.String() != expect {
+		t.Errorf("expected recording %s, got %s", expect, buf.String())
+	}
+	for n := 0; n < 2; n++ {
+		src := buf.Source()
+		for i := range seq {
+			done, err := src.Step(&slot)
+			if err != nil {
+				t.Fatalf("replay %d: unexpected error %v", n, err)
+			}
+			if !IsTokenEqual(slot, seq[i]) || slot.Tag != seq[i].Tag {
+				t.Errorf("replay %d: token %d: expected %s, got %s", n, i, seq[i], slot)
+			}
+			if done != (i == len(seq)-1) {
+				t.Errorf("replay %d: token %d: expected done=%v", n, i, !done)
+			}
+			// Changing what's replayed doesn't change the recording.
+			if len(slot.Bytes) > 0 {
+				slot.Bytes[0] = 9
+			}
+		}
+		if _, err := src.Step(&slot); err == nil {
+			t.Errorf("replay %d: expected an error after the end of the recording", n)
+		}
+	}
+
+	buf.Reset()
+	if len(buf.Tokens) != 0 || buf.String() != "" {
+		t.Errorf("expected an empty recording after reset, got %s", buf.String())
+	}
+}
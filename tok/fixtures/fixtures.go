@@ -246,6 +246,28 @@ var Sequences = []Sequence{
 			{Type: TMapClose},
 		},
 	},
+	{"undefined",
+		[]Token{
+			{Type: TUndefined},
+		},
+	},
+	{"undefined in map",
+		[]Token{
+			{Type: TMapOpen, Length: 1},
+			TokStr("k"),
+			{Type: TUndefined},
+			{Type: TMapClose},
+		},
+	},
+	{"simple values in array",
+		[]Token{
+			{Type: TArrOpen, Length: 3},
+			{Type: TSimple, Uint: 16},
+			{Type: TSimple, Uint: 32},
+			{Type: TSimple, Uint: 255},
+			{Type: TArrClose},
+		},
+	},
 	{"null in array in array",
 		[]Token{
 			{Type: TArrOpen, Length: 1},
@@ -311,6 +333,11 @@ var Sequences = []Sequence{
 			{Type: TString, Str: "wahoo", Tagged: true, Tag: 50},
 		},
 	},
+	{"string with nested tags",
+		[]Token{
+			{Type: TString, Str: "x", Tagged: true, Tag: 42, OuterTags: []uint64{55799}},
+		},
+	},
 	{"array with mixed tagged values",
 		[]Token{
 			{Type: TArrOpen, Length: 2},
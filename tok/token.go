@@ -20,11 +20,13 @@ type Token struct {
 	Bytes   []byte  // Value union.  Only one of these has meaning, depending on the value of 'Type'.
 	Bool    bool    // Value union.  Only one of these has meaning, depending on the value of 'Type'.
 	Int     int64   // Value union.  Only one of these has meaning, depending on the value of 'Type'.
-	Uint    uint64  // Value union.  Only one of these has meaning, depending on the value of 'Type'.
+	Uint    uint64  // Value union.  Only one of these has meaning, depending on the value of 'Type'.  (Also used by TSimple.)
 	Float64 float64 // Value union.  Only one of these has meaning, depending on the value of 'Type'.
 
-	Tagged bool // Extension slot for cbor.
-	Tag    int  // Extension slot for cbor.  Only applicable if tagged=true.
+	Tagged    bool     // Extension slot for cbor.
+	Tag       uint64   // Extension slot for cbor.  Only applicable if tagged=true.  If there are several tags, this is the innermost one.
+	OuterTags []uint64 // Extension slot for cbor.  Only applicable if tagged=true.  Any further tags wrapped around `Tag`, outermost first.
+	Width     int      // Extension slot for cbor.  How many bytes the serial form gives a float (2, 4, or 8), or the argument of an int or a map or array length (1, 2, 4, or 8).  Zero leaves it to the encoder (as does any, unless the encoder is set to preserve widths).
 }
 
 type TokenType byte
@@ -36,6 +38,9 @@ const (
 	TArrClose TokenType = ']'
 	TNull     TokenType = '0'
 
+	TUndefined TokenType = 'U' // CBOR's "undefined".  Distinct from null; no equivalent in most other formats.
+	TSimple    TokenType = 'S' // CBOR's other "simple values".  The number (0-19, or 32-255) is in the Uint field.
+
 	TString  TokenType = 's'
 	TBytes   TokenType = 'x'
 	TBool    TokenType = 'b'
@@ -56,6 +61,10 @@ func (tt TokenType) String() string {
 		return "array close"
 	case TNull:
 		return "null"
+	case TUndefined:
+		return "undefined"
+	case TSimple:
+		return "simple"
 	case TString:
 		return "string"
 	case TBytes:
@@ -76,6 +85,8 @@ func (tt TokenType) IsValid() bool {
 	switch tt {
 	case TString, TBytes, TBool, TInt, TUint, TFloat64, TNull:
 		return true
+	case TUndefined, TSimple:
+		return true
 	case TMapOpen, TMapClose, TArrOpen, TArrClose:
 		return true
 	default:
@@ -85,7 +96,7 @@ func (tt TokenType) IsValid() bool {
 
 func (tt TokenType) IsValue() bool {
 	switch tt {
-	case TString, TBytes, TBool, TInt, TUint, TFloat64:
+	case TString, TBytes, TBool, TInt, TUint, TFloat64, TSimple:
 		return true
 	default:
 		return false
@@ -94,7 +105,7 @@ func (tt TokenType) IsValue() bool {
 
 func (tt TokenType) IsSpecial() bool {
 	switch tt {
-	case TMapOpen, TMapClose, TArrOpen, TArrClose, TNull:
+	case TMapOpen, TMapClose, TArrOpen, TArrClose, TNull, TUndefined:
 		return true
 	default:
 		return false
@@ -120,9 +131,9 @@ func IsTokenEqual(t1, t2 Token) bool {
 	switch t1.Type {
 	case TMapOpen, TArrOpen:
 		return t1.Length == t2.Length
-	case TMapClose, TArrClose, TNull:
+	case TMapClose, TArrClose, TNull, TUndefined:
 		return true
-	case TString, TBool, TInt, TUint, TFloat64:
+	case TString, TBool, TInt, TUint, TFloat64, TSimple:
 		return t1.Value() == t2.Value()
 	case TBytes:
 		return bytes.Equal(t1.Bytes, t2.Bytes)
@@ -145,7 +156,7 @@ func (t Token) Value() interface{} {
 		return t.Bool
 	case TInt:
 		return t.Int
-	case TUint:
+	case TUint, TSimple:
 		return t.Uint
 	case TFloat64:
 		return t.Float64
@@ -158,7 +169,12 @@ func (t Token) String() string {
 	if !t.Tagged {
 		return t.StringSansTag()
 	}
-	return fmt.Sprintf("_%d:%s", t.Tag, t.StringSansTag())
+	var buf bytes.Buffer
+	for _, tag := range t.OuterTags {
+		fmt.Fprintf(&buf, "_%d:", tag)
+	}
+	fmt.Fprintf(&buf, "_%d:%s", t.Tag, t.StringSansTag())
+	return buf.String()
 }
 
 func (t Token) StringSansTag() string {
@@ -179,6 +195,8 @@ func (t Token) StringSansTag() string {
 		return "<]>"
 	case TNull:
 		return "<0>"
+	case TUndefined:
+		return "<U>"
 	case TString:
 		return fmt.Sprintf("<%c:%q>", t.Type, t.Value())
 	}
@@ -0,0 +1,23 @@
+/*
+	Package transform rewrites token streams as they flow, so that documents
+	can be reshaped -- and transcoded -- in one streaming pass, whatever
+	codecs are on either end.
+
+	An `Op` is one rewriting step: the package provides ops to rename keys
+	(`Rename`), drop entries (`Drop`), replace values (`Replace`), keep only
+	some entries (`Project`), and wrap values in a map or unwrap them from
+	one (`Wrap`, `Unwrap`).  Any number of ops can be chained, and the
+	chain used either as a TokenSource (wrapping another source; see
+	`NewSource`) or as a TokenSink (wrapping another sink; see `NewSink`).
+
+	Ops pick which values to act on with patterns: JSON Pointers (see
+	`query.Pointer`) in which a `*` step matches any one map key or array index.
+	Patterns match against the paths in the stream each op receives, which,
+	after an earlier op in the chain has renamed or unwrapped something,
+	may differ from the paths in the original document.
+
+	Ops which may remove entries from maps or arrays (`Drop`, `Project`)
+	change the `Length` of those containers to -1 (unknown), since it's not
+	known in advance how many entries will be left.
+*/
+package transform
@@ -0,0 +1,302 @@
+package transform
+
+import (
+	"fmt"
+
+	"github.com/polydawn/refmt/query"
+	. "github.com/polydawn/refmt/tok"
+)
+
+// Error returned by the Op made by `Unwrap` when a value can't be unwrapped.
+type ErrUnwrap struct {
+	Path   string // Path of the value.
+	Reason string
+}
+
+func (e ErrUnwrap) Error() string {
+	return fmt.Sprintf("transform: can't unwrap value at %q: %s", e.Path, e.Reason)
+}
+
+var (
+	tokMapOpen  = Token{Type: TMapOpen, Length: 1}
+	tokMapClose = Token{Type: TMapClose}
+)
+
+// Drops the tokens of one value, or the value following a key.
+type skipper struct {
+	next  bool // Set to skip the next value.
+	depth int  // Depth within a value being skipped.
+}
+
+// Start skipping the value that `tok` begins.
+func (s *skipper) skip(tok *Token) {
+	if tok.Type == TMapOpen || tok.Type == TArrOpen {
+		s.depth = 1
+	}
+}
+
+// Reports whether `tok` is part of a value being skipped.
+func (s *skipper) consume(tok *Token) bool {
+	switch {
+	case s.depth > 0:
+		switch tok.Type {
+		case TMapOpen, TArrOpen:
+			s.depth++
+		case TMapClose, TArrClose:
+			s.depth--
+		}
+		return true
+	case s.next:
+		s.next = false
+		s.skip(tok)
+		return true
+	}
+	return false
+}
+
+// Rename returns an Op which changes map keys at paths matching `pattern` to `to`.
+func Rename(pattern query.Pointer, to string) Op {
+	return &renameOp{pattern, to}
+}
+
+type renameOp struct {
+	pattern query.Pointer
+	to      string
+}
+
+func (op *renameOp) Transform(tok *Token, at Position, emit func(*Token) error) error {
+	if at.Key && matches(op.pattern, at.Path) {
+		tok.Type, tok.Str = TString, op.to
+	}
+	return emit(tok)
+}
+
+// Drop returns an Op which removes the map entries and array entries
+// at paths matching any of the patterns.
+// The document itself can't be dropped, so the patterns can't be empty.
+func Drop(patterns ...query.Pointer) Op {
+	for _, p := range patterns {
+		if len(p) == 0 {
+			panic("transform: can't drop the whole document")
+		}
+	}
+	return &dropOp{patterns: patterns}
+}
+
+type dropOp struct {
+	patterns []query.Pointer
+	skipper
+}
+
+func (op *dropOp) Transform(tok *Token, at Position, emit func(*Token) error) error {
+	switch {
+	case op.consume(tok):
+		return nil
+	case at.Key && matchesAny(op.patterns, at.Path):
+		op.next = true
+		return nil
+	case at.valueStart(tok) && matchesAny(op.patterns, at.Path):
+		op.skip(tok)
+		return nil
+	}
+	if tok.Type == TMapOpen || tok.Type == TArrOpen {
+		// If any entries might be dropped, we don't know how many will be left.
+		for _, p := range op.patterns {
+			if len(p) == len(at.Path)+1 && matches(p[:len(at.Path)], at.Path) {
+				tok.Length = -1
+			}
+		}
+	}
+	return emit(tok)
+}
+
+// Replace returns an Op which replaces values at paths matching `pattern`
+// with the value made of the tokens `with`.
+func Replace(pattern query.Pointer, with ...Token) Op {
+	return &replaceOp{pattern: pattern, with: with}
+}
+
+type replaceOp struct {
+	pattern query.Pointer
+	with    []Token
+	slot    Token
+	skipper
+}
+
+func (op *replaceOp) Transform(tok *Token, at Position, emit func(*Token) error) error {
+	if op.consume(tok) {
+		return nil
+	}
+	if !at.valueStart(tok) || !matches(op.pattern, at.Path) {
+		return emit(tok)
+	}
+	op.skip(tok)
+	for i := range op.with {
+		op.slot = op.with[i]
+		if err := emit(&op.slot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Project returns an Op which keeps only the values at paths matching
+// any of the patterns, and the maps and arrays on the way to them;
+// all other map and array entries are dropped.
+func Project(patterns ...query.Pointer) Op {
+	return &projectOp{patterns: patterns}
+}
+
+type projectOp struct {
+	patterns []query.Pointer
+	skipper
+}
+
+// Reports whether the path is within a value being kept, or on the way to one.
+func (op *projectOp) kept(path query.Pointer) bool {
+	for _, p := range op.patterns {
+		if n := len(p); n <= len(path) && matches(p, path[:n]) || n > len(path) && matches(p[:len(path)], path) {
+			return true
+		}
+	}
+	return len(path) == 0
+}
+
+// Reports whether the path is within a value being kept (and not just on the way to one).
+func (op *projectOp) within(path query.Pointer) bool {
+	for _, p := range op.patterns {
+		if n := len(p); n <= len(path) && matches(p, path[:n]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (op *projectOp) Transform(tok *Token, at Position, emit func(*Token) error) error {
+	switch {
+	case op.consume(tok):
+		return nil
+	case at.Key && !op.kept(at.Path):
+		op.next = true
+		return nil
+	case at.valueStart(tok) && !op.kept(at.Path):
+		op.skip(tok)
+		return nil
+	}
+	if (tok.Type == TMapOpen || tok.Type == TArrOpen) && !op.within(at.Path) {
+		// Some entries may be dropped; we don't know how many will be left.
+		tok.Length = -1
+	}
+	return emit(tok)
+}
+
+// Wrap returns an Op which replaces values at paths matching `pattern`
+// with a map, holding the original value as its only entry, under `key`.
+func Wrap(pattern query.Pointer, key string) Op {
+	return &wrapOp{pattern: pattern, key: Token{Type: TString, Str: key}}
+}
+
+type wrapOp struct {
+	pattern query.Pointer
+	key     Token
+	slot    Token
+	open    bool // Set while within a wrapped map or array.  (Matches of one pattern can't nest.)
+}
+
+func (op *wrapOp) emitConst(tok Token, emit func(*Token) error) error {
+	op.slot = tok
+	return emit(&op.slot)
+}
+
+func (op *wrapOp) Transform(tok *Token, at Position, emit func(*Token) error) error {
+	if tok.Type == TMapClose || tok.Type == TArrClose {
+		if err := emit(tok); err != nil {
+			return err
+		}
+		if op.open && matches(op.pattern, at.Path) {
+			op.open = false
+			return op.emitConst(tokMapClose, emit)
+		}
+		return nil
+	}
+	if !at.valueStart(tok) || !matches(op.pattern, at.Path) {
+		return emit(tok)
+	}
+	if err := op.emitConst(tokMapOpen, emit); err != nil {
+		return err
+	}
+	if err := op.emitConst(op.key, emit); err != nil {
+		return err
+	}
+	if err := emit(tok); err != nil {
+		return err
+	}
+	if tok.Type == TMapOpen || tok.Type == TArrOpen {
+		op.open = true
+		return nil
+	}
+	return op.emitConst(tokMapClose, emit)
+}
+
+// Unwrap returns an Op which replaces maps (or arrays) at paths matching
+// `pattern` with their entry under the key (or index) `step`.
+// If there's no such entry, or the value isn't a map or array,
+// it's an `ErrUnwrap`.
+func Unwrap(pattern query.Pointer, step string) Op {
+	return &unwrapOp{pattern: pattern, step: step}
+}
+
+type unwrapOp struct {
+	pattern query.Pointer
+	step    string
+	state   unwrapState
+	depth   int // Length of the path of the value being unwrapped.  (Matches of one pattern can't nest.)
+}
+
+type unwrapState byte
+
+const (
+	unwrapIdle     unwrapState = iota // Not within a value being unwrapped.
+	unwrapSeeking                     // Dropping entries until the one we want.
+	unwrapPassing                     // Passing on the entry we want.
+	unwrapDraining                    // Dropping entries after the one we want.
+)
+
+func (op *unwrapOp) Transform(tok *Token, at Position, emit func(*Token) error) error {
+	isClose := tok.Type == TMapClose || tok.Type == TArrClose
+	switch op.state {
+	case unwrapIdle:
+		if !at.valueStart(tok) || !matches(op.pattern, at.Path) {
+			return emit(tok)
+		}
+		if tok.Type != TMapOpen && tok.Type != TArrOpen {
+			return ErrUnwrap{at.Path.String(), "found " + tok.Type.String() + ", not a map or array"}
+		}
+		op.state, op.depth = unwrapSeeking, len(at.Path)
+		return nil
+	case unwrapSeeking:
+		if isClose && len(at.Path) == op.depth {
+			return ErrUnwrap{at.Path.String(), fmt.Sprintf("no entry %q", op.step)}
+		}
+		if len(at.Path) != op.depth+1 || at.Path[op.depth] != op.step {
+			return nil
+		}
+		op.state = unwrapPassing
+		if at.Key {
+			return nil
+		}
+		fallthrough
+	case unwrapPassing:
+		if len(at.Path) == op.depth+1 && (isClose || at.valueStart(tok) && tok.Type != TMapOpen && tok.Type != TArrOpen) {
+			// This token finishes the entry we want.
+			op.state = unwrapDraining
+		}
+		return emit(tok)
+	case unwrapDraining:
+		if isClose && len(at.Path) == op.depth {
+			op.state = unwrapIdle
+		}
+		return nil
+	}
+	panic("unreachable")
+}
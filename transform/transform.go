@@ -0,0 +1,255 @@
+package transform
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/polydawn/refmt/query"
+	"github.com/polydawn/refmt/shared"
+	. "github.com/polydawn/refmt/tok"
+)
+
+/*
+	Op is one step of a transformation.
+
+	Transform is called with each token of the stream in turn, and calls
+	`emit` with each token it wants to pass on -- none, the same one
+	(possibly modified), or several.  The token belongs to the Op until
+	Transform returns, and may be modified freely; so may any emitted token,
+	by the Ops downstream, so an Op should emit a copy of any token it
+	wants to keep.
+
+	Ops are stateful; use a fresh set for each stream.
+*/
+type Op interface {
+	Transform(tok *Token, at Position, emit func(*Token) error) error
+}
+
+/*
+	Position describes where a token is in the stream an Op receives.
+
+	For the tokens that make up a value (a scalar, or the opening and
+	closing of a map or array), Path is the path of that value.
+	For a map key, Path is the path of the value the key introduces,
+	and Key is true.
+
+	The Path is only valid during the call to Transform.
+*/
+type Position struct {
+	Path query.Pointer
+	Key  bool
+}
+
+// Reports whether the token at this position starts a value.
+func (at Position) valueStart(tok *Token) bool {
+	return !at.Key && tok.Type != TMapClose && tok.Type != TArrClose
+}
+
+/*
+	Source is a TokenSource that yields the tokens of another source,
+	transformed by a chain of Ops.
+
+	It's done when it has yielded one complete value.  If the Ops finish
+	the value early (e.g. `Unwrap` at the root), the rest of the source
+	isn't read.
+*/
+type Source struct {
+	src     shared.TokenSource
+	srcDone bool
+	chain   chain
+	queue   []Token // Tokens emitted by the chain and not yet yielded.
+	next    int     // Index of the next token to yield from the queue.
+	depth   int     // Depth of the tokens yielded so far.
+}
+
+func NewSource(src shared.TokenSource, ops ...Op) *Source {
+	s := &Source{src: src}
+	s.chain.init(ops, s.enqueue)
+	return s
+}
+
+func (s *Source) enqueue(tok *Token) error {
+	s.queue = append(s.queue, *tok)
+	return nil
+}
+
+func (s *Source) Step(tokenSlot *Token) (done bool, err error) {
+	for s.next == len(s.queue) {
+		s.queue, s.next = s.queue[:0], 0
+		if s.srcDone {
+			return true, fmt.Errorf("transform: source ended before a complete value was transformed")
+		}
+		if s.srcDone, err = s.src.Step(tokenSlot); err != nil {
+			return true, err
+		}
+		if err := s.chain.push(0, tokenSlot); err != nil {
+			return true, err
+		}
+	}
+	*tokenSlot = s.queue[s.next]
+	s.next++
+	switch tokenSlot.Type {
+	case TMapOpen, TArrOpen:
+		s.depth++
+	case TMapClose, TArrClose:
+		s.depth--
+	}
+	return s.depth == 0, nil
+}
+
+/*
+	Sink is a TokenSink that transforms the tokens stepped into it by a
+	chain of Ops, and passes the results on to another sink.
+
+	It's done when it's been given one complete value.
+*/
+type Sink struct {
+	sink     shared.TokenSink
+	sinkDone bool
+	chain    chain
+	depth    int // Depth of the tokens given so far.
+}
+
+func NewSink(sink shared.TokenSink, ops ...Op) *Sink {
+	s := &Sink{sink: sink}
+	s.chain.init(ops, s.forward)
+	return s
+}
+
+func (s *Sink) forward(tok *Token) (err error) {
+	if s.sinkDone {
+		return fmt.Errorf("transform: sink already done, but given more tokens")
+	}
+	s.sinkDone, err = s.sink.Step(tok)
+	return err
+}
+
+func (s *Sink) Step(tok *Token) (done bool, err error) {
+	switch tok.Type {
+	case TMapOpen, TArrOpen:
+		s.depth++
+	case TMapClose, TArrClose:
+		s.depth--
+	}
+	if err := s.chain.push(0, tok); err != nil {
+		return true, err
+	}
+	if s.depth > 0 {
+		return false, nil
+	}
+	if !s.sinkDone {
+		return true, fmt.Errorf("transform: value finished, but sink expects more")
+	}
+	return true, nil
+}
+
+// A chain of Ops, each one's output feeding the next one's input.
+type chain struct {
+	ops      []Op
+	trackers []tracker            // Tracks the position in each Op's input.
+	slots    []Token              // Each Op's copy of the token it's transforming.
+	emits    []func(*Token) error // Pushes a token into the next stage.
+	out      func(*Token) error   // The end of the chain.
+}
+
+func (c *chain) init(ops []Op, out func(*Token) error) {
+	c.ops = ops
+	c.trackers = make([]tracker, len(ops))
+	c.slots = make([]Token, len(ops))
+	c.emits = make([]func(*Token) error, len(ops))
+	for i := range ops {
+		i := i
+		c.emits[i] = func(tok *Token) error { return c.push(i+1, tok) }
+	}
+	c.out = out
+}
+
+// Push a token into stage `i` of the chain.
+func (c *chain) push(i int, tok *Token) error {
+	if i == len(c.ops) {
+		return c.out(tok)
+	}
+	c.slots[i] = *tok
+	tok = &c.slots[i]
+	typ := tok.Type
+	at := c.trackers[i].before(tok)
+	err := c.ops[i].Transform(tok, at, c.emits[i])
+	c.trackers[i].after(typ, at)
+	return err
+}
+
+// Keeps track of the path of each token in a stream.
+type tracker struct {
+	path  query.Pointer
+	stack []trackerFrame // One for each open map or array.
+}
+
+type trackerFrame struct {
+	isMap     bool
+	expectKey bool // If a map: whether the next token is a key (or the end).
+	index     int  // If an array: index of the next entry.
+}
+
+// Find the position of the next token, and step into it if it's a map key or array entry.
+func (tr *tracker) before(tok *Token) Position {
+	if n := len(tr.stack); n > 0 {
+		f := &tr.stack[n-1]
+		switch {
+		case tok.Type == TMapClose || tok.Type == TArrClose:
+			// The container's own path.
+		case f.isMap && f.expectKey:
+			f.expectKey = false
+			tr.path = append(tr.path, query.KeyStep(tok))
+			return Position{Path: tr.path, Key: true}
+		case !f.isMap:
+			tr.path = append(tr.path, strconv.Itoa(f.index))
+			f.index++
+		}
+	}
+	return Position{Path: tr.path}
+}
+
+// Step out of anything the token (of type `typ`, at `at`) finished.
+func (tr *tracker) after(typ TokenType, at Position) {
+	if at.Key {
+		return
+	}
+	switch typ {
+	case TMapOpen:
+		tr.stack = append(tr.stack, trackerFrame{isMap: true, expectKey: true})
+		return
+	case TArrOpen:
+		tr.stack = append(tr.stack, trackerFrame{})
+		return
+	case TMapClose, TArrClose:
+		tr.stack = tr.stack[:len(tr.stack)-1]
+	}
+	// A value is complete; step out of its entry.
+	if n := len(tr.stack); n > 0 {
+		tr.path = tr.path[:len(tr.path)-1]
+		tr.stack[n-1].expectKey = tr.stack[n-1].isMap
+	}
+}
+
+// Reports whether a path matches a pattern, in which `*` matches any one step.
+func matches(pattern, path query.Pointer) bool {
+	if len(pattern) != len(path) {
+		return false
+	}
+	for i := range pattern {
+		if pattern[i] != "*" && pattern[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Reports whether a path matches any of the patterns.
+func matchesAny(patterns []query.Pointer, path query.Pointer) bool {
+	for _, p := range patterns {
+		if matches(p, path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package transform
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/polydawn/refmt/cbor"
+	"github.com/polydawn/refmt/json"
+	"github.com/polydawn/refmt/query"
+	"github.com/polydawn/refmt/shared"
+	. "github.com/polydawn/refmt/tok"
+)
+
+var p = query.MustParsePointer
+
+const transformFixture = `{"name":"x","items":[{"id":1,"secret":"a"},{"id":2,"secret":"b","tags":["t"]}],"meta":{"v":3}}`
+
+// Transform json with a fresh set of ops, as both a Source and a Sink,
+// and check both give the same result.
+func transformJson(serial string, ops func() []Op) (string, error) {
+	var buf1, buf2 bytes.Buffer
+	err1 := shared.TokenPump{
+		TokenSource: NewSource(json.NewDecoder(strings.NewReader(serial)), ops()...),
+		TokenSink:   json.NewEncoder(&buf1),
+	}.Run()
+	err2 := shared.TokenPump{
+		TokenSource: json.NewDecoder(strings.NewReader(serial)),
+		TokenSink:   NewSink(json.NewEncoder(&buf2), ops()...),
+	}.Run()
+	So(err2, ShouldResemble, err1)
+	if err1 == nil {
+		So(buf2.String(), ShouldEqual, buf1.String())
+	}
+	return buf1.String(), err1
+}
+
+func TestTransform(t *testing.T) {
+	Convey("Transforming streams", t, func() {
+		for _, tr := range []struct {
+			title  string
+			ops    func() []Op
+			expect string
+		}{
+			{"no ops",
+				func() []Op { return nil },
+				transformFixture},
+			{"rename",
+				func() []Op { return []Op{Rename(p("/items/*/id"), "ID"), Rename(p("/name"), "title")} },
+				`{"title":"x","items":[{"ID":1,"secret":"a"},{"ID":2,"secret":"b","tags":["t"]}],"meta":{"v":3}}`},
+			{"drop",
+				func() []Op { return []Op{Drop(p("/items/*/secret"), p("/meta"), p("/items/1/tags/0"))} },
+				`{"name":"x","items":[{"id":1},{"id":2,"tags":[]}]}`},
+			{"drop array entries",
+				func() []Op { return []Op{Drop(p("/items/0"))} },
+				`{"name":"x","items":[{"id":2,"secret":"b","tags":["t"]}],"meta":{"v":3}}`},
+			{"replace",
+				func() []Op {
+					return []Op{
+						Replace(p("/items/*/secret"), Token{Type: TString, Str: "***"}),
+						Replace(p("/meta"), Token{Type: TArrOpen, Length: 1}, Token{Type: TNull}, Token{Type: TArrClose}),
+					}
+				},
+				`{"name":"x","items":[{"id":1,"secret":"***"},{"id":2,"secret":"***","tags":["t"]}],"meta":[null]}`},
+			{"replace the root",
+				func() []Op { return []Op{Replace(p(""), Token{Type: TBool, Bool: true})} },
+				`true`},
+			{"project",
+				func() []Op { return []Op{Project(p("/items/*/id"), p("/meta"))} },
+				`{"items":[{"id":1},{"id":2}],"meta":{"v":3}}`},
+			{"project nothing",
+				func() []Op { return []Op{Project()} },
+				`{}`},
+			{"wrap",
+				func() []Op { return []Op{Wrap(p("/items/*/id"), "n"), Wrap(p("/meta"), "m")} },
+				`{"name":"x","items":[{"id":{"n":1},"secret":"a"},{"id":{"n":2},"secret":"b","tags":["t"]}],"meta":{"m":{"v":3}}}`},
+			{"wrap the root, and within it",
+				func() []Op { return []Op{Wrap(p(""), "doc"), Wrap(p("/doc/meta"), "m")} },
+				`{"doc":{"name":"x","items":[{"id":1,"secret":"a"},{"id":2,"secret":"b","tags":["t"]}],"meta":{"m":{"v":3}}}}`},
+			{"unwrap",
+				func() []Op { return []Op{Unwrap(p("/items"), "1"), Unwrap(p("/meta"), "v")} },
+				`{"name":"x","items":{"id":2,"secret":"b","tags":["t"]},"meta":3}`},
+			{"unwrap each",
+				func() []Op { return []Op{Unwrap(p("/items/*"), "id")} },
+				`{"name":"x","items":[1,2],"meta":{"v":3}}`},
+			{"unwrap within unwrapped",
+				func() []Op { return []Op{Unwrap(p("/items"), "1"), Unwrap(p("/items/tags"), "0")} },
+				`{"name":"x","items":{"id":2,"secret":"b","tags":"t"},"meta":{"v":3}}`},
+			{"unwrap the root",
+				func() []Op { return []Op{Unwrap(p(""), "items"), Unwrap(p(""), "0")} },
+				`{"id":1,"secret":"a"}`},
+			{"wrap and unwrap",
+				func() []Op { return []Op{Wrap(p("/items/*"), "w"), Unwrap(p("/items/*"), "w")} },
+				transformFixture},
+		} {
+			Convey(tr.title, func() {
+				got, err := transformJson(transformFixture, tr.ops)
+				So(err, ShouldBeNil)
+				So(got, ShouldEqual, tr.expect)
+			})
+		}
+	})
+	Convey("Unwrapping what's not there", t, func() {
+		_, err := transformJson(transformFixture, func() []Op { return []Op{Unwrap(p("/meta"), "nope")} })
+		So(err, ShouldResemble, ErrUnwrap{"/meta", `no entry "nope"`})
+		_, err = transformJson(transformFixture, func() []Op { return []Op{Unwrap(p("/name"), "x")} })
+		So(err, ShouldResemble, ErrUnwrap{"/name", "found string, not a map or array"})
+	})
+	Convey("Transcoding cbor with definite lengths", t, func() {
+		serial, err := cbor.Marshal(map[string]interface{}{"a": 1, "b": []int{1, 2, 3}, "c": "x"})
+		So(err, ShouldBeNil)
+		var out bytes.Buffer
+		err = shared.TokenPump{
+			TokenSource: NewSource(cbor.NewDecoder(bytes.NewReader(serial)), Drop(p("/b/1"), p("/c"))),
+			TokenSink:   cbor.NewEncoder(&out),
+		}.Run()
+		So(err, ShouldBeNil)
+		var v interface{}
+		So(cbor.Unmarshal(out.Bytes(), &v), ShouldBeNil)
+		So(v, ShouldResemble, map[string]interface{}{"a": uint64(1), "b": []interface{}{uint64(1), uint64(3)}})
+	})
+}
@@ -4,6 +4,9 @@ import (
 	"io"
 
 	"github.com/polydawn/refmt/cbor"
+	"github.com/polydawn/refmt/cid"
+	"github.com/polydawn/refmt/dagcbor"
+	"github.com/polydawn/refmt/dagjson"
 	"github.com/polydawn/refmt/json"
 	"github.com/polydawn/refmt/obj/atlas"
 )
@@ -13,22 +16,30 @@ type DecodeOptions interface {
 }
 
 func Unmarshal(opts DecodeOptions, data []byte, v interface{}) error {
-	switch opts.(type) {
+	switch o := opts.(type) {
 	case json.DecodeOptions:
-		return json.Unmarshal(data, v)
+		return json.UnmarshalWithOptions(o, data, v, atlas.MustBuild(json.NumberAtlasEntry))
 	case cbor.DecodeOptions:
-		return cbor.Unmarshal(data, v)
+		return cbor.UnmarshalWithOptions(o, data, v, atlas.MustBuild())
+	case dagcbor.DecodeOptions:
+		return dagcbor.UnmarshalWithOptions(o, data, v, atlas.MustBuild(cid.AtlasEntry))
+	case dagjson.DecodeOptions:
+		return dagjson.UnmarshalWithOptions(o, data, v, atlas.MustBuild(cid.AtlasEntry))
 	default:
 		panic("incorrect usage: unknown DecodeOptions type")
 	}
 }
 
 func UnmarshalAtlased(opts DecodeOptions, data []byte, v interface{}, atl atlas.Atlas) error {
-	switch opts.(type) {
+	switch o := opts.(type) {
 	case json.DecodeOptions:
-		return json.UnmarshalAtlased(data, v, atl)
+		return json.UnmarshalWithOptions(o, data, v, atl)
 	case cbor.DecodeOptions:
-		return cbor.UnmarshalAtlased(data, v, atl)
+		return cbor.UnmarshalWithOptions(o, data, v, atl)
+	case dagcbor.DecodeOptions:
+		return dagcbor.UnmarshalWithOptions(o, data, v, atl)
+	case dagjson.DecodeOptions:
+		return dagjson.UnmarshalWithOptions(o, data, v, atl)
 	default:
 		panic("incorrect usage: unknown DecodeOptions type")
 	}
@@ -39,22 +50,30 @@ type Unmarshaller interface {
 }
 
 func NewUnmarshaller(opts DecodeOptions, r io.Reader) Unmarshaller {
-	switch opts.(type) {
+	switch o := opts.(type) {
 	case json.DecodeOptions:
-		return json.NewUnmarshaller(r)
+		return json.NewUnmarshallerWithOptions(o, r, atlas.MustBuild(json.NumberAtlasEntry))
 	case cbor.DecodeOptions:
-		return cbor.NewUnmarshaller(r)
+		return cbor.NewUnmarshallerWithOptions(o, r, atlas.MustBuild())
+	case dagcbor.DecodeOptions:
+		return dagcbor.NewUnmarshallerWithOptions(o, r, atlas.MustBuild(cid.AtlasEntry))
+	case dagjson.DecodeOptions:
+		return dagjson.NewUnmarshallerWithOptions(o, r, atlas.MustBuild(cid.AtlasEntry))
 	default:
 		panic("incorrect usage: unknown DecodeOptions type")
 	}
 }
 
 func NewUnmarshallerAtlased(opts DecodeOptions, r io.Reader, atl atlas.Atlas) Unmarshaller {
-	switch opts.(type) {
+	switch o := opts.(type) {
 	case json.DecodeOptions:
-		return json.NewUnmarshallerAtlased(r, atl)
+		return json.NewUnmarshallerWithOptions(o, r, atl)
 	case cbor.DecodeOptions:
-		return cbor.NewUnmarshallerAtlased(r, atl)
+		return cbor.NewUnmarshallerWithOptions(o, r, atl)
+	case dagcbor.DecodeOptions:
+		return dagcbor.NewUnmarshallerWithOptions(o, r, atl)
+	case dagjson.DecodeOptions:
+		return dagjson.NewUnmarshallerWithOptions(o, r, atl)
 	default:
 		panic("incorrect usage: unknown DecodeOptions type")
 	}